@@ -0,0 +1,175 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+)
+
+// BlockHandler handles the blocklist that keeps wallpapers, tags and uploaders
+// out of searches
+type BlockHandler struct {
+	cache  interfaces.WallpaperCache
+	logger *slog.Logger
+}
+
+// NewBlockHandler creates a new block handler
+func NewBlockHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *BlockHandler {
+	return &BlockHandler{
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// blockTarget is one value to add to or remove from the blocklist
+type blockTarget struct {
+	kind      string
+	value     string
+	wallpaper *wallhaven.WallpaperMetadata // The cached wallpaper behind a wallpaper block, if any
+}
+
+// targets collects what the arguments and flags refer to. With neither a
+// wallpaper nor a tag or uploader given, the current wallpaper is meant.
+func (h *BlockHandler) targets(c *cli.Command) ([]blockTarget, error) {
+	var targets []blockTarget
+	for _, tag := range c.StringSlice("tag") {
+		targets = append(targets, blockTarget{kind: constants.BlockKindTag, value: tag})
+	}
+	for _, uploader := range c.StringSlice("uploader") {
+		targets = append(targets, blockTarget{kind: constants.BlockKindUploader, value: uploader})
+	}
+
+	refs := c.Args().Slice()
+	if len(refs) == 0 && len(targets) == 0 {
+		current := currentWallpaper(h.cache)
+		if current == nil {
+			return nil, fmt.Errorf("no current wallpaper to block")
+		}
+		refs = []string{current.ID}
+	}
+
+	for _, ref := range refs {
+		wallpaper := findWallpaper(h.cache, ref)
+		id := ref
+		if wallpaper != nil {
+			if id = wallhaven.WallhavenID(wallpaper.Path); id == "" {
+				return nil, fmt.Errorf("%s is not a wallhaven wallpaper", wallpaper.Path)
+			}
+		}
+		targets = append(targets, blockTarget{kind: constants.BlockKindWallpaper, value: id, wallpaper: wallpaper})
+	}
+	return targets, nil
+}
+
+// Handle blocks wallpapers by cache ID, wallhaven ID or path, tags and uploaders
+func (h *BlockHandler) Handle(ctx context.Context, c *cli.Command) error {
+	targets, err := h.targets(c)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if err := h.cache.Block(target.kind, target.value); err != nil {
+			h.logger.Error("Failed to block", "kind", target.kind, "value", target.value, "error", err)
+			return err
+		}
+		fmt.Printf("Blocked %s %s\n", target.kind, target.value)
+
+		if target.wallpaper != nil && c.Bool("delete") {
+			if err := h.cache.TrashWallpaper(target.wallpaper.ID, constants.TrashReasonBlocked); err != nil {
+				h.logger.Error("Failed to remove blocked wallpaper", "error", err, "path", target.wallpaper.Path)
+				return err
+			}
+			fmt.Printf("Moved to trash: %s\n", target.wallpaper.Path)
+		}
+	}
+	return nil
+}
+
+// HandleRemove takes wallpapers, tags and uploaders off the blocklist
+func (h *BlockHandler) HandleRemove(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() == 0 && len(c.StringSlice("tag")) == 0 && len(c.StringSlice("uploader")) == 0 {
+		return fmt.Errorf("a wallpaper ID, --tag or --uploader is required")
+	}
+	targets, err := h.targets(c)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		removed, err := h.cache.Unblock(target.kind, target.value)
+		if err != nil {
+			return err
+		}
+		if removed {
+			fmt.Printf("Unblocked %s %s\n", target.kind, target.value)
+		} else {
+			fmt.Printf("%s %s was not blocked\n", target.kind, target.value)
+		}
+	}
+	return nil
+}
+
+// HandleList lists the blocklist
+func (h *BlockHandler) HandleList(ctx context.Context, c *cli.Command) error {
+	out, err := newRenderer(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := h.cache.ListBlocked()
+	if err != nil {
+		h.logger.Error("Failed to list blocklist", "error", err)
+		return err
+	}
+	if !out.text() {
+		return out.render(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("Nothing is blocked\n")
+		return nil
+	}
+	for _, entry := range entries {
+		fmt.Printf("%-10s %-30s %s\n", entry.Kind, entry.Value, entry.AddedAt.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// targetFlags are the flags naming tags and uploaders to block or unblock
+func (h *BlockHandler) targetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "tag",
+			Aliases: []string{"t"},
+			Usage:   "Tag to block; searches exclude it",
+		},
+		&cli.StringSliceFlag{
+			Name:    "uploader",
+			Aliases: []string{"u"},
+			Usage:   "Uploader whose wallpapers searches skip",
+		},
+	}
+}
+
+// GetFlags returns the CLI flags for the block command
+func (h *BlockHandler) GetFlags() []cli.Flag {
+	return append(h.targetFlags(),
+		&cli.BoolFlag{
+			Name:  "delete",
+			Usage: "Also move blocked wallpapers that are in the cache to the trash",
+		},
+	)
+}
+
+// GetRemoveFlags returns the CLI flags for the block remove command
+func (h *BlockHandler) GetRemoveFlags() []cli.Flag {
+	return h.targetFlags()
+}
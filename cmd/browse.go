@@ -0,0 +1,718 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/executor"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
+)
+
+// BrowseHandler runs the interactive terminal browser
+type BrowseHandler struct {
+	cache     interfaces.WallpaperCache
+	executor  interfaces.ScriptExecutor
+	validator interfaces.Validator
+	logger    *slog.Logger
+}
+
+// NewBrowseHandler creates a new browse handler
+func NewBrowseHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *BrowseHandler {
+	return &BrowseHandler{
+		cache:     cache,
+		executor:  executor.NewScriptExecutor(logger),
+		validator: validator.NewValidator(),
+		logger:    logger,
+	}
+}
+
+// browserMode decides what keystrokes do
+type browserMode int
+
+const (
+	modeNormal browserMode = iota
+	modeFilter
+	modeTag
+	modeConfirmDelete
+)
+
+// browserHelp is shown in the footer when there is nothing else to say
+const browserHelp = "enter apply  f favourite  1-5 rate  t tag  d delete  / filter  tab source  q quit"
+
+// escapeKeys maps the escape sequences terminals send to key names
+var escapeKeys = map[string]string{
+	"\x1b[A": "up", "\x1b[B": "down", "\x1b[C": "right", "\x1b[D": "left",
+	"\x1bOA": "up", "\x1bOB": "down", "\x1bOC": "right", "\x1bOD": "left",
+	"\x1b[5~": "pgup", "\x1b[6~": "pgdown", "\x1b[3~": "delete",
+	"\x1b[H": "home", "\x1b[F": "end", "\x1b[1~": "home", "\x1b[4~": "end",
+	"\x1bOH": "home", "\x1bOF": "end",
+}
+
+// browser is the state of a browse session
+type browser struct {
+	handler *BrowseHandler
+	cmd     *cli.Command
+	out     *bufio.Writer
+	fd      int
+
+	source string
+	tags   []string
+	all    []*wallhaven.WallpaperMetadata
+	items  []*wallhaven.WallpaperMetadata // all, narrowed by filter
+	cursor int
+	offset int
+	filter string
+
+	mode    browserMode
+	input   string
+	message string
+
+	preview  string
+	previews map[string]string // Encoded previews keyed by ID and size
+}
+
+// Handle processes the browse command
+func (h *BrowseHandler) Handle(ctx context.Context, c *cli.Command) error {
+	source := c.String("source")
+	if err := h.validator.ValidateSource(source); err != nil {
+		return err
+	}
+	previewMode := c.String("preview")
+	if err := h.validator.ValidatePreviewMode(previewMode); err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("browse needs an interactive terminal")
+	}
+
+	b := &browser{
+		handler:  h,
+		cmd:      c,
+		out:      bufio.NewWriter(os.Stdout),
+		fd:       int(os.Stdout.Fd()),
+		source:   source,
+		tags:     c.StringSlice("tags"),
+		preview:  detectPreview(previewMode),
+		previews: make(map[string]string),
+		message:  browserHelp,
+	}
+	if err := b.load(); err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to configure terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	// Use the alternate screen so the shell's scrollback is left alone
+	b.out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		if b.preview == constants.PreviewKitty {
+			b.out.WriteString(wallhaven.KittyClearImages)
+		}
+		b.out.WriteString("\x1b[?25h\x1b[?1049l")
+		b.out.Flush()
+	}()
+
+	keys := make(chan []byte)
+	go readInput(keys)
+
+	resize := make(chan os.Signal, 1)
+	if len(resizeSignals) > 0 {
+		signal.Notify(resize, resizeSignals...)
+		defer signal.Stop(resize)
+	}
+
+	b.draw()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-resize:
+			b.draw()
+		case data, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, key := range parseKeys(data) {
+				if b.handleKey(key) {
+					return nil
+				}
+			}
+			b.draw()
+		}
+	}
+}
+
+// readInput sends whatever is read from stdin until it is closed
+func readInput(keys chan<- []byte) {
+	defer close(keys)
+	buf := make([]byte, 256)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			keys <- bytes.Clone(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parseKeys splits raw terminal input into key names. Printable characters are
+// returned as themselves.
+func parseKeys(data []byte) []string {
+	var keys []string
+	for i := 0; i < len(data); {
+		if data[i] == 0x1b {
+			name, size := parseEscape(data[i:])
+			if name != "" {
+				keys = append(keys, name)
+			}
+			i += size
+			continue
+		}
+
+		switch data[i] {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+		case '\t':
+			keys = append(keys, "tab")
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+		default:
+			r, size := utf8.DecodeRune(data[i:])
+			if unicode.IsPrint(r) {
+				keys = append(keys, string(r))
+			}
+			i += size
+			continue
+		}
+		i++
+	}
+	return keys
+}
+
+// parseEscape names the escape sequence at the start of data and returns its
+// length. Unknown sequences are skipped with an empty name; a lone ESC is "esc".
+func parseEscape(data []byte) (string, int) {
+	for seq, name := range escapeKeys {
+		if bytes.HasPrefix(data, []byte(seq)) {
+			return name, len(seq)
+		}
+	}
+	if len(data) > 1 && data[1] == '[' {
+		// Skip to the final byte of an unrecognised control sequence
+		for i := 2; i < len(data); i++ {
+			if data[i] >= 0x40 && data[i] <= 0x7e {
+				return "", i + 1
+			}
+		}
+		return "", len(data)
+	}
+	return "esc", 1
+}
+
+// detectPreview resolves the auto preview mode from the terminal's environment
+func detectPreview(mode string) string {
+	if mode != constants.PreviewAuto {
+		return mode
+	}
+
+	termName := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "", strings.Contains(termName, "kitty"),
+		strings.Contains(termName, "ghostty"), program == "WezTerm":
+		return constants.PreviewKitty
+	case strings.HasPrefix(termName, "foot"), strings.Contains(termName, "mlterm"),
+		strings.Contains(termName, "contour"):
+		return constants.PreviewSixel
+	default:
+		return constants.PreviewNone
+	}
+}
+
+// load reads the current source from the cache
+func (b *browser) load() error {
+	limit := 0
+	if b.source == constants.SourceHistory {
+		limit = constants.MaxHistorySize
+	}
+	wallpapers, err := loadSource(b.handler.cache, b.source, b.tags, limit)
+	if err != nil {
+		return err
+	}
+	b.all = wallpapers
+	b.applyFilter()
+	return nil
+}
+
+// applyFilter narrows the list to wallpapers matching every word of the filter
+// in their name, ID, resolution or tags
+func (b *browser) applyFilter() {
+	b.items = b.items[:0]
+	for _, wallpaper := range b.all {
+		if matchesQuery(wallpaper, b.filter) {
+			b.items = append(b.items, wallpaper)
+		}
+	}
+	b.cursor = max(0, min(b.cursor, len(b.items)-1))
+}
+
+// selected returns the wallpaper under the cursor, if any
+func (b *browser) selected() *wallhaven.WallpaperMetadata {
+	if b.cursor < len(b.items) {
+		return b.items[b.cursor]
+	}
+	return nil
+}
+
+// handleKey acts on a key and reports whether the browser should exit
+func (b *browser) handleKey(key string) bool {
+	if key == "ctrl-c" {
+		return true
+	}
+
+	switch b.mode {
+	case modeFilter:
+		switch key {
+		case "enter":
+			b.mode = modeNormal
+		case "esc":
+			b.filter = ""
+			b.mode = modeNormal
+		case "backspace":
+			b.filter = dropLastRune(b.filter)
+		case "up", "down", "pgup", "pgdown":
+			b.move(key)
+			return false
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				b.filter += key
+			}
+		}
+		b.applyFilter()
+		return false
+	case modeTag:
+		switch key {
+		case "enter":
+			b.mode = modeNormal
+			b.tag(b.input)
+		case "esc":
+			b.mode = modeNormal
+			b.message = "Cancelled"
+		case "backspace":
+			b.input = dropLastRune(b.input)
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				b.input += key
+			}
+		}
+		return false
+	case modeConfirmDelete:
+		b.mode = modeNormal
+		if key == "y" || key == "Y" {
+			b.delete()
+		} else {
+			b.message = "Cancelled"
+		}
+		return false
+	}
+
+	switch key {
+	case "q":
+		return true
+	case "up", "k", "down", "j", "pgup", "pgdown", "home", "g", "end", "G":
+		b.move(key)
+	case "/":
+		b.mode = modeFilter
+	case "esc":
+		b.filter = ""
+		b.applyFilter()
+	case "enter":
+		b.apply()
+	case "f":
+		b.toggleFavorite()
+	case "1", "2", "3", "4", "5":
+		b.rate(int(key[0] - '0'))
+	case "t":
+		if b.selected() != nil {
+			b.mode = modeTag
+			b.input = ""
+		}
+	case "d", "delete":
+		if b.selected() != nil {
+			b.mode = modeConfirmDelete
+		}
+	case "tab":
+		b.nextSource()
+	case "r":
+		if err := b.load(); err != nil {
+			b.message = err.Error()
+		}
+	}
+	return false
+}
+
+// move moves the cursor for a navigation key
+func (b *browser) move(key string) {
+	page := max(1, b.listHeight()-1)
+	switch key {
+	case "up", "k":
+		b.cursor--
+	case "down", "j":
+		b.cursor++
+	case "pgup":
+		b.cursor -= page
+	case "pgdown":
+		b.cursor += page
+	case "home", "g":
+		b.cursor = 0
+	case "end", "G":
+		b.cursor = len(b.items) - 1
+	}
+	b.cursor = max(0, min(b.cursor, len(b.items)-1))
+}
+
+// nextSource switches to the next of constants.BrowseSources
+func (b *browser) nextSource() {
+	next := (slices.Index(constants.BrowseSources, b.source) + 1) % len(constants.BrowseSources)
+	b.source = constants.BrowseSources[next]
+	b.cursor, b.offset = 0, 0
+	if err := b.load(); err != nil {
+		b.message = err.Error()
+	}
+}
+
+// apply runs the apply script on the selected wallpaper
+func (b *browser) apply() {
+	wallpaper := b.selected()
+	if wallpaper == nil {
+		return
+	}
+	scriptPath := b.cmd.String("scriptPath")
+	if scriptPath == "" {
+		b.message = "Set --scriptPath to apply wallpapers"
+		return
+	}
+
+	cache, logger := b.handler.cache, b.handler.logger
+	if err := b.handler.executor.Execute(scriptPath, applyPath(b.cmd, cache, logger, wallpaper)); err != nil {
+		b.message = "Failed to apply: " + err.Error()
+		return
+	}
+	// As with goto, a wallpaper never shown needs a place in history for previous
+	// and next to step from
+	if wallpaper.UseCount == 0 {
+		if err := cache.MarkAsUsed(wallpaper.ID); err != nil {
+			logger.Warn("Failed to mark wallpaper as used", "error", err)
+		} else {
+			wallpaper.UseCount = 1
+		}
+	}
+	if err := cache.SetCurrentView(wallpaper.ID); err != nil {
+		logger.Warn("Failed to update current view", "error", err)
+	}
+	b.message = "Applied " + filepath.Base(wallpaper.Path)
+}
+
+// toggleFavorite flips the selected wallpaper's favourite status
+func (b *browser) toggleFavorite() {
+	wallpaper := b.selected()
+	if wallpaper == nil {
+		return
+	}
+	if err := b.handler.cache.ToggleFavorite(wallpaper.ID); err != nil {
+		b.message = "Failed to update favourite: " + err.Error()
+		return
+	}
+	wallpaper.IsFavorite = !wallpaper.IsFavorite
+	if wallpaper.IsFavorite {
+		b.message = "Added to favourites"
+	} else {
+		b.message = "Removed from favourites"
+	}
+}
+
+// rate sets the selected wallpaper's rating
+func (b *browser) rate(rating int) {
+	wallpaper := b.selected()
+	if wallpaper == nil {
+		return
+	}
+	if err := b.handler.cache.SetRating(wallpaper.ID, rating); err != nil {
+		b.message = "Failed to rate: " + err.Error()
+		return
+	}
+	wallpaper.Rating = rating
+	b.message = "Rated " + strings.Repeat("★", rating)
+}
+
+// tag adds the comma-separated tags in input to the selected wallpaper. Tags
+// prefixed with - are removed instead.
+func (b *browser) tag(input string) {
+	wallpaper := b.selected()
+	if wallpaper == nil {
+		return
+	}
+
+	var add, remove []string
+	for _, tag := range strings.Split(input, ",") {
+		tag = strings.TrimSpace(tag)
+		if name, ok := strings.CutPrefix(tag, "-"); ok && name != "" {
+			remove = append(remove, name)
+		} else if tag != "" {
+			add = append(add, tag)
+		}
+	}
+
+	cache := b.handler.cache
+	if len(add) > 0 {
+		if err := cache.AddTags(wallpaper.ID, add); err != nil {
+			b.message = "Failed to add tags: " + err.Error()
+			return
+		}
+	}
+	if len(remove) > 0 {
+		if err := cache.RemoveTags(wallpaper.ID, remove); err != nil {
+			b.message = "Failed to remove tags: " + err.Error()
+			return
+		}
+	}
+	if updated := cache.GetByID(wallpaper.ID); updated != nil {
+		wallpaper.Tags = updated.Tags
+	}
+	b.message = "Tags updated"
+}
+
+// delete moves the selected wallpaper and its file to the trash
+func (b *browser) delete() {
+	wallpaper := b.selected()
+	if wallpaper == nil {
+		return
+	}
+	if err := b.handler.cache.RemoveWallpaper(wallpaper.ID); err != nil {
+		b.message = "Failed to delete: " + err.Error()
+		return
+	}
+	b.all = slices.DeleteFunc(b.all, func(w *wallhaven.WallpaperMetadata) bool { return w.ID == wallpaper.ID })
+	b.applyFilter()
+	b.message = "Moved " + filepath.Base(wallpaper.Path) + " to trash"
+}
+
+// size returns the terminal size, falling back to 80x24
+func (b *browser) size() (int, int) {
+	width, height, err := term.GetSize(b.fd)
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// listHeight is the number of list rows between the header and the two footer lines
+func (b *browser) listHeight() int {
+	_, height := b.size()
+	return max(1, height-3)
+}
+
+// listWidth is the width of the list, leaving the rest of the screen for previews
+func (b *browser) listWidth() int {
+	width, _ := b.size()
+	if b.preview == constants.PreviewNone || width < 60 {
+		return width
+	}
+	return width / 2
+}
+
+// draw redraws the whole screen
+func (b *browser) draw() {
+	width, height := b.size()
+	listHeight, listWidth := b.listHeight(), b.listWidth()
+
+	// Keep the cursor on screen
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+listHeight {
+		b.offset = b.cursor - listHeight + 1
+	}
+
+	b.out.WriteString("\x1b[H\x1b[2J")
+	if b.preview == constants.PreviewKitty {
+		b.out.WriteString(wallhaven.KittyClearImages)
+	}
+
+	header := fmt.Sprintf(" %s · %s (%d/%d)", constants.AppName, b.source, len(b.items), len(b.all))
+	if b.filter != "" || b.mode == modeFilter {
+		header += "  filter: " + b.filter
+	}
+	fmt.Fprintf(b.out, "\x1b[1m%s\x1b[0m", truncate(header, width))
+
+	for row := range listHeight {
+		i := b.offset + row
+		if i >= len(b.items) {
+			break
+		}
+		line := truncate(" "+browserLine(b.items[i]), listWidth-1)
+		fmt.Fprintf(b.out, "\x1b[%d;1H", row+2)
+		if i == b.cursor {
+			fmt.Fprintf(b.out, "\x1b[7m%-*s\x1b[0m", listWidth-1, line)
+		} else {
+			b.out.WriteString(line)
+		}
+	}
+	if len(b.items) == 0 {
+		fmt.Fprintf(b.out, "\x1b[2;1H \x1b[2mNo wallpapers\x1b[0m")
+	}
+
+	if wallpaper := b.selected(); wallpaper != nil {
+		fmt.Fprintf(b.out, "\x1b[%d;1H\x1b[2m%s\x1b[0m", height-1, truncate(" "+browserDetails(wallpaper), width))
+		if listWidth < width {
+			b.drawPreview(wallpaper, listWidth+2, 2, width-listWidth-2, listHeight)
+		}
+	}
+
+	var footer string
+	switch b.mode {
+	case modeFilter:
+		footer = "/" + b.filter
+	case modeTag:
+		footer = "Tags (comma separated, -tag removes): " + b.input
+	case modeConfirmDelete:
+		footer = fmt.Sprintf("Delete %s and its file? [y/N]", filepath.Base(b.selected().Path))
+	default:
+		footer = b.message
+		b.message = browserHelp
+	}
+	fmt.Fprintf(b.out, "\x1b[%d;1H %s", height, truncate(footer, width-1))
+
+	b.out.Flush()
+}
+
+// drawPreview draws the wallpaper's thumbnail in the given cell area
+func (b *browser) drawPreview(wallpaper *wallhaven.WallpaperMetadata, column, row, columns, rows int) {
+	key := fmt.Sprintf("%s-%dx%d", wallpaper.ID, columns, rows)
+	encoded, ok := b.previews[key]
+	if !ok {
+		var err error
+		encoded, err = b.encodePreview(wallpaper, columns, rows)
+		if err != nil {
+			b.handler.logger.Debug("Failed to render preview", "id", wallpaper.ID, "error", err)
+		}
+		b.previews[key] = encoded
+	}
+	if encoded != "" {
+		fmt.Fprintf(b.out, "\x1b[%d;%dH%s", row, column, encoded)
+	}
+}
+
+// encodePreview renders a wallpaper's thumbnail to fit columns x rows cells
+func (b *browser) encodePreview(wallpaper *wallhaven.WallpaperMetadata, columns, rows int) (string, error) {
+	thumbnail, err := b.handler.cache.GetThumbnail(wallpaper)
+	if err != nil {
+		return "", err
+	}
+
+	cellWidth, cellHeight := terminalCellSize(b.fd)
+	if cellWidth == 0 || cellHeight == 0 {
+		cellWidth, cellHeight = constants.DefaultPreviewCellWidth, constants.DefaultPreviewCellHeight
+	}
+
+	img, err := wallhaven.LoadPreview(thumbnail, columns*cellWidth, rows*cellHeight)
+	if err != nil {
+		return "", err
+	}
+
+	if b.preview == constants.PreviewSixel {
+		return wallhaven.EncodeSixel(img), nil
+	}
+
+	// Kitty scales the image itself, so ask for the largest cell box that keeps
+	// its aspect ratio
+	bounds := img.Bounds()
+	scale := min(float64(columns*cellWidth)/float64(bounds.Dx()), float64(rows*cellHeight)/float64(bounds.Dy()))
+	fitColumns := max(1, int(float64(bounds.Dx())*scale)/cellWidth)
+	fitRows := max(1, int(float64(bounds.Dy())*scale)/cellHeight)
+	return wallhaven.EncodeKitty(img, fitColumns, fitRows)
+}
+
+// browserLine summarises a wallpaper in one list row
+func browserLine(wallpaper *wallhaven.WallpaperMetadata) string {
+	favorite := " "
+	if wallpaper.IsFavorite {
+		favorite = "♥"
+	}
+	stars := strings.Repeat("★", wallpaper.Rating) + strings.Repeat(" ", constants.MaxRating-wallpaper.Rating)
+	return fmt.Sprintf("%s %s %s", favorite, stars, filepath.Base(wallpaper.Path))
+}
+
+// browserDetails describes the selected wallpaper on the details line
+func browserDetails(wallpaper *wallhaven.WallpaperMetadata) string {
+	parts := []string{displayID(wallpaper)}
+	if wallpaper.Resolution != "" {
+		parts = append(parts, wallpaper.Resolution)
+	}
+	parts = append(parts, fmt.Sprintf("used %d times", wallpaper.UseCount))
+	if len(wallpaper.Tags) > 0 {
+		parts = append(parts, strings.Join(wallpaper.Tags, ", "))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// dropLastRune removes the last character of s
+func dropLastRune(s string) string {
+	_, size := utf8.DecodeLastRuneInString(s)
+	return s[:len(s)-size]
+}
+
+// GetFlags returns the CLI flags for the browse command
+func (h *BrowseHandler) GetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "source",
+			Aliases: []string{"s"},
+			Value:   constants.SourceHistory,
+			Usage:   "Wallpapers to browse: " + strings.Join(constants.ValidSources, ", "),
+		},
+		&cli.StringSliceFlag{
+			Name:    "tags",
+			Aliases: []string{"t"},
+			Usage:   "Tags to browse when source is tags",
+		},
+		&cli.StringFlag{
+			Name:      "scriptPath",
+			Aliases:   []string{"sp"},
+			TakesFile: true,
+			Usage:     "Path to the script to run when applying a wallpaper",
+		},
+		&cli.StringFlag{
+			Name:  "preview",
+			Value: constants.PreviewAuto,
+			Usage: "Image preview protocol: " + strings.Join(constants.ValidPreviewModes, ", "),
+		},
+	}
+}
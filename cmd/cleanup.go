@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/urfave/cli/v3"
 
@@ -33,48 +35,334 @@ func NewCleanupHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *Cl
 	}
 }
 
-// Handle processes the cleanup command
+// Handle processes the cleanup command. Several modes can be given at once, in
+// which case everything any of them finds is removed together. Duplicates are
+// worked out last, from what the other modes leave, so the copy kept is never one
+// they remove.
 func (h *CleanupHandler) Handle(ctx context.Context, c *cli.Command) error {
-	mode := c.String("mode")
+	modes := c.StringSlice("mode")
 	dryRun := c.Bool("dryRun")
 
-	if err := h.validator.ValidateCleanupMode(mode); err != nil {
+	for _, mode := range modes {
+		if err := h.validator.ValidateCleanupMode(mode); err != nil {
+			return err
+		}
+	}
+
+	out, err := newRenderer(c)
+	if err != nil {
 		return err
 	}
 
 	var toRemove []*wallhaven.WallpaperMetadata
+	seen := make(map[string]bool)
+	add := func(found []*wallhaven.WallpaperMetadata) {
+		for _, wallpaper := range found {
+			if !seen[wallpaper.Path] {
+				seen[wallpaper.Path] = true
+				toRemove = append(toRemove, wallpaper)
+			}
+		}
+	}
+
+	onlyInvalid, dedupe := true, false
+	for _, mode := range modes {
+		if mode == constants.CleanupModeInvalid {
+			if err := h.cache.CleanupInvalidEntries(); err != nil {
+				return fmt.Errorf("failed to cleanup invalid entries: %w", err)
+			}
+			if out.text() {
+				fmt.Printf("Cleaned up invalid cache entries\n")
+			}
+			continue
+		}
+		onlyInvalid = false
+		if mode == constants.CleanupModeDuplicates {
+			dedupe = true
+			continue
+		}
+
+		found, err := h.find(c, mode, out.text())
+		if err != nil {
+			return err
+		}
+		add(found)
+	}
+
+	// Duplicate copies are merged into the copy kept rather than just trashed
+	var keepers map[string]string
+	if dedupe {
+		threshold := c.Int("threshold")
+		if threshold < 0 || threshold > 64 {
+			return fmt.Errorf("threshold must be between 0 and 64")
+		}
+		var copies []*wallhaven.WallpaperMetadata
+		copies, keepers = h.findDuplicates(threshold, seen)
+		if out.text() {
+			fmt.Printf("Found %d duplicate copies\n", len(copies))
+		}
+		add(copies)
+	}
+
+	if !out.text() {
+		// List what would be or was removed instead of reporting progress
+		if !dryRun {
+			h.removeAll(toRemove, keepers)
+		}
+		return out.render(toRemove)
+	}
+
+	if onlyInvalid {
+		return nil
+	}
+	if len(toRemove) == 0 {
+		fmt.Printf("No wallpapers to remove\n")
+		return nil
+	}
+
+	return h.processRemoval(toRemove, keepers, dryRun)
+}
+
+// find returns what a cleanup mode would remove, describing it when verbose
+func (h *CleanupHandler) find(c *cli.Command, mode string, verbose bool) ([]*wallhaven.WallpaperMetadata, error) {
+	var found []*wallhaven.WallpaperMetadata
+	var description string
 
 	switch mode {
 	case constants.CleanupModeUnused:
-		toRemove = h.cache.GetUnusedWallpapers()
-		fmt.Printf("Found %d unused wallpapers\n", len(toRemove))
+		found = h.cache.GetUnusedWallpapers()
+		description = "unused wallpapers"
 	case constants.CleanupModeOld:
 		olderThanStr := c.String("olderThan")
-		duration, err := h.parseDuration(olderThanStr)
+		duration, err := parseDuration(olderThanStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid olderThan duration: %w", err)
+		}
+		found = h.cache.GetOldWallpapers(duration)
+		description = "wallpapers older than " + olderThanStr
+	case constants.CleanupModeQuota:
+		plan, err := h.cache.PlanEviction()
 		if err != nil {
-			return fmt.Errorf("invalid olderThan duration: %w", err)
+			return nil, fmt.Errorf("failed to plan eviction: %w", err)
 		}
-		toRemove = h.cache.GetOldWallpapers(duration)
-		fmt.Printf("Found %d wallpapers older than %s\n", len(toRemove), olderThanStr)
-	case constants.CleanupModeInvalid:
-		if err := h.cache.CleanupInvalidEntries(); err != nil {
-			return fmt.Errorf("failed to cleanup invalid entries: %w", err)
+		found = plan.Evict
+		description = "wallpapers over quota"
+		if verbose {
+			fmt.Printf("Cache holds %d wallpapers (%.2f MB); quota is %s and %s, evicting by %s\n",
+				plan.Count, float64(plan.Bytes)/1024/1024,
+				quotaLimit(plan.MaxCount, "wallpapers"), quotaLimit(int(plan.MaxBytes/1024/1024), "MB"), plan.Policy)
 		}
-		fmt.Printf("Cleaned up invalid cache entries\n")
-		return nil
+	case constants.CleanupModeOrphans:
+		downloadPath := c.String("downloadPath")
+		orphans, err := h.findOrphans(downloadPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", downloadPath, err)
+		}
+		found = orphans
+		description = "files in " + downloadPath + " missing from the cache"
+	case constants.CleanupModeLowRated:
+		maxRating := c.Int("maxRating")
+		found = filterWallpapers(h.cache.GetAll(), func(w *wallhaven.WallpaperMetadata) bool {
+			return w.Rating > 0 && w.Rating <= maxRating
+		})
+		description = fmt.Sprintf("wallpapers rated %d or lower", maxRating)
+	case constants.CleanupModeResolution:
+		filter, err := resolutionFilter(c.String("minResolution"), c.StringSlice("ratios"))
+		if err != nil {
+			return nil, err
+		}
+		found = filterWallpapers(h.cache.GetAll(), filter)
+		description = "wallpapers below the minimum resolution or outside the allowed ratios"
+	case constants.CleanupModeOversize:
+		maxBytes := int64(c.Int("maxSizeMB")) * 1024 * 1024
+		found = filterWallpapers(h.cache.GetAll(), func(w *wallhaven.WallpaperMetadata) bool {
+			return w.Size > maxBytes
+		})
+		description = fmt.Sprintf("wallpapers larger than %d MB", c.Int("maxSizeMB"))
 	default:
-		return fmt.Errorf("invalid cleanup mode: %s", mode)
+		return nil, fmt.Errorf("invalid cleanup mode: %s", mode)
 	}
 
-	if len(toRemove) == 0 {
-		fmt.Printf("No wallpapers to remove\n")
-		return nil
+	if verbose {
+		fmt.Printf("Found %d %s\n", len(found), description)
 	}
+	return found, nil
+}
 
-	return h.processRemoval(toRemove, dryRun)
+// findOrphans returns the images directly in dir that have no cache entry. They
+// have no ID, which tells the removal to trash them as plain files.
+func (h *CleanupHandler) findOrphans(dir string) ([]*wallhaven.WallpaperMetadata, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := findImages(dir, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []*wallhaven.WallpaperMetadata
+	for _, file := range files {
+		if h.cache.GetByPath(file) != nil {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		orphans = append(orphans, &wallhaven.WallpaperMetadata{
+			Path:         file,
+			Size:         info.Size(),
+			DownloadedAt: info.ModTime(),
+			LastUsed:     info.ModTime(),
+		})
+	}
+	return orphans, nil
 }
 
-func (h *CleanupHandler) processRemoval(toRemove []*wallhaven.WallpaperMetadata, dryRun bool) error {
+// findDuplicates returns every copy but the best of each group of near
+// duplicates, and of exact duplicates that have no perceptual hash to compare,
+// along with the ID of the copy each one is merged into. Wallpapers whose path is
+// in removed are already going and take no part, so the best copy is picked from
+// those that stay.
+func (h *CleanupHandler) findDuplicates(threshold int, removed map[string]bool) ([]*wallhaven.WallpaperMetadata, map[string]string) {
+	if _, err := h.cache.BackfillPerceptualHashes(); err != nil {
+		h.logger.Warn("Failed to backfill perceptual hashes", "error", err)
+	}
+
+	staying := func(w *wallhaven.WallpaperMetadata) bool { return !removed[w.Path] }
+
+	var groups [][]*wallhaven.WallpaperMetadata
+	grouped := make(map[string]bool)
+	for _, group := range h.cache.FindNearDuplicates(threshold) {
+		for _, wallpaper := range group {
+			grouped[wallpaper.ID] = true
+		}
+		groups = append(groups, filterWallpapers(group, staying))
+	}
+
+	// Identical files share a perceptual hash, so only those without one are left
+	byHash := make(map[string][]*wallhaven.WallpaperMetadata)
+	var hashes []string
+	for _, wallpaper := range filterWallpapers(h.cache.GetAll(), staying) {
+		if grouped[wallpaper.ID] {
+			continue
+		}
+		if byHash[wallpaper.Hash] == nil {
+			hashes = append(hashes, wallpaper.Hash)
+		}
+		byHash[wallpaper.Hash] = append(byHash[wallpaper.Hash], wallpaper)
+	}
+	for _, hash := range hashes {
+		if len(byHash[hash]) > 1 {
+			groups = append(groups, byHash[hash])
+		}
+	}
+
+	var copies []*wallhaven.WallpaperMetadata
+	keepers := make(map[string]string)
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		keep := wallhaven.BestCopy(group)
+		for _, wallpaper := range group {
+			if wallpaper != keep {
+				copies = append(copies, wallpaper)
+				keepers[wallpaper.ID] = keep.ID
+			}
+		}
+	}
+	return copies, keepers
+}
+
+// resolutionFilter matches wallpapers smaller than minResolution in either
+// dimension or whose aspect ratio is none of ratios. Wallpapers of unknown
+// resolution never match.
+func resolutionFilter(minResolution string, ratios []string) (func(*wallhaven.WallpaperMetadata) bool, error) {
+	if minResolution == "" && len(ratios) == 0 {
+		return nil, fmt.Errorf("the resolution mode needs --minResolution or --ratios")
+	}
+
+	var minWidth, minHeight int
+	if minResolution != "" {
+		var ok bool
+		if minWidth, minHeight, ok = wallhaven.ParseResolution(minResolution); !ok {
+			return nil, fmt.Errorf("invalid minimum resolution: %s", minResolution)
+		}
+	}
+
+	var allowed []float64
+	for _, ratio := range ratios {
+		w, h, ok := wallhaven.ParseResolution(ratio)
+		if !ok {
+			return nil, fmt.Errorf("invalid ratio: %s", ratio)
+		}
+		allowed = append(allowed, float64(w)/float64(h))
+	}
+
+	return func(wallpaper *wallhaven.WallpaperMetadata) bool {
+		width, height, ok := wallhaven.ParseResolution(wallpaper.Resolution)
+		if !ok {
+			return false
+		}
+		if width < minWidth || height < minHeight {
+			return true
+		}
+		if len(allowed) == 0 {
+			return false
+		}
+		ratio := float64(width) / float64(height)
+		for _, want := range allowed {
+			if math.Abs(ratio-want) <= want*constants.RatioTolerance {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// filterWallpapers returns the wallpapers matching keep
+func filterWallpapers(wallpapers []*wallhaven.WallpaperMetadata, keep func(*wallhaven.WallpaperMetadata) bool) []*wallhaven.WallpaperMetadata {
+	var matched []*wallhaven.WallpaperMetadata
+	for _, wallpaper := range wallpapers {
+		if keep(wallpaper) {
+			matched = append(matched, wallpaper)
+		}
+	}
+	return matched
+}
+
+// quotaLimit describes one quota limit, where zero means unlimited
+func quotaLimit(limit int, unit string) string {
+	if limit <= 0 {
+		return "unlimited " + unit
+	}
+	return fmt.Sprintf("%d %s", limit, unit)
+}
+
+// remove moves a wallpaper, or an orphaned file without an ID, to the trash. A
+// duplicate copy is merged into the copy keepers says is kept first.
+func (h *CleanupHandler) remove(wallpaper *wallhaven.WallpaperMetadata, keepers map[string]string) error {
+	if keep, ok := keepers[wallpaper.ID]; ok {
+		return h.cache.MergeDuplicate(keep, wallpaper.ID)
+	}
+	if wallpaper.ID == "" {
+		return h.cache.TrashFile(wallpaper.Path, constants.TrashReasonCleanup)
+	}
+	return h.cache.TrashWallpaper(wallpaper.ID, constants.TrashReasonCleanup)
+}
+
+// removeAll moves wallpapers to the trash without printing progress
+func (h *CleanupHandler) removeAll(toRemove []*wallhaven.WallpaperMetadata, keepers map[string]string) {
+	for _, wallpaper := range toRemove {
+		if err := h.remove(wallpaper, keepers); err != nil {
+			h.logger.Error("Failed to remove wallpaper", "error", err, "path", wallpaper.Path)
+		}
+	}
+}
+
+func (h *CleanupHandler) processRemoval(toRemove []*wallhaven.WallpaperMetadata, keepers map[string]string, dryRun bool) error {
 	var totalSize int64
 	for _, wallpaper := range toRemove {
 		totalSize += wallpaper.Size
@@ -84,8 +372,8 @@ func (h *CleanupHandler) processRemoval(toRemove []*wallhaven.WallpaperMetadata,
 				float64(wallpaper.Size)/1024/1024,
 				wallpaper.LastUsed.Format("2006-01-02 15:04:05"))
 		} else {
-			fmt.Printf("Removing: %s\n", wallpaper.Path)
-			if err := h.cache.RemoveWallpaper(wallpaper.ID); err != nil {
+			fmt.Printf("Moving to trash: %s\n", wallpaper.Path)
+			if err := h.remove(wallpaper, keepers); err != nil {
 				h.logger.Error("Failed to remove wallpaper", "error", err, "path", wallpaper.Path)
 			}
 		}
@@ -95,39 +383,12 @@ func (h *CleanupHandler) processRemoval(toRemove []*wallhaven.WallpaperMetadata,
 		fmt.Printf("\nWould free %.2f MB of storage\n", float64(totalSize)/1024/1024)
 		fmt.Printf("Run without --dryRun to actually remove these wallpapers\n")
 	} else {
-		fmt.Printf("\nFreed %.2f MB of storage\n", float64(totalSize)/1024/1024)
+		fmt.Printf("\nMoved %.2f MB to the trash; run 'trash empty' to free it now\n", float64(totalSize)/1024/1024)
 	}
 
 	return nil
 }
 
-func (h *CleanupHandler) parseDuration(s string) (time.Duration, error) {
-	if len(s) < 2 {
-		return 0, fmt.Errorf("invalid duration format")
-	}
-
-	unit := s[len(s)-1:]
-	valueStr := s[:len(s)-1]
-
-	value, err := time.ParseDuration(valueStr + "h")
-	if err != nil {
-		return 0, err
-	}
-
-	switch unit {
-	case "d":
-		return value * 24, nil
-	case "w":
-		return value * 24 * 7, nil
-	case "M":
-		return value * 24 * 30, nil
-	case "y":
-		return value * 24 * 365, nil
-	default:
-		return time.ParseDuration(s)
-	}
-}
-
 // GetFlags returns the CLI flags for the cleanup command
 func (h *CleanupHandler) GetFlags() []cli.Flag {
 	return []cli.Flag{
@@ -138,16 +399,40 @@ func (h *CleanupHandler) GetFlags() []cli.Flag {
 			TakesFile: true,
 			Usage:     "Absolute path to download directory",
 		},
-		&cli.StringFlag{
+		&cli.StringSliceFlag{
 			Name:  "mode",
-			Value: constants.CleanupModeUnused,
-			Usage: "Cleanup mode: " + strings.Join(constants.ValidCleanupModes, ", "),
+			Value: []string{constants.CleanupModeUnused},
+			Usage: "Cleanup modes, combined when repeated or comma separated: " + strings.Join(constants.ValidCleanupModes, ", "),
 		},
 		&cli.StringFlag{
 			Name:  "olderThan",
 			Value: constants.DefaultCleanupOlderThan,
 			Usage: "Remove wallpapers older than this duration (e.g., '30d', '1w')",
 		},
+		&cli.IntFlag{
+			Name:    "threshold",
+			Aliases: []string{"t"},
+			Value:   constants.DefaultSimilarityThreshold,
+			Usage:   "Maximum number of differing perceptual hash bits (0-64) for the duplicates mode",
+		},
+		&cli.IntFlag{
+			Name:  "maxRating",
+			Value: constants.DefaultCleanupMaxRating,
+			Usage: "Remove wallpapers rated at or below this in the low-rated mode; unrated wallpapers are kept",
+		},
+		&cli.StringFlag{
+			Name:  "minResolution",
+			Usage: "Remove wallpapers smaller than this (e.g. 1920x1080) in the resolution mode",
+		},
+		&cli.StringSliceFlag{
+			Name:  "ratios",
+			Usage: "Remove wallpapers whose aspect ratio is none of these (e.g. 16x9,16x10) in the resolution mode",
+		},
+		&cli.IntFlag{
+			Name:  "maxSizeMB",
+			Value: constants.DefaultCleanupMaxSizeMB,
+			Usage: "Remove wallpapers larger than this many megabytes in the oversize mode",
+		},
 		&cli.BoolFlag{
 			Name:  "dryRun",
 			Value: false,
@@ -0,0 +1,118 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
+)
+
+// ContactSheetHandler handles contact sheet generation
+type ContactSheetHandler struct {
+	cache     interfaces.WallpaperCache
+	validator interfaces.Validator
+	logger    *slog.Logger
+}
+
+// NewContactSheetHandler creates a new contact sheet handler
+func NewContactSheetHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *ContactSheetHandler {
+	return &ContactSheetHandler{
+		cache:     cache,
+		validator: validator.NewValidator(),
+		logger:    logger,
+	}
+}
+
+// Handle processes the contact-sheet command
+func (h *ContactSheetHandler) Handle(ctx context.Context, c *cli.Command) error {
+	source := c.String("source")
+	if err := h.validator.ValidateSource(source); err != nil {
+		return err
+	}
+
+	wallpapers, err := loadSource(h.cache, source, c.StringSlice("tags"), c.Int("limit"))
+	if err != nil {
+		return err
+	}
+	if len(wallpapers) == 0 {
+		fmt.Printf("No wallpapers found for source %s\n", source)
+		return nil
+	}
+
+	items := make([]wallhaven.ContactSheetItem, 0, len(wallpapers))
+	for _, wallpaper := range wallpapers {
+		thumb, err := h.cache.GetThumbnail(wallpaper)
+		if err != nil {
+			h.logger.Warn("Failed to get thumbnail", "path", wallpaper.Path, "error", err)
+		}
+		items = append(items, wallhaven.ContactSheetItem{
+			Thumbnail: thumb,
+			Caption:   contactSheetCaption(wallpaper),
+		})
+	}
+
+	output := c.String("output")
+	if err := wallhaven.CreateContactSheet(items, c.Int("columns"), output); err != nil {
+		h.logger.Error("Failed to create contact sheet", "error", err)
+		return err
+	}
+
+	fmt.Printf("Created contact sheet of %d wallpapers: %s\n", len(items), output)
+	return nil
+}
+
+// contactSheetCaption labels a cell with the wallpaper ID, rating and favourite status
+func contactSheetCaption(wallpaper *wallhaven.WallpaperMetadata) string {
+	caption := displayID(wallpaper)
+	if wallpaper.Rating > 0 {
+		caption += " " + strings.Repeat("*", wallpaper.Rating)
+	}
+	if wallpaper.IsFavorite {
+		caption += " [fav]"
+	}
+	return caption
+}
+
+// GetFlags returns the CLI flags for the contact-sheet command
+func (h *ContactSheetHandler) GetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "source",
+			Aliases: []string{"s"},
+			Value:   constants.SourceFavorites,
+			Usage:   "Wallpapers to include: " + strings.Join(constants.ValidSources, ", "),
+		},
+		&cli.StringSliceFlag{
+			Name:    "tags",
+			Aliases: []string{"t"},
+			Usage:   "Tags wallpapers must have when using the tags source",
+		},
+		&cli.IntFlag{
+			Name:    "limit",
+			Aliases: []string{"l"},
+			Value:   constants.DefaultListLimit,
+			Usage:   "Maximum number of wallpapers to include",
+		},
+		&cli.IntFlag{
+			Name:    "columns",
+			Aliases: []string{"c"},
+			Value:   constants.DefaultContactSheetColumns,
+			Usage:   "Number of thumbnails per row",
+		},
+		&cli.StringFlag{
+			Name:      "output",
+			Aliases:   []string{"o"},
+			Value:     "contact-sheet.png",
+			TakesFile: true,
+			Usage:     "Path to write the contact sheet PNG to",
+		},
+	}
+}
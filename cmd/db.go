@@ -0,0 +1,187 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+)
+
+// DBHandler handles database maintenance
+type DBHandler struct {
+	cache  interfaces.WallpaperCache
+	logger *slog.Logger
+}
+
+// NewDBHandler creates a new database handler
+func NewDBHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *DBHandler {
+	return &DBHandler{
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// HandleMigrate reports the schema version. Pending migrations are applied when
+// the cache is opened, so by the time this runs the database is up to date.
+func (h *DBHandler) HandleMigrate(ctx context.Context, c *cli.Command) error {
+	current, migrations, err := h.cache.MigrationStatus()
+	if err != nil {
+		h.logger.Error("Failed to read migration status", "error", err)
+		return err
+	}
+
+	fmt.Printf("Database schema version %d (latest %d)\n", current, wallhaven.SchemaVersion)
+	if !c.Bool("status") {
+		return nil
+	}
+
+	fmt.Printf("\n")
+	for _, m := range migrations {
+		state := "pending"
+		if m.Applied {
+			state = "applied"
+		}
+		fmt.Printf("  %3d  %-8s %s\n", m.Version, state, m.Description)
+	}
+	return nil
+}
+
+// GetMigrateFlags returns the CLI flags for the db migrate command
+func (h *DBHandler) GetMigrateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "status",
+			Usage: "List every migration and whether it has been applied",
+		},
+	}
+}
+
+// HandleBackup writes a timestamped copy of the database and prunes old copies
+func (h *DBHandler) HandleBackup(ctx context.Context, c *cli.Command) error {
+	dir := c.String("dir")
+	if dir == "" {
+		dir = h.cache.BackupDir()
+	}
+
+	path, err := h.cache.Backup(dir, int(c.Int("keep")))
+	if err != nil {
+		h.logger.Error("Failed to back up database", "error", err)
+		return err
+	}
+
+	fmt.Printf("Backed up database to %s\n", path)
+	return nil
+}
+
+// GetBackupFlags returns the CLI flags for the db backup command
+func (h *DBHandler) GetBackupFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "dir",
+			Aliases: []string{"d"},
+			Usage:   "Directory to write the backup to (default: backups folder next to the database)",
+		},
+		&cli.IntFlag{
+			Name:    "keep",
+			Aliases: []string{"k"},
+			Value:   constants.DefaultBackupRetention,
+			Usage:   "Number of backups to keep in the directory, 0 keeps all",
+		},
+	}
+}
+
+// HandleRestore replaces the database with a validated backup
+func (h *DBHandler) HandleRestore(ctx context.Context, c *cli.Command) error {
+	input := c.Args().First()
+	if input == "" {
+		return fmt.Errorf("a backup file to restore is required")
+	}
+
+	safety, err := h.cache.Restore(input)
+	if safety != "" {
+		fmt.Printf("Saved the previous database to %s\n", safety)
+	}
+	if err != nil {
+		h.logger.Error("Failed to restore database", "backup", input, "error", err)
+		return fmt.Errorf("failed to restore %s: %w", input, err)
+	}
+
+	fmt.Printf("Restored database from %s\n", input)
+	return nil
+}
+
+// HandleCheck runs the integrity and foreign key checks and fails if any problems are found
+func (h *DBHandler) HandleCheck(ctx context.Context, c *cli.Command) error {
+	problems, err := h.cache.CheckIntegrity()
+	if err != nil {
+		h.logger.Error("Failed to check database", "error", err)
+		return err
+	}
+
+	if len(problems) == 0 {
+		fmt.Printf("Database is healthy\n")
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("  %s\n", problem)
+	}
+	return fmt.Errorf("found %d problems in the database", len(problems))
+}
+
+// HandleVacuum rebuilds the database file and reports the space reclaimed
+func (h *DBHandler) HandleVacuum(ctx context.Context, c *cli.Command) error {
+	before, err := h.cache.GetDatabaseStats()
+	if err != nil {
+		return err
+	}
+	if err := h.cache.Vacuum(); err != nil {
+		h.logger.Error("Failed to vacuum database", "error", err)
+		return err
+	}
+	after, err := h.cache.GetDatabaseStats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Vacuumed database: %.2f MB -> %.2f MB\n",
+		float64(before.FileSize)/1024/1024, float64(after.FileSize)/1024/1024)
+	return nil
+}
+
+// HandleAnalyze refreshes the query planner statistics
+func (h *DBHandler) HandleAnalyze(ctx context.Context, c *cli.Command) error {
+	if err := h.cache.Analyze(); err != nil {
+		h.logger.Error("Failed to analyze database", "error", err)
+		return err
+	}
+
+	fmt.Printf("Updated query planner statistics\n")
+	return nil
+}
+
+// HandleStats shows the database's size and the size of each table
+func (h *DBHandler) HandleStats(ctx context.Context, c *cli.Command) error {
+	stats, err := h.cache.GetDatabaseStats()
+	if err != nil {
+		h.logger.Error("Failed to read database stats", "error", err)
+		return err
+	}
+
+	fmt.Printf("Database: %s\n", stats.Path)
+	fmt.Printf("  Schema version:  %d\n", stats.SchemaVersion)
+	fmt.Printf("  File size:       %.2f MB\n", float64(stats.FileSize)/1024/1024)
+	fmt.Printf("  Pages:           %d x %d bytes (%d free)\n", stats.PageCount, stats.PageSize, stats.FreePages)
+
+	fmt.Printf("\nTables:\n")
+	for _, table := range stats.Tables {
+		fmt.Printf("  %-22s %8d rows  %8.1f KB\n", table.Name, table.Rows, float64(table.Bytes)/1024)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+)
+
+// DupesHandler handles near-duplicate detection
+type DupesHandler struct {
+	cache  interfaces.WallpaperCache
+	logger *slog.Logger
+}
+
+// NewDupesHandler creates a new dupes handler
+func NewDupesHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *DupesHandler {
+	return &DupesHandler{
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// Handle processes the dupes command
+func (h *DupesHandler) Handle(ctx context.Context, c *cli.Command) error {
+	threshold := c.Int("threshold")
+	dryRun := c.Bool("dryRun")
+
+	if threshold < 0 || threshold > 64 {
+		return fmt.Errorf("threshold must be between 0 and 64")
+	}
+
+	updated, err := h.cache.BackfillPerceptualHashes()
+	if err != nil {
+		h.logger.Warn("Failed to backfill perceptual hashes", "error", err)
+	} else if updated > 0 {
+		h.logger.Info("Calculated missing perceptual hashes", "count", updated)
+	}
+
+	groups := h.cache.FindNearDuplicates(threshold)
+	if len(groups) == 0 {
+		fmt.Printf("No near-duplicate wallpapers found\n")
+		return nil
+	}
+
+	fmt.Printf("Found %d groups of near-duplicate wallpapers\n\n", len(groups))
+
+	var removed int
+	var freed int64
+	for i, group := range groups {
+		keep := wallhaven.BestCopy(group)
+		fmt.Printf("%d. Keeping %s (%s)\n", i+1, filepath.Base(keep.Path), describeCopy(keep))
+
+		for _, wallpaper := range group {
+			if wallpaper == keep {
+				continue
+			}
+			if dryRun {
+				fmt.Printf("   Would remove: %s (%s)\n", wallpaper.Path, describeCopy(wallpaper))
+			} else {
+				fmt.Printf("   Moving to trash: %s (%s)\n", wallpaper.Path, describeCopy(wallpaper))
+				if err := h.cache.MergeDuplicate(keep.ID, wallpaper.ID); err != nil {
+					h.logger.Error("Failed to remove duplicate", "error", err, "path", wallpaper.Path)
+					continue
+				}
+			}
+			removed++
+			freed += wallpaper.Size
+		}
+		fmt.Printf("\n")
+	}
+
+	if dryRun {
+		fmt.Printf("Would move %d wallpapers (%.2f MB) to the trash\n", removed, float64(freed)/1024/1024)
+		fmt.Printf("Run without --dryRun to actually remove these wallpapers\n")
+	} else {
+		fmt.Printf("Moved %d wallpapers (%.2f MB) to the trash; run 'trash empty' to free it now\n", removed, float64(freed)/1024/1024)
+	}
+
+	return nil
+}
+
+// describeCopy summarises the attributes used to pick which duplicate to keep
+func describeCopy(wallpaper *wallhaven.WallpaperMetadata) string {
+	desc := wallpaper.Resolution
+	if desc == "" {
+		desc = "unknown resolution"
+	}
+	if wallpaper.IsFavorite {
+		desc += ", favorite"
+	}
+	if wallpaper.Rating > 0 {
+		desc += fmt.Sprintf(", rated %d", wallpaper.Rating)
+	}
+	return desc
+}
+
+// GetFlags returns the CLI flags for the dupes command
+func (h *DupesHandler) GetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:    "threshold",
+			Aliases: []string{"t"},
+			Value:   constants.DefaultSimilarityThreshold,
+			Usage:   "Maximum number of differing perceptual hash bits (0-64) for wallpapers to count as duplicates",
+		},
+		&cli.BoolFlag{
+			Name:  "dryRun",
+			Value: false,
+			Usage: "Show which duplicates would be removed without actually removing them",
+		},
+	}
+}
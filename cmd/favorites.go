@@ -11,23 +11,28 @@ import (
 	"github.com/urfave/cli/v3"
 
 	"git.asdf.cafe/abs3nt/wallhaven_dl/config"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/executor"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
 )
 
 // FavoritesHandler handles favorites-related commands
 type FavoritesHandler struct {
-	cache    interfaces.WallpaperCache
-	executor interfaces.ScriptExecutor
-	logger   *slog.Logger
+	cache     interfaces.WallpaperCache
+	validator interfaces.Validator
+	executor  interfaces.ScriptExecutor
+	logger    *slog.Logger
 }
 
 // NewFavoritesHandler creates a new favorites handler
 func NewFavoritesHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *FavoritesHandler {
 	return &FavoritesHandler{
-		cache:    cache,
-		executor: executor.NewScriptExecutor(logger),
-		logger:   logger,
+		cache:     cache,
+		validator: validator.NewValidator(),
+		executor:  executor.NewScriptExecutor(logger),
+		logger:    logger,
 	}
 }
 
@@ -62,6 +67,15 @@ func (h *FavoritesHandler) HandleAdd(ctx context.Context, c *cli.Command) error
 // HandleList lists all favorite wallpapers
 func (h *FavoritesHandler) HandleList(ctx context.Context, c *cli.Command) error {
 	favorites := h.cache.GetFavorites()
+
+	out, err := newRenderer(c)
+	if err != nil {
+		return err
+	}
+	if !out.text() {
+		return out.render(favorites)
+	}
+
 	if len(favorites) == 0 {
 		fmt.Printf("No favorite wallpapers found\n")
 		return nil
@@ -88,19 +102,28 @@ func (h *FavoritesHandler) HandleList(ctx context.Context, c *cli.Command) error
 	return nil
 }
 
-// HandleRandom sets a random favorite as wallpaper
+// HandleRandom sets a random favorite as wallpaper. With --shuffle it draws from
+// a persistent shuffle bag instead, so every wallpaper of the source is shown
+// once before any repeats.
 func (h *FavoritesHandler) HandleRandom(ctx context.Context, c *cli.Command) error {
-	favorite := h.cache.GetRandomFavorite()
-	if favorite == nil {
-		fmt.Printf("No favorite wallpapers found\n")
-		return fmt.Errorf("no favorite wallpapers available")
+	var favorite *wallhaven.WallpaperMetadata
+	if c.Bool("shuffle") {
+		var err error
+		if favorite, err = h.nextShuffled(c); err != nil {
+			return err
+		}
+		fmt.Printf("Setting shuffled wallpaper: %s\n", filepath.Base(favorite.Path))
+	} else {
+		if favorite = h.cache.GetRandomFavorite(); favorite == nil {
+			fmt.Printf("No favorite wallpapers found\n")
+			return fmt.Errorf("no favorite wallpapers available")
+		}
+		fmt.Printf("Setting random favorite wallpaper: %s\n", filepath.Base(favorite.Path))
 	}
 
-	fmt.Printf("Setting random favorite wallpaper: %s\n", filepath.Base(favorite.Path))
-
 	scriptPath := c.String("scriptPath")
 	if scriptPath != "" {
-		if err := h.executor.Execute(scriptPath, favorite.Path); err != nil {
+		if err := h.executor.Execute(scriptPath, applyPath(c, h.cache, h.logger, favorite)); err != nil {
 			return err
 		}
 	}
@@ -117,6 +140,35 @@ func (h *FavoritesHandler) HandleRandom(ctx context.Context, c *cli.Command) err
 	return nil
 }
 
+// nextShuffled draws the next wallpaper from the shuffle bag of the source named
+// by --source and --tags
+func (h *FavoritesHandler) nextShuffled(c *cli.Command) (*wallhaven.WallpaperMetadata, error) {
+	source := c.String("source")
+	if err := h.validator.ValidateSource(source); err != nil {
+		return nil, err
+	}
+	var tags []string
+	if source == constants.SourceTags {
+		tags = c.StringSlice("tags")
+	}
+
+	wallpapers, err := loadSource(h.cache, source, tags, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(wallpapers) == 0 {
+		return nil, fmt.Errorf("no %s wallpapers to shuffle", source)
+	}
+
+	bag := wallhaven.ShuffleBagKey(source, tags)
+	if c.Bool("reshuffle") {
+		if err := h.cache.ResetShuffle(bag); err != nil {
+			return nil, err
+		}
+	}
+	return h.cache.NextShuffled(bag, wallpapers, c.Bool("weighted"))
+}
+
 // GetCommonFlags returns common flags for favorites commands
 func (h *FavoritesHandler) GetCommonFlags() []cli.Flag {
 	return []cli.Flag{
@@ -133,12 +185,37 @@ func (h *FavoritesHandler) GetCommonFlags() []cli.Flag {
 // GetRandomFlags returns flags for the random favorites command
 func (h *FavoritesHandler) GetRandomFlags() []cli.Flag {
 	flags := h.GetCommonFlags()
-	flags = append(flags, &cli.StringFlag{
-		Name:      "scriptPath",
-		Aliases:   []string{"sp"},
-		Value:     "",
-		TakesFile: true,
-		Usage:     "Path to the script to run after switching",
-	})
+	flags = append(flags,
+		&cli.StringFlag{
+			Name:      "scriptPath",
+			Aliases:   []string{"sp"},
+			Value:     "",
+			TakesFile: true,
+			Usage:     "Path to the script to run after switching",
+		},
+		&cli.BoolFlag{
+			Name:  "shuffle",
+			Usage: "Rotate through the source without repeats instead of picking uniformly",
+		},
+		&cli.StringFlag{
+			Name:    "source",
+			Aliases: []string{"s"},
+			Value:   constants.SourceFavorites,
+			Usage:   "Wallpapers to shuffle: " + strings.Join(constants.ValidSources, ", "),
+		},
+		&cli.StringSliceFlag{
+			Name:    "tags",
+			Aliases: []string{"t"},
+			Usage:   "Tags to shuffle when source is tags",
+		},
+		&cli.BoolFlag{
+			Name:  "weighted",
+			Usage: "Bring higher rated wallpapers up earlier in each shuffled round",
+		},
+		&cli.BoolFlag{
+			Name:  "reshuffle",
+			Usage: "Start a new shuffled round",
+		},
+	)
 	return flags
 }
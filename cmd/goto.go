@@ -0,0 +1,77 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/executor"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+)
+
+// GotoHandler switches straight to a given wallpaper
+type GotoHandler struct {
+	cache    interfaces.WallpaperCache
+	executor interfaces.ScriptExecutor
+	logger   *slog.Logger
+}
+
+// NewGotoHandler creates a new goto handler
+func NewGotoHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *GotoHandler {
+	return &GotoHandler{
+		cache:    cache,
+		executor: executor.NewScriptExecutor(logger),
+		logger:   logger,
+	}
+}
+
+// Handle processes the goto command
+func (h *GotoHandler) Handle(ctx context.Context, c *cli.Command) error {
+	ref := c.Args().First()
+	if ref == "" {
+		return fmt.Errorf("a wallpaper ID or path is required")
+	}
+
+	wallpaper := findWallpaper(h.cache, ref)
+	if wallpaper == nil {
+		return fmt.Errorf("wallpaper not found: %s", ref)
+	}
+
+	h.logger.Info("Switching to wallpaper", "path", wallpaper.Path)
+
+	scriptPath := c.String("scriptPath")
+	if scriptPath != "" {
+		if err := h.executor.Execute(scriptPath, applyPath(c, h.cache, h.logger, wallpaper)); err != nil {
+			return err
+		}
+	}
+
+	// Wallpapers that were never shown have no place in history yet, so record the
+	// use or previous and next would have nothing to step from
+	if wallpaper.UseCount == 0 {
+		if err := h.cache.MarkAsUsed(wallpaper.ID); err != nil {
+			h.logger.Warn("Failed to mark wallpaper as used", "error", err)
+		}
+	}
+
+	if err := h.cache.SetCurrentView(wallpaper.ID); err != nil {
+		h.logger.Warn("Failed to update current view", "error", err)
+	}
+
+	return nil
+}
+
+// GetFlags returns the CLI flags for the goto command
+func (h *GotoHandler) GetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      "scriptPath",
+			Aliases:   []string{"sp"},
+			TakesFile: true,
+			Usage:     "Path to the script to run after switching",
+		},
+	}
+}
@@ -0,0 +1,188 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+)
+
+// currentWallpaper returns the wallpaper currently on screen. It prefers the
+// view state (which follows previous/next) and falls back to the most recently used.
+func currentWallpaper(cache interfaces.WallpaperCache) *wallhaven.WallpaperMetadata {
+	if id := cache.GetCurrentView(); id != "" {
+		if current := cache.GetByID(id); current != nil {
+			return current
+		}
+	}
+	return cache.GetCurrent()
+}
+
+// loadSource returns up to limit cached wallpapers from one of constants.ValidSources
+func loadSource(cache interfaces.WallpaperCache, source string, tags []string, limit int) ([]*wallhaven.WallpaperMetadata, error) {
+	var wallpapers []*wallhaven.WallpaperMetadata
+	switch source {
+	case constants.SourceFavorites:
+		wallpapers = cache.GetFavorites()
+	case constants.SourceHistory:
+		wallpapers = cache.GetHistory(limit)
+	case constants.SourceTags:
+		if len(tags) == 0 {
+			return nil, fmt.Errorf("at least one tag is required for source %q", source)
+		}
+		wallpapers = cache.GetByTags(tags)
+	case constants.SourceLibrary:
+		wallpapers = cache.GetAll()
+	default:
+		return nil, fmt.Errorf("invalid source: %s", source)
+	}
+
+	if limit > 0 && len(wallpapers) > limit {
+		wallpapers = wallpapers[:limit]
+	}
+	return wallpapers, nil
+}
+
+// matchesQuery reports whether every word of query appears in the wallpaper's
+// name, ID, resolution or tags, ignoring case
+func matchesQuery(wallpaper *wallhaven.WallpaperMetadata, query string) bool {
+	haystack := strings.ToLower(strings.Join(append([]string{
+		filepath.Base(wallpaper.Path), wallpaper.ID, wallpaper.Resolution,
+	}, wallpaper.Tags...), " "))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(haystack, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// displayID returns the wallhaven ID of a wallpaper when its file name carries one,
+// falling back to the cache ID
+func displayID(wallpaper *wallhaven.WallpaperMetadata) string {
+	if id := wallhaven.WallhavenID(wallpaper.Path); id != "" {
+		return id
+	}
+	return wallpaper.ID
+}
+
+// findWallpaper looks a wallpaper up by cache ID, wallhaven ID or file path
+func findWallpaper(cache interfaces.WallpaperCache, ref string) *wallhaven.WallpaperMetadata {
+	if wallpaper := cache.GetByID(ref); wallpaper != nil {
+		return wallpaper
+	}
+	if absPath, err := filepath.Abs(ref); err == nil {
+		if wallpaper := cache.GetByPath(absPath); wallpaper != nil {
+			return wallpaper
+		}
+	}
+	for _, wallpaper := range cache.GetAll() {
+		if wallhaven.WallhavenID(wallpaper.Path) == ref {
+			return wallpaper
+		}
+	}
+	return nil
+}
+
+// applyPath returns the file to hand to the apply script: a variant fitted to the
+// resolution given by the global fitTo flag, or the original when fitting is disabled
+// or fails
+func applyPath(c *cli.Command, cache interfaces.WallpaperCache, logger *slog.Logger, wallpaper *wallhaven.WallpaperMetadata) string {
+	width, height, ok := wallhaven.ParseResolution(c.String("fitTo"))
+	if !ok {
+		return wallpaper.Path
+	}
+
+	variant, err := cache.GetDisplayVariant(wallpaper, width, height, c.String("cropMode"))
+	if err != nil {
+		logger.Warn("Failed to create display variant, using original", "path", wallpaper.Path, "error", err)
+		return wallpaper.Path
+	}
+	return variant
+}
+
+// parseDuration parses a duration that may use the d, w, M and y units in
+// addition to those time.ParseDuration understands (e.g. "30d", "2w")
+func parseDuration(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid duration format")
+	}
+
+	unit := s[len(s)-1:]
+	valueStr := s[:len(s)-1]
+
+	value, err := time.ParseDuration(valueStr + "h")
+	if err != nil {
+		return 0, err
+	}
+
+	switch unit {
+	case "d":
+		return value * 24, nil
+	case "w":
+		return value * 24 * 7, nil
+	case "M":
+		return value * 24 * 30, nil
+	case "y":
+		return value * 24 * 365, nil
+	default:
+		return time.ParseDuration(s)
+	}
+}
+
+// parseTimeBound parses a --since/--until value: an RFC 3339 timestamp, a date
+// (2006-01-02) in local time, or a duration such as "7d" meaning that long ago.
+// An empty value returns the zero time, leaving that end of the range open.
+func parseTimeBound(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	duration, err := parseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: expected a date, RFC 3339 timestamp or duration like 7d", s)
+	}
+	return time.Now().Add(-duration), nil
+}
+
+// timeRangeFlags returns the --since and --until flags shared by commands that
+// accept a time window
+func timeRangeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "Only include activity after this date, timestamp or duration ago (e.g. '2025-01-01', '7d')",
+		},
+		&cli.StringFlag{
+			Name:  "until",
+			Usage: "Only include activity before this date, timestamp or duration ago",
+		},
+	}
+}
+
+// parseTimeRange reads the --since and --until flags
+func parseTimeRange(c *cli.Command) (since, until time.Time, err error) {
+	if since, err = parseTimeBound(c.String("since")); err != nil {
+		return since, until, err
+	}
+	if until, err = parseTimeBound(c.String("until")); err != nil {
+		return since, until, err
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		return since, until, fmt.Errorf("--until must not be before --since")
+	}
+	return since, until, nil
+}
@@ -12,9 +12,12 @@ import (
 	"strings"
 
 	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
 
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/executor"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
 )
 
 // HistoryHandler handles history browsing
@@ -35,19 +38,47 @@ func NewHistoryHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *Hi
 
 // Handle processes the history command
 func (h *HistoryHandler) Handle(ctx context.Context, c *cli.Command) error {
-	history := h.cache.GetHistory(50)
+	history, err := h.load(c)
+	if err != nil {
+		return err
+	}
+
+	limit := int(c.Int("limit"))
+	offset := int(c.Int("offset"))
+	if limit < 0 || offset < 0 {
+		return fmt.Errorf("limit and offset must not be negative")
+	}
+	page := history[min(offset, len(history)):]
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+	}
+
+	out, err := newRenderer(c)
+	if err != nil {
+		return err
+	}
+	if !out.text() {
+		return out.render(page)
+	}
 
-	if len(history) == 0 {
+	if len(page) == 0 {
 		fmt.Println("No wallpaper history found.")
-		fmt.Println("Use 'search' to download some wallpapers first!")
+		if len(history) == 0 && c.String("filter") == "" && !c.IsSet("since") && !c.IsSet("until") {
+			fmt.Println("Use 'search' to download some wallpapers first!")
+		}
 		return nil
 	}
 
-	fmt.Printf("\n📜 Wallpaper History (last %d)\n", len(history))
+	if offset > 0 {
+		fmt.Printf("\n📜 Wallpaper History (%d-%d of %d)\n", offset+1, offset+len(page), len(history))
+	} else {
+		fmt.Printf("\n📜 Wallpaper History (last %d)\n", len(page))
+	}
 	fmt.Println(strings.Repeat("=", 80))
 
-	for i, wp := range history {
-		fmt.Printf("\n%d. %s\n", i+1, filepath.Base(wp.Path))
+	for i, wp := range page {
+		// Numbers count from the top of the whole list so they work with 'history apply'
+		fmt.Printf("\n%d. %s\n", offset+i+1, filepath.Base(wp.Path))
 		fmt.Printf("   Resolution: %s\n", wp.Resolution)
 		fmt.Printf("   Used: %d times", wp.UseCount)
 
@@ -66,9 +97,9 @@ func (h *HistoryHandler) Handle(ctx context.Context, c *cli.Command) error {
 
 	fmt.Println()
 
-	// Interactive selection
+	// Interactive selection, only when someone is at the terminal to answer
 	scriptPath := c.String("scriptPath")
-	if scriptPath == "" {
+	if scriptPath == "" || !term.IsTerminal(int(os.Stdin.Fd())) {
 		return nil
 	}
 
@@ -83,17 +114,73 @@ func (h *HistoryHandler) Handle(ctx context.Context, c *cli.Command) error {
 	}
 
 	selection, err := strconv.Atoi(input)
-	if err != nil || selection < 1 || selection > len(history) {
+	if err != nil || selection <= offset || selection > offset+len(page) {
 		return fmt.Errorf("invalid selection: %s", input)
 	}
 
-	selected := history[selection-1]
-	fmt.Printf("Applying wallpaper: %s\n", filepath.Base(selected.Path))
+	return h.apply(c, history[selection-1])
+}
+
+// HandleApply applies a history entry given by its number in the listing or by ID
+func (h *HistoryHandler) HandleApply(ctx context.Context, c *cli.Command) error {
+	ref := c.Args().First()
+	if ref == "" {
+		return fmt.Errorf("a history number or wallpaper ID is required")
+	}
 
-	if err := h.executor.Execute(scriptPath, selected.Path); err != nil {
+	history, err := h.load(c)
+	if err != nil {
 		return err
 	}
 
+	var selected *wallhaven.WallpaperMetadata
+	if index, err := strconv.Atoi(ref); err == nil {
+		if index < 1 || index > len(history) {
+			return fmt.Errorf("history entry %d does not exist, there are %d", index, len(history))
+		}
+		selected = history[index-1]
+	} else {
+		for _, wp := range history {
+			if wp.ID == ref || wallhaven.WallhavenID(wp.Path) == ref {
+				selected = wp
+				break
+			}
+		}
+		if selected == nil {
+			return fmt.Errorf("wallpaper %s is not in the history", ref)
+		}
+	}
+
+	return h.apply(c, selected)
+}
+
+// load returns the history matching the since, until and filter flags
+func (h *HistoryHandler) load(c *cli.Command) ([]*wallhaven.WallpaperMetadata, error) {
+	since, until, err := parseTimeRange(c)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := c.String("filter")
+	var history []*wallhaven.WallpaperMetadata
+	for _, wp := range h.cache.GetHistoryBetween(since, until) {
+		if matchesQuery(wp, filter) {
+			history = append(history, wp)
+		}
+	}
+	return history, nil
+}
+
+// apply runs the script on a history entry and makes it the current view
+func (h *HistoryHandler) apply(c *cli.Command, selected *wallhaven.WallpaperMetadata) error {
+	fmt.Printf("Applying wallpaper: %s\n", filepath.Base(selected.Path))
+
+	if scriptPath := c.String("scriptPath"); scriptPath != "" {
+		if err := h.executor.Execute(scriptPath, applyPath(c, h.cache, h.logger, selected)); err != nil {
+			return err
+		}
+	}
+
 	// Update view state
 	if err := h.cache.SetCurrentView(selected.ID); err != nil {
 		h.logger.Warn("Failed to update current view", "error", err)
@@ -103,9 +190,14 @@ func (h *HistoryHandler) Handle(ctx context.Context, c *cli.Command) error {
 	return nil
 }
 
-// GetFlags returns the CLI flags for the history command
-func (h *HistoryHandler) GetFlags() []cli.Flag {
-	return []cli.Flag{
+// filterFlags returns the flags that choose which history entries are listed
+func (h *HistoryHandler) filterFlags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:    "filter",
+			Aliases: []string{"q"},
+			Usage:   "Only include wallpapers whose name, ID, resolution or tags contain every word",
+		},
 		&cli.StringFlag{
 			Name:      "scriptPath",
 			Aliases:   []string{"sp"},
@@ -114,4 +206,26 @@ func (h *HistoryHandler) GetFlags() []cli.Flag {
 			Usage:     "Path to the script to run after selecting a wallpaper",
 		},
 	}
+	return append(flags, timeRangeFlags()...)
+}
+
+// GetFlags returns the CLI flags for the history command
+func (h *HistoryHandler) GetFlags() []cli.Flag {
+	return append(h.filterFlags(),
+		&cli.IntFlag{
+			Name:    "limit",
+			Aliases: []string{"l"},
+			Value:   constants.DefaultListLimit,
+			Usage:   "Maximum number of entries to list, 0 for all",
+		},
+		&cli.IntFlag{
+			Name:  "offset",
+			Usage: "Number of entries to skip from the most recent",
+		},
+	)
+}
+
+// GetApplyFlags returns the CLI flags for the history apply command
+func (h *HistoryHandler) GetApplyFlags() []cli.Flag {
+	return h.filterFlags()
 }
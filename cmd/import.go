@@ -0,0 +1,281 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/config"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+)
+
+// Import transfer modes
+const (
+	importInPlace = iota
+	importCopy
+	importMove
+)
+
+// ImportHandler handles importing existing image folders into the cache
+type ImportHandler struct {
+	cache  interfaces.WallpaperCache
+	logger *slog.Logger
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *ImportHandler {
+	return &ImportHandler{
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// importResult describes what happened to a single file during an import
+type importResult int
+
+const (
+	importAdded importResult = iota
+	importDuplicate
+	importExists
+	importConflict
+)
+
+// Handle processes the import command
+func (h *ImportHandler) Handle(ctx context.Context, c *cli.Command) error {
+	dir := c.Args().First()
+	if dir == "" {
+		return fmt.Errorf("a directory to import is required")
+	}
+	if c.Bool("copy") && c.Bool("move") {
+		return fmt.Errorf("--copy and --move cannot be used together")
+	}
+
+	mode := importInPlace
+	switch {
+	case c.Bool("copy"):
+		mode = importCopy
+	case c.Bool("move"):
+		mode = importMove
+	}
+	downloadPath := c.String("downloadPath")
+	recursive := c.Bool("recursive")
+	dryRun := c.Bool("dryRun")
+
+	files, err := findImages(dir, recursive)
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	if len(files) == 0 {
+		fmt.Printf("No images found in %s\n", dir)
+		return nil
+	}
+
+	counts := make(map[importResult]int)
+	var failed int
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		result, err := h.importFile(file, downloadPath, mode, dryRun)
+		if err != nil {
+			h.logger.Warn("Failed to import wallpaper", "path", file, "error", err)
+			failed++
+			continue
+		}
+		counts[result]++
+	}
+
+	verb := "Imported"
+	if dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d of %d images from %s\n", verb, counts[importAdded], len(files), dir)
+	if n := counts[importDuplicate]; n > 0 {
+		fmt.Printf("  Skipped %d already cached images\n", n)
+	}
+	if n := counts[importExists]; n > 0 {
+		fmt.Printf("  Skipped %d wallpapers already cached under another file\n", n)
+	}
+	if n := counts[importConflict]; n > 0 {
+		fmt.Printf("  Skipped %d images whose name is taken in %s\n", n, downloadPath)
+	}
+	if failed > 0 {
+		fmt.Printf("  Failed to import %d images\n", failed)
+	}
+	return nil
+}
+
+// importFile registers a single image, first copying or moving it into downloadPath
+// when requested. Images already in the cache, by content or by wallhaven ID, are skipped.
+func (h *ImportHandler) importFile(file, downloadPath string, mode int, dryRun bool) (importResult, error) {
+	hash, _, err := wallhaven.CalculateFileHash(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate hash: %w", err)
+	}
+	if duplicate := h.cache.FindDuplicate(hash); duplicate != nil {
+		h.logger.Debug("Skipping cached image", "path", file, "existing", duplicate.Path)
+		return importDuplicate, nil
+	}
+
+	sourceURL := wallhaven.SourceURL(file)
+	if mode != importInPlace {
+		// file:// source URLs should point at where the image will live
+		dest := filepath.Join(downloadPath, filepath.Base(file))
+		if !samePath(file, dest) {
+			if _, err := os.Stat(dest); err == nil {
+				h.logger.Warn("Skipping image, name already taken in download directory", "path", file, "existing", dest)
+				return importConflict, nil
+			}
+			if wallhaven.WallhavenID(file) == "" {
+				sourceURL = wallhaven.SourceURL(dest)
+			}
+		}
+	}
+
+	if existing := h.cache.GetByID(wallhaven.GenerateID(sourceURL)); existing != nil {
+		h.logger.Debug("Skipping image, wallpaper already cached", "path", file, "existing", existing.Path)
+		return importExists, nil
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return 0, err
+	}
+
+	if dryRun {
+		fmt.Printf("Would import: %s\n", file)
+		return importAdded, nil
+	}
+
+	if mode != importInPlace {
+		dest := filepath.Join(downloadPath, filepath.Base(file))
+		if !samePath(file, dest) {
+			if err := transferFile(file, dest, mode == importMove); err != nil {
+				return 0, err
+			}
+			file = dest
+		}
+	}
+
+	if _, err := h.cache.ImportWallpaper(file, sourceURL, info.ModTime()); err != nil {
+		return 0, err
+	}
+	fmt.Printf("Imported: %s\n", file)
+	return importAdded, nil
+}
+
+// findImages returns the image files in dir, descending into subdirectories when
+// recursive is set. Hidden directories such as the cache are always skipped.
+func findImages(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (!recursive || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && wallhaven.IsImageFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// samePath reports whether two paths refer to the same location
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// transferFile copies src to dst, removing src afterwards when move is set.
+// Moves are done with a rename where possible.
+func transferFile(src, dst string, move bool) error {
+	if err := os.MkdirAll(filepath.Dir(dst), constants.DirPermissions); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if move {
+		err := os.Rename(src, dst)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, syscall.EXDEV) {
+			return fmt.Errorf("failed to move file: %w", err)
+		}
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, constants.FilePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	if move {
+		return os.Remove(src)
+	}
+	return nil
+}
+
+// GetFlags returns the CLI flags for the import command
+func (h *ImportHandler) GetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      "downloadPath",
+			Aliases:   []string{"dp"},
+			Value:     config.GetDefaultDownloadPath(),
+			TakesFile: true,
+			Usage:     "Absolute path to download directory to copy or move images into",
+		},
+		&cli.BoolFlag{
+			Name:    "recursive",
+			Aliases: []string{"r"},
+			Usage:   "Import images in subdirectories too",
+		},
+		&cli.BoolFlag{
+			Name:  "copy",
+			Usage: "Copy images into the download directory instead of registering them in place",
+		},
+		&cli.BoolFlag{
+			Name:  "move",
+			Usage: "Move images into the download directory instead of registering them in place",
+		},
+		&cli.BoolFlag{
+			Name:  "dryRun",
+			Value: false,
+			Usage: "Show which images would be imported without importing them",
+		},
+	}
+}
@@ -0,0 +1,171 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/config"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
+)
+
+// LibraryHandler handles exporting and importing the wallpaper library
+type LibraryHandler struct {
+	cache     interfaces.WallpaperCache
+	validator interfaces.Validator
+	logger    *slog.Logger
+}
+
+// NewLibraryHandler creates a new library handler
+func NewLibraryHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *LibraryHandler {
+	return &LibraryHandler{
+		cache:     cache,
+		validator: validator.NewValidator(),
+		logger:    logger,
+	}
+}
+
+// HandleExport writes the library to a file, or stdout when the output is "-"
+func (h *LibraryHandler) HandleExport(ctx context.Context, c *cli.Command) error {
+	output := c.String("output")
+	format, err := h.libraryFormat(c, output)
+	if err != nil {
+		return err
+	}
+
+	library, err := h.cache.ExportLibrary()
+	if err != nil {
+		h.logger.Error("Failed to export library", "error", err)
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := wallhaven.WriteLibrary(w, library, format); err != nil {
+		return fmt.Errorf("failed to write library: %w", err)
+	}
+
+	if output != "-" {
+		fmt.Printf("Exported %d wallpapers to %s\n", len(library.Wallpapers), output)
+	}
+	return nil
+}
+
+// HandleImport merges a library document into the cache
+func (h *LibraryHandler) HandleImport(ctx context.Context, c *cli.Command) error {
+	input := c.Args().First()
+	if input == "" {
+		return fmt.Errorf("a library file to import is required")
+	}
+
+	format, err := h.libraryFormat(c, input)
+	if err != nil {
+		return err
+	}
+	policy := c.String("policy")
+	if err := h.validator.ValidateMergePolicy(policy); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", input, err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	library, err := wallhaven.ReadLibrary(r, format)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.cache.MergeLibrary(library, policy, []string{c.String("downloadPath")})
+	if result != nil {
+		fmt.Printf("Added %d and merged %d of %d wallpapers\n", result.Added, result.Merged, len(library.Wallpapers))
+		if result.Missing > 0 {
+			fmt.Printf("  Skipped %d wallpapers whose files weren't found\n", result.Missing)
+		}
+	}
+	if err != nil {
+		h.logger.Error("Failed to import library", "error", err)
+		return err
+	}
+	return nil
+}
+
+// libraryFormat returns the --format flag if set, otherwise the format implied by
+// the file extension, defaulting to JSON
+func (h *LibraryHandler) libraryFormat(c *cli.Command, path string) (string, error) {
+	if c.IsSet("format") {
+		format := c.String("format")
+		return format, h.validator.ValidateLibraryFormat(format)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return constants.LibraryFormatCSV, nil
+	}
+	return constants.LibraryFormatJSON, nil
+}
+
+func (h *LibraryHandler) formatFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"f"},
+		Value:   constants.LibraryFormatJSON,
+		Usage:   "Document format: " + strings.Join(constants.ValidLibraryFormats, ", ") + " (default from the file extension)",
+	}
+}
+
+// GetExportFlags returns the CLI flags for the library export command
+func (h *LibraryHandler) GetExportFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      "output",
+			Aliases:   []string{"o"},
+			Value:     "-",
+			TakesFile: true,
+			Usage:     "File to write the library to, - for stdout",
+		},
+		h.formatFlag(),
+	}
+}
+
+// GetImportFlags returns the CLI flags for the library import command
+func (h *LibraryHandler) GetImportFlags() []cli.Flag {
+	return []cli.Flag{
+		h.formatFlag(),
+		&cli.StringFlag{
+			Name:    "policy",
+			Aliases: []string{"p"},
+			Value:   constants.MergePolicyKeepNewer,
+			Usage:   "Whose rating and favourite status win for wallpapers in both libraries: " + strings.Join(constants.ValidMergePolicies, ", "),
+		},
+		&cli.StringFlag{
+			Name:      "downloadPath",
+			Aliases:   []string{"dp"},
+			Value:     config.GetDefaultDownloadPath(),
+			TakesFile: true,
+			Usage:     "Directory to look for wallpaper files in when they aren't at their recorded path",
+		},
+	}
+}
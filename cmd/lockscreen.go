@@ -0,0 +1,109 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/config"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
+)
+
+// LockscreenHandler handles lockscreen image generation
+type LockscreenHandler struct {
+	cache  interfaces.WallpaperCache
+	logger *slog.Logger
+}
+
+// NewLockscreenHandler creates a new lockscreen handler
+func NewLockscreenHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *LockscreenHandler {
+	return &LockscreenHandler{
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// Handle processes the lockscreen command
+func (h *LockscreenHandler) Handle(ctx context.Context, c *cli.Command) error {
+	current := currentWallpaper(h.cache)
+	if current == nil {
+		fmt.Printf("No current wallpaper found\n")
+		return fmt.Errorf("no current wallpaper available")
+	}
+
+	effects := wallhaven.LockscreenEffects{
+		Blur:     c.Int("blur"),
+		Dim:      c.Int("dim"),
+		Pixelate: c.Int("pixelate"),
+	}
+
+	output := c.String("output")
+	if err := wallhaven.CreateLockscreen(current.Path, output, effects); err != nil {
+		h.logger.Error("Failed to create lockscreen", "error", err)
+		return err
+	}
+
+	fmt.Printf("Generated lockscreen from %s: %s\n", filepath.Base(current.Path), output)
+	return nil
+}
+
+// HandleViewChange regenerates the default lockscreen image for a newly viewed
+// wallpaper using the default effects. It is registered as a cache view hook when
+// automatic lockscreen generation is enabled.
+func (h *LockscreenHandler) HandleViewChange(wallpaperID string) {
+	wallpaper := h.cache.GetByID(wallpaperID)
+	if wallpaper == nil {
+		h.logger.Warn("Cannot regenerate lockscreen, wallpaper not found", "id", wallpaperID)
+		return
+	}
+
+	effects := wallhaven.LockscreenEffects{
+		Blur: constants.DefaultLockscreenBlur,
+		Dim:  constants.DefaultLockscreenDim,
+	}
+	if err := wallhaven.CreateLockscreen(wallpaper.Path, config.GetDefaultLockscreenPath(), effects); err != nil {
+		h.logger.Warn("Failed to regenerate lockscreen", "error", err)
+		return
+	}
+	h.logger.Info("Regenerated lockscreen", "wallpaper", wallpaper.Path)
+}
+
+// GetFlags returns the CLI flags for the lockscreen command
+func (h *LockscreenHandler) GetFlags() []cli.Flag {
+	v := validator.NewValidator()
+
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:    "blur",
+			Aliases: []string{"b"},
+			Value:   constants.DefaultLockscreenBlur,
+			Usage:   "Blur radius in pixels, 0 to disable",
+		},
+		&cli.IntFlag{
+			Name:      "dim",
+			Aliases:   []string{"d"},
+			Value:     constants.DefaultLockscreenDim,
+			Usage:     "Percentage to darken the image by",
+			Validator: v.ValidatePercentage,
+		},
+		&cli.IntFlag{
+			Name:    "pixelate",
+			Aliases: []string{"p"},
+			Usage:   "Pixelate into blocks of this many pixels, 0 to disable",
+		},
+		&cli.StringFlag{
+			Name:      "output",
+			Aliases:   []string{"o"},
+			Value:     config.GetDefaultLockscreenPath(),
+			TakesFile: true,
+			Usage:     "Path to write the lockscreen image to",
+		},
+	}
+}
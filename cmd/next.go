@@ -9,6 +9,7 @@ import (
 	"github.com/urfave/cli/v3"
 
 	"git.asdf.cafe/abs3nt/wallhaven_dl/config"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/executor"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
 )
@@ -31,7 +32,19 @@ func NewNextHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *NextH
 
 // Handle processes the next command
 func (h *NextHandler) Handle(ctx context.Context, c *cli.Command) error {
-	next := h.cache.GetNext()
+	steps := int(c.Int("steps"))
+	if steps < 1 {
+		return fmt.Errorf("steps must be at least 1")
+	}
+
+	// Step through the active playlist. A shuffled one can't step back, so history
+	// left ahead by previous is replayed before drawing again.
+	if playlist := activePlaylist(c, h.cache); playlist != nil &&
+		(playlist.Mode == constants.PlaylistModeSequential || h.cache.GetNext(steps) == nil) {
+		return playPlaylist(c, h.cache, h.executor, h.logger, playlist, steps)
+	}
+
+	next := h.cache.GetNext(steps)
 	if next == nil {
 		h.logger.Info("No next wallpaper found")
 		return fmt.Errorf("no next wallpaper available")
@@ -41,13 +54,13 @@ func (h *NextHandler) Handle(ctx context.Context, c *cli.Command) error {
 
 	scriptPath := c.String("scriptPath")
 	if scriptPath != "" {
-		if err := h.executor.Execute(scriptPath, next.Path); err != nil {
+		if err := h.executor.Execute(scriptPath, applyPath(c, h.cache, h.logger, next)); err != nil {
 			return err
 		}
 	}
 
-	// Update the current view to this wallpaper so next/previous calls work correctly
-	if err := h.cache.SetCurrentView(next.ID); err != nil {
+	// Step forward along the navigation stack
+	if _, err := h.cache.Navigate(steps); err != nil {
 		h.logger.Warn("Failed to update current view", "error", err)
 	}
 
@@ -71,5 +84,15 @@ func (h *NextHandler) GetFlags() []cli.Flag {
 			TakesFile: true,
 			Usage:     "Path to the script to run after switching",
 		},
+		&cli.IntFlag{
+			Name:    "steps",
+			Aliases: []string{"n"},
+			Value:   1,
+			Usage:   "How many wallpapers to go forward in history",
+		},
+		&cli.BoolFlag{
+			Name:  "history",
+			Usage: "Step forward through history even while a playlist is playing",
+		},
 	}
 }
@@ -0,0 +1,203 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// templateFuncs are available to --format templates in addition to the builtins
+var templateFuncs = template.FuncMap{
+	"base": filepath.Base,
+	"join": strings.Join,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// renderer writes command results in the mode chosen by the global --output and
+// --format flags. Handlers print their usual text when text() is true and hand
+// their data to render otherwise.
+type renderer struct {
+	mode     string
+	template *template.Template
+	w        io.Writer
+}
+
+// newRenderer creates a renderer from the global output flags
+func newRenderer(c *cli.Command) (*renderer, error) {
+	r := &renderer{mode: c.String("output"), w: os.Stdout}
+	if r.mode == "" {
+		r.mode = constants.OutputText
+	}
+
+	if format := c.String("format"); format != "" {
+		tmpl, err := template.New("format").Funcs(templateFuncs).Parse(format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid format template: %w", err)
+		}
+		r.template = tmpl
+	}
+	return r, nil
+}
+
+// text reports whether the handler should print its human-readable output
+func (r *renderer) text() bool {
+	return r.template == nil && r.mode == constants.OutputText
+}
+
+// render writes v, which is either a single record or a slice of records. A
+// --format template is applied to each record in turn and takes precedence over
+// --output.
+func (r *renderer) render(v any) error {
+	records, isList := splitRecords(v)
+	if isList && records == nil {
+		// Keep empty results as [] rather than null
+		v = []any{}
+	}
+
+	switch {
+	case r.template != nil:
+		for _, record := range records {
+			if err := r.template.Execute(r.w, record); err != nil {
+				return fmt.Errorf("failed to apply format template: %w", err)
+			}
+			fmt.Fprintln(r.w)
+		}
+		return nil
+	case r.mode == constants.OutputJSON:
+		encoder := json.NewEncoder(r.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case r.mode == constants.OutputJSONL:
+		encoder := json.NewEncoder(r.w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case r.mode == constants.OutputTSV:
+		return writeTSV(r.w, records)
+	default:
+		return fmt.Errorf("output mode %q has no structured form", r.mode)
+	}
+}
+
+// splitRecords returns the elements of v if it is a slice, or v itself otherwise
+func splitRecords(v any) ([]any, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return []any{v}, false
+	}
+
+	var records []any
+	for i := range rv.Len() {
+		records = append(records, rv.Index(i).Interface())
+	}
+	return records, true
+}
+
+// tsvColumn is a struct field written as a TSV column, named after its JSON key
+type tsvColumn struct {
+	name  string
+	index []int
+}
+
+// writeTSV writes records as tab-separated values with a header row. Columns
+// follow the JSON field names of the record type; records that aren't structs are
+// written as a single value column.
+func writeTSV(w io.Writer, records []any) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	recordType := reflect.TypeOf(records[0])
+	if recordType.Kind() == reflect.Pointer {
+		recordType = recordType.Elem()
+	}
+	if recordType.Kind() != reflect.Struct {
+		fmt.Fprintln(w, "value")
+		for _, record := range records {
+			fmt.Fprintln(w, tsvValue(reflect.ValueOf(record)))
+		}
+		return nil
+	}
+
+	columns := tsvColumns(recordType)
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+	fmt.Fprintln(w, strings.Join(names, "\t"))
+
+	for _, record := range records {
+		rv := reflect.Indirect(reflect.ValueOf(record))
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = tsvValue(rv.FieldByIndex(column.index))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return nil
+}
+
+// tsvColumns lists the exported fields of t, flattening embedded structs
+func tsvColumns(t reflect.Type) []tsvColumn {
+	var columns []tsvColumn
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, tsvColumn{name: name, index: field.Index})
+	}
+	return columns
+}
+
+// tsvValue formats a field for a TSV cell. Times use RFC 3339, string slices are
+// comma-separated and anything else non-scalar is written as compact JSON.
+func tsvValue(v reflect.Value) string {
+	switch value := v.Interface().(type) {
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return value.Format(time.RFC3339)
+	case []string:
+		return strings.Join(value, ",")
+	case string:
+		return strings.NewReplacer("\t", " ", "\n", " ").Replace(value)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface())
+	}
+
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,232 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/executor"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
+)
+
+// PickHandler lets the user choose a wallpaper through dmenu, rofi, fzf or a
+// similar menu program
+type PickHandler struct {
+	cache     interfaces.WallpaperCache
+	validator interfaces.Validator
+	executor  interfaces.ScriptExecutor
+	logger    *slog.Logger
+}
+
+// NewPickHandler creates a new pick handler
+func NewPickHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *PickHandler {
+	return &PickHandler{
+		cache:     cache,
+		validator: validator.NewValidator(),
+		executor:  executor.NewScriptExecutor(logger),
+		logger:    logger,
+	}
+}
+
+// Handle processes the pick command
+func (h *PickHandler) Handle(ctx context.Context, c *cli.Command) error {
+	source := c.String("source")
+	if err := h.validator.ValidateSource(source); err != nil {
+		return err
+	}
+	minRating := int(c.Int("minRating"))
+	if minRating > 0 {
+		if err := h.validator.ValidateRating(minRating); err != nil {
+			return err
+		}
+	}
+
+	limit := int(c.Int("limit"))
+	if limit <= 0 && source == constants.SourceHistory {
+		limit = constants.MaxHistorySize
+	}
+	wallpapers, err := loadSource(h.cache, source, c.StringSlice("tags"), 0)
+	if err != nil {
+		return err
+	}
+
+	query := c.String("query")
+	var candidates []*wallhaven.WallpaperMetadata
+	for _, wallpaper := range wallpapers {
+		if wallpaper.Rating >= minRating && matchesQuery(wallpaper, query) {
+			candidates = append(candidates, wallpaper)
+		}
+		if limit > 0 && len(candidates) == limit {
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no wallpapers to pick from")
+	}
+
+	entries := make(map[string]*wallhaven.WallpaperMetadata, len(candidates))
+	var input strings.Builder
+	for _, wallpaper := range candidates {
+		line := pickLine(wallpaper)
+		if _, ok := entries[line]; ok {
+			// Menus only hand back the text, so every entry has to be distinct
+			line += "  (" + wallpaper.ID + ")"
+		}
+		entries[line] = wallpaper
+
+		input.WriteString(line)
+		if c.Bool("icons") {
+			// rofi reads per-row options after a NUL byte
+			if thumb, err := h.cache.GetThumbnail(wallpaper); err == nil {
+				input.WriteString("\x00icon\x1f" + thumb)
+			} else {
+				h.logger.Debug("No thumbnail for menu icon", "id", wallpaper.ID, "error", err)
+			}
+		}
+		input.WriteByte('\n')
+	}
+
+	choice, err := runMenu(ctx, c.String("menu"), input.String())
+	if err != nil {
+		return err
+	}
+	if choice == "" {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+	selected, ok := entries[choice]
+	if !ok {
+		return fmt.Errorf("menu returned an unknown entry: %q", choice)
+	}
+
+	fmt.Printf("Applying wallpaper: %s\n", filepath.Base(selected.Path))
+
+	scriptPath := c.String("scriptPath")
+	if scriptPath != "" {
+		if err := h.executor.Execute(scriptPath, applyPath(c, h.cache, h.logger, selected)); err != nil {
+			return err
+		}
+	}
+
+	if err := h.cache.MarkAsUsed(selected.ID); err != nil {
+		h.logger.Warn("Failed to mark wallpaper as used", "error", err)
+	}
+
+	// Set this as the current view so 'previous' works correctly
+	if err := h.cache.SetCurrentView(selected.ID); err != nil {
+		h.logger.Warn("Failed to update current view", "error", err)
+	}
+
+	return nil
+}
+
+// pickLine summarises a wallpaper as one menu entry
+func pickLine(wallpaper *wallhaven.WallpaperMetadata) string {
+	parts := []string{displayID(wallpaper), filepath.Base(wallpaper.Path)}
+	if wallpaper.Resolution != "" {
+		parts = append(parts, wallpaper.Resolution)
+	}
+	if wallpaper.Rating > 0 {
+		parts = append(parts, strings.Repeat("★", wallpaper.Rating))
+	}
+	if wallpaper.IsFavorite {
+		parts = append(parts, "♥")
+	}
+	if len(wallpaper.Tags) > 0 {
+		parts = append(parts, "["+strings.Join(wallpaper.Tags, ", ")+"]")
+	}
+	return strings.Join(parts, "  ")
+}
+
+// runMenu feeds input to the menu program and returns the chosen line. Known menu
+// names expand to their usual command line; anything else is split on spaces and
+// run as is. An empty choice means the user cancelled.
+func runMenu(ctx context.Context, menu, input string) (string, error) {
+	args, ok := constants.MenuCommands[menu]
+	if !ok {
+		args = strings.Fields(menu)
+	}
+	if len(args) == 0 {
+		return "", fmt.Errorf("no menu program configured")
+	}
+
+	var stdout bytes.Buffer
+	menuCmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	menuCmd.Stdin = strings.NewReader(input)
+	menuCmd.Stdout = &stdout
+	// fzf draws its interface on stderr
+	menuCmd.Stderr = os.Stderr
+
+	if err := menuCmd.Run(); err != nil {
+		// Menus exit non-zero when dismissed without a choice
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stdout.Len() == 0 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to run menu %q: %w", args[0], err)
+	}
+
+	choice, _, _ := strings.Cut(stdout.String(), "\n")
+	return strings.TrimSuffix(choice, "\r"), nil
+}
+
+// GetFlags returns the CLI flags for the pick command
+func (h *PickHandler) GetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "source",
+			Aliases: []string{"s"},
+			Value:   constants.SourceHistory,
+			Usage:   "Wallpapers to pick from: " + strings.Join(constants.ValidSources, ", "),
+		},
+		&cli.StringSliceFlag{
+			Name:    "tags",
+			Aliases: []string{"t"},
+			Usage:   "Tags to pick from when source is tags",
+		},
+		&cli.StringFlag{
+			Name:    "query",
+			Aliases: []string{"q"},
+			Usage:   "Only list wallpapers whose name, ID, resolution or tags contain every word",
+		},
+		&cli.IntFlag{
+			Name:    "minRating",
+			Aliases: []string{"r"},
+			Usage:   "Only list wallpapers rated at least this",
+		},
+		&cli.IntFlag{
+			Name:    "limit",
+			Aliases: []string{"l"},
+			Usage:   "Maximum number of entries to list, 0 for all",
+		},
+		&cli.StringFlag{
+			Name:    "menu",
+			Aliases: []string{"m"},
+			Value:   constants.DefaultMenu,
+			Sources: cli.EnvVars("WH_MENU"),
+			Usage:   "Menu program: fzf, rofi, dmenu or a command line, split on spaces, that reads entries on stdin",
+		},
+		&cli.BoolFlag{
+			Name:  "icons",
+			Usage: "Send thumbnail icons with each entry (rofi)",
+		},
+		&cli.StringFlag{
+			Name:      "scriptPath",
+			Aliases:   []string{"sp"},
+			TakesFile: true,
+			Usage:     "Path to the script to run after picking a wallpaper",
+		},
+	}
+}
@@ -0,0 +1,353 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/executor"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
+)
+
+// PlaylistHandler handles named, ordered playlists of cached wallpapers
+type PlaylistHandler struct {
+	cache     interfaces.WallpaperCache
+	validator interfaces.Validator
+	executor  interfaces.ScriptExecutor
+	logger    *slog.Logger
+}
+
+// NewPlaylistHandler creates a new playlist handler
+func NewPlaylistHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *PlaylistHandler {
+	return &PlaylistHandler{
+		cache:     cache,
+		validator: validator.NewValidator(),
+		executor:  executor.NewScriptExecutor(logger),
+		logger:    logger,
+	}
+}
+
+// activePlaylist returns the playlist next and previous should step through, or
+// nil when none is playing or --history asks for plain history
+func activePlaylist(c *cli.Command, cache interfaces.WallpaperCache) *wallhaven.Playlist {
+	if c.Bool("history") {
+		return nil
+	}
+	return cache.GetActivePlaylist()
+}
+
+// playPlaylist moves a playlist the given number of steps and switches to the
+// wallpaper it lands on
+func playPlaylist(c *cli.Command, cache interfaces.WallpaperCache, exec interfaces.ScriptExecutor, logger *slog.Logger, playlist *wallhaven.Playlist, steps int) error {
+	wallpaper, err := cache.AdvancePlaylist(playlist.Name, steps)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Switching to playlist wallpaper", "playlist", playlist.Name, "path", wallpaper.Path)
+
+	scriptPath := c.String("scriptPath")
+	if scriptPath != "" {
+		if err := exec.Execute(scriptPath, applyPath(c, cache, logger, wallpaper)); err != nil {
+			return err
+		}
+	}
+
+	if err := cache.MarkAsUsed(wallpaper.ID); err != nil {
+		logger.Warn("Failed to mark wallpaper as used", "error", err)
+	}
+	if err := cache.SetCurrentView(wallpaper.ID); err != nil {
+		logger.Warn("Failed to update current view", "error", err)
+	}
+	return nil
+}
+
+// wallpaperIDs resolves wallpaper references to cache IDs, defaulting to the
+// current wallpaper when there are none
+func (h *PlaylistHandler) wallpaperIDs(refs []string) ([]string, error) {
+	if len(refs) == 0 {
+		current := currentWallpaper(h.cache)
+		if current == nil {
+			return nil, fmt.Errorf("no current wallpaper available")
+		}
+		return []string{current.ID}, nil
+	}
+
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		wallpaper := findWallpaper(h.cache, ref)
+		if wallpaper == nil {
+			return nil, fmt.Errorf("wallpaper not found: %s", ref)
+		}
+		ids = append(ids, wallpaper.ID)
+	}
+	return ids, nil
+}
+
+// playlistName returns the playlist named by the first argument
+func playlistName(c *cli.Command) (string, error) {
+	name := c.Args().First()
+	if name == "" {
+		return "", fmt.Errorf("a playlist name is required")
+	}
+	return name, nil
+}
+
+// HandleCreate creates a playlist, optionally with its first wallpapers
+func (h *PlaylistHandler) HandleCreate(ctx context.Context, c *cli.Command) error {
+	name, err := playlistName(c)
+	if err != nil {
+		return err
+	}
+	mode := c.String("mode")
+	if err := h.validator.ValidatePlaylistMode(mode); err != nil {
+		return err
+	}
+
+	if err := h.cache.CreatePlaylist(name, mode); err != nil {
+		return err
+	}
+	fmt.Printf("Created %s playlist %s\n", mode, name)
+
+	if refs := c.Args().Tail(); len(refs) > 0 {
+		return h.add(name, refs, 0)
+	}
+	return nil
+}
+
+// HandleDelete deletes a playlist, leaving its wallpapers in the cache
+func (h *PlaylistHandler) HandleDelete(ctx context.Context, c *cli.Command) error {
+	name, err := playlistName(c)
+	if err != nil {
+		return err
+	}
+	if err := h.cache.DeletePlaylist(name); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted playlist %s\n", name)
+	return nil
+}
+
+// HandleAdd adds wallpapers to a playlist, the current wallpaper by default
+func (h *PlaylistHandler) HandleAdd(ctx context.Context, c *cli.Command) error {
+	name, err := playlistName(c)
+	if err != nil {
+		return err
+	}
+	return h.add(name, c.Args().Tail(), c.Int("at"))
+}
+
+// add adds the referenced wallpapers to a playlist before the given place
+func (h *PlaylistHandler) add(name string, refs []string, at int) error {
+	ids, err := h.wallpaperIDs(refs)
+	if err != nil {
+		return err
+	}
+
+	added, err := h.cache.AddToPlaylist(name, ids, at)
+	if err != nil {
+		h.logger.Error("Failed to add to playlist", "playlist", name, "error", err)
+		return err
+	}
+	fmt.Printf("Added %d wallpaper(s) to %s", added, name)
+	if skipped := len(ids) - added; skipped > 0 {
+		fmt.Printf(" (%d already in it)", skipped)
+	}
+	fmt.Printf("\n")
+	return nil
+}
+
+// HandleRemove removes wallpapers from a playlist, the current wallpaper by default
+func (h *PlaylistHandler) HandleRemove(ctx context.Context, c *cli.Command) error {
+	name, err := playlistName(c)
+	if err != nil {
+		return err
+	}
+	if _, err := h.cache.GetPlaylist(name); err != nil {
+		return err
+	}
+	ids, err := h.wallpaperIDs(c.Args().Tail())
+	if err != nil {
+		return err
+	}
+
+	removed, err := h.cache.RemoveFromPlaylist(name, ids)
+	if err != nil {
+		h.logger.Error("Failed to remove from playlist", "playlist", name, "error", err)
+		return err
+	}
+	fmt.Printf("Removed %d wallpaper(s) from %s\n", removed, name)
+	return nil
+}
+
+// HandleList lists the playlists
+func (h *PlaylistHandler) HandleList(ctx context.Context, c *cli.Command) error {
+	out, err := newRenderer(c)
+	if err != nil {
+		return err
+	}
+
+	playlists, err := h.cache.ListPlaylists()
+	if err != nil {
+		h.logger.Error("Failed to list playlists", "error", err)
+		return err
+	}
+	if !out.text() {
+		return out.render(playlists)
+	}
+
+	if len(playlists) == 0 {
+		fmt.Printf("No playlists\n")
+		return nil
+	}
+	for _, playlist := range playlists {
+		marker := " "
+		if playlist.Active {
+			marker = "*"
+		}
+		fmt.Printf("%s %-20s %-10s %d wallpaper(s)\n", marker, playlist.Name, playlist.Mode, playlist.Count)
+	}
+	return nil
+}
+
+// HandleShow lists a playlist's wallpapers in order, marking the one played last
+func (h *PlaylistHandler) HandleShow(ctx context.Context, c *cli.Command) error {
+	out, err := newRenderer(c)
+	if err != nil {
+		return err
+	}
+	name, err := playlistName(c)
+	if err != nil {
+		return err
+	}
+
+	playlist, err := h.cache.GetPlaylist(name)
+	if err != nil {
+		return err
+	}
+	items, err := h.cache.GetPlaylistItems(name)
+	if err != nil {
+		return err
+	}
+	if !out.text() {
+		return out.render(items)
+	}
+
+	status := ""
+	if playlist.Active {
+		status = ", playing"
+	}
+	fmt.Printf("%s (%s%s)\n", playlist.Name, playlist.Mode, status)
+	if len(items) == 0 {
+		fmt.Printf("  No wallpapers\n")
+		return nil
+	}
+	for i, item := range items {
+		marker := " "
+		if item.ID == playlist.Current {
+			marker = ">"
+		}
+		fmt.Printf("%s %3d. %s  %s\n", marker, i+1, displayID(item), filepath.Base(item.Path))
+	}
+	return nil
+}
+
+// HandlePlay makes a playlist the one next and previous step through and
+// switches to its next wallpaper
+func (h *PlaylistHandler) HandlePlay(ctx context.Context, c *cli.Command) error {
+	name, err := playlistName(c)
+	if err != nil {
+		return err
+	}
+	playlist, err := h.cache.GetPlaylist(name)
+	if err != nil {
+		return err
+	}
+
+	if mode := c.String("mode"); mode != "" && mode != playlist.Mode {
+		if err := h.validator.ValidatePlaylistMode(mode); err != nil {
+			return err
+		}
+		if err := h.cache.SetPlaylistMode(name, mode); err != nil {
+			return err
+		}
+		playlist.Mode = mode
+	}
+	if c.Bool("restart") {
+		if err := h.cache.ResetPlaylist(name); err != nil {
+			return err
+		}
+	}
+	if err := h.cache.SetActivePlaylist(name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Playing %s (%s)\n", name, playlist.Mode)
+	return playPlaylist(c, h.cache, h.executor, h.logger, playlist, 1)
+}
+
+// HandleStop stops playing the active playlist so next and previous go back to
+// stepping through history
+func (h *PlaylistHandler) HandleStop(ctx context.Context, c *cli.Command) error {
+	playlist := h.cache.GetActivePlaylist()
+	if playlist == nil {
+		fmt.Printf("No playlist is playing\n")
+		return nil
+	}
+	if err := h.cache.SetActivePlaylist(""); err != nil {
+		return err
+	}
+	fmt.Printf("Stopped playing %s\n", playlist.Name)
+	return nil
+}
+
+// GetCreateFlags returns the CLI flags for the playlist create command
+func (h *PlaylistHandler) GetCreateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "mode",
+			Aliases: []string{"m"},
+			Value:   constants.PlaylistModeSequential,
+			Usage:   "How the playlist plays: " + strings.Join(constants.ValidPlaylistModes, ", "),
+		},
+	}
+}
+
+// GetAddFlags returns the CLI flags for the playlist add command
+func (h *PlaylistHandler) GetAddFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:  "at",
+			Usage: "Insert before the wallpaper at this place (1 is the first) instead of appending",
+		},
+	}
+}
+
+// GetPlayFlags returns the CLI flags for the playlist play command
+func (h *PlaylistHandler) GetPlayFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      "scriptPath",
+			Aliases:   []string{"sp"},
+			TakesFile: true,
+			Usage:     "Path to the script to run after switching",
+		},
+		&cli.StringFlag{
+			Name:    "mode",
+			Aliases: []string{"m"},
+			Usage:   "Change how the playlist plays: " + strings.Join(constants.ValidPlaylistModes, ", "),
+		},
+		&cli.BoolFlag{
+			Name:  "restart",
+			Usage: "Start from the first wallpaper, or a new shuffled round, instead of resuming",
+		},
+	}
+}
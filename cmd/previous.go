@@ -9,6 +9,7 @@ import (
 	"github.com/urfave/cli/v3"
 
 	"git.asdf.cafe/abs3nt/wallhaven_dl/config"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/executor"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
 )
@@ -31,7 +32,18 @@ func NewPreviousHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *P
 
 // Handle processes the previous command
 func (h *PreviousHandler) Handle(ctx context.Context, c *cli.Command) error {
-	previous := h.cache.GetPrevious()
+	steps := int(c.Int("steps"))
+	if steps < 1 {
+		return fmt.Errorf("steps must be at least 1")
+	}
+
+	// Step back through a sequential playlist; shuffled ones step back through
+	// history instead
+	if playlist := activePlaylist(c, h.cache); playlist != nil && playlist.Mode == constants.PlaylistModeSequential {
+		return playPlaylist(c, h.cache, h.executor, h.logger, playlist, -steps)
+	}
+
+	previous := h.cache.GetPrevious(steps)
 	if previous == nil {
 		h.logger.Info("No previous wallpaper found")
 		return fmt.Errorf("no previous wallpaper available")
@@ -41,13 +53,13 @@ func (h *PreviousHandler) Handle(ctx context.Context, c *cli.Command) error {
 
 	scriptPath := c.String("scriptPath")
 	if scriptPath != "" {
-		if err := h.executor.Execute(scriptPath, previous.Path); err != nil {
+		if err := h.executor.Execute(scriptPath, applyPath(c, h.cache, h.logger, previous)); err != nil {
 			return err
 		}
 	}
 
-	// Update the current view to this wallpaper so next 'previous' call goes further back
-	if err := h.cache.SetCurrentView(previous.ID); err != nil {
+	// Step back along the navigation stack without truncating what lies ahead
+	if _, err := h.cache.Navigate(-steps); err != nil {
 		h.logger.Warn("Failed to update current view", "error", err)
 	}
 
@@ -71,5 +83,15 @@ func (h *PreviousHandler) GetFlags() []cli.Flag {
 			TakesFile: true,
 			Usage:     "Path to the script to run after switching",
 		},
+		&cli.IntFlag{
+			Name:    "steps",
+			Aliases: []string{"n"},
+			Value:   1,
+			Usage:   "How many wallpapers to go back in history",
+		},
+		&cli.BoolFlag{
+			Name:  "history",
+			Usage: "Step back through history even while a playlist is playing",
+		},
 	}
 }
@@ -45,11 +45,21 @@ func (h *RateHandler) Handle(ctx context.Context, c *cli.Command) error {
 		return fmt.Errorf("no current wallpaper available")
 	}
 
+	out, err := newRenderer(c)
+	if err != nil {
+		return err
+	}
+
 	if err := h.cache.SetRating(current.ID, rating); err != nil {
 		h.logger.Error("Failed to set rating", "error", err)
 		return err
 	}
 
+	if !out.text() {
+		current.Rating = rating
+		return out.render(current)
+	}
+
 	fmt.Printf("Rated wallpaper %s: %s\n", filepath.Base(current.Path), strings.Repeat("⭐", rating))
 	return nil
 }
@@ -3,10 +3,12 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"math/rand"
 	"os"
 	"path"
+	"slices"
 	"strings"
 	"time"
 
@@ -60,7 +62,7 @@ func (h *SearchHandler) Handle(ctx context.Context, c *cli.Command) error {
 		h.logger.Warn("Failed to cleanup invalid cache entries", "error", err)
 	}
 
-	wallpaper, filePath, err := h.searchAndDownload(ctx, cfg, c.Args().First())
+	id, filePath, err := h.searchAndDownload(ctx, cfg, c.Args().First())
 	if err != nil {
 		h.logger.Error("Failed to search and download wallpaper", "error", err)
 		return err
@@ -68,13 +70,17 @@ func (h *SearchHandler) Handle(ctx context.Context, c *cli.Command) error {
 
 	h.logger.Info("Wallpaper ready", "path", filePath)
 
+	// Hand the script a variant fitted to the display when one is configured
+	if wallpaper := h.cache.GetByID(id); wallpaper != nil && cfg.ScriptPath != "" {
+		filePath = applyPath(c, h.cache, h.logger, wallpaper)
+	}
+
 	// Execute script if provided - non-fatal if it fails
 	if err := h.executeScript(cfg.ScriptPath, filePath); err != nil {
 		h.logger.Warn("Script execution failed, but wallpaper was downloaded successfully", "error", err)
 	}
 
-	if wallpaper != nil {
-		id := wallhaven.GenerateID(wallpaper.Path)
+	if id != "" {
 		if err := h.cache.MarkAsUsed(id); err != nil {
 			h.logger.Warn("Failed to mark wallpaper as used", "error", err)
 		}
@@ -101,11 +107,22 @@ func (h *SearchHandler) buildConfig(c *cli.Command) (*config.Config, error) {
 	cfg.AtLeast = c.String("atLeast")
 	cfg.DownloadPath = c.String("downloadPath")
 	cfg.ScriptPath = c.String("scriptPath")
+	cfg.SimilarityThreshold = c.Int("similarity")
+	cfg.Discover = c.Bool("discover")
+
+	if avoidRecent := c.String("avoidRecent"); avoidRecent != "" && avoidRecent != "0" {
+		window, err := parseDuration(avoidRecent)
+		if err != nil {
+			return nil, fmt.Errorf("invalid avoidRecent duration: %w", err)
+		}
+		cfg.AvoidRecent = window
+	}
 
 	return cfg, nil
 }
 
-func (h *SearchHandler) searchAndDownload(ctx context.Context, cfg *config.Config, query string) (*wallhaven.Wallpaper, string, error) {
+// searchAndDownload picks a search result and returns its cache ID and local path
+func (h *SearchHandler) searchAndDownload(ctx context.Context, cfg *config.Config, query string) (string, string, error) {
 	seed := rand.NewSource(time.Now().UnixNano())
 	r := rand.New(seed)
 
@@ -126,42 +143,197 @@ func (h *SearchHandler) searchAndDownload(ctx context.Context, cfg *config.Confi
 		}
 	}
 
-	h.logger.Debug("Searching wallpapers", "query", query, "page", search.Page)
+	blocklist, err := h.cache.GetBlocklist()
+	if err != nil {
+		h.logger.Warn("Failed to load blocklist", "error", err)
+		blocklist = &wallhaven.Blocklist{}
+	}
+	blocklist.ApplyTo(&search.Query)
+
+	var prefs *wallhaven.Preferences
+	if cfg.Discover {
+		prefs = h.discover(search, r)
+	}
+
+	results, err := h.searchPage(ctx, search, blocklist)
+	if err != nil {
+		return "", "", err
+	}
+
+	result, err := h.choose(ctx, search, results, r, cfg, blocklist, prefs)
+	if err != nil {
+		return "", "", err
+	}
+	return h.getOrDownloadWithCache(ctx, result, cfg)
+}
+
+// discover learns the preference model and composes the search from it: without
+// a query one of the most liked tags is searched for, and the most disliked tags
+// are excluded. It returns nil when nothing has been learned yet.
+func (h *SearchHandler) discover(search *wallhaven.Search, r *rand.Rand) *wallhaven.Preferences {
+	prefs, err := h.cache.LearnPreferences()
+	if err != nil {
+		h.logger.Warn("Failed to learn preferences", "error", err)
+		return nil
+	}
+
+	if len(search.Query.Tags) == 0 {
+		if liked := prefs.TopTags(constants.DiscoverTopTags); len(liked) > 0 {
+			search.Query.Tags = []string{liked[r.Intn(len(liked))]}
+		}
+	}
+	for _, tag := range prefs.DislikedTags(constants.DiscoverExcludedTags) {
+		if !slices.Contains(search.Query.Tags, tag) && !slices.Contains(search.Query.ExcludeTags, tag) {
+			search.Query.ExcludeTags = append(search.Query.ExcludeTags, tag)
+		}
+	}
+
+	h.logger.Info("Discovering wallpapers", "tags", search.Query.Tags, "excluded", search.Query.ExcludeTags)
+	return prefs
+}
+
+// searchPage runs a search and drops the blocked results
+func (h *SearchHandler) searchPage(ctx context.Context, search *wallhaven.Search, blocklist *wallhaven.Blocklist) (*wallhaven.SearchResults, error) {
+	h.logger.Debug("Searching wallpapers", "query", search.Query.Tags, "page", search.Page)
 	results, err := wallhaven.SearchWallpapersWithContext(ctx, search)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
 	h.logger.Info("Found wallpapers", "count", len(results.Data))
-	return h.getOrDownloadWithCache(ctx, results, r, cfg.DownloadPath, cfg.Categories, cfg.Purity)
+	if removed := results.RemoveBlocked(blocklist); removed > 0 {
+		h.logger.Info("Skipped blocked wallpapers", "count", removed)
+	}
+	return results, nil
+}
+
+// choose picks a search result, preferring wallpapers never downloaded over
+// cached ones and skipping any shown within cfg.AvoidRecent. With preferences
+// only the best ranked of each group are picked from. When a page has nothing
+// left to pick the following pages are searched, and if they run out too any
+// result of the first page will do.
+func (h *SearchHandler) choose(ctx context.Context, search *wallhaven.Search, results *wallhaven.SearchResults, r *rand.Rand, cfg *config.Config, blocklist *wallhaven.Blocklist, prefs *wallhaven.Preferences) (wallhaven.Wallpaper, error) {
+	recent := h.recentlyUsed(cfg.AvoidRecent)
+	first := results
+
+	for extra := 0; ; extra++ {
+		for _, tier := range h.candidateTiers(results.Data, recent, cfg.DownloadPath) {
+			// Discover picks among the best ranked, and the next best stand in for
+			// any of those that turn out to be blocked
+			var reserve []wallhaven.Wallpaper
+			if prefs != nil {
+				ranked := prefs.Rank(tier, len(tier))
+				n := min(constants.DiscoverCandidates, len(ranked))
+				tier, reserve = ranked[:n], ranked[n:]
+			}
+			if result, err := h.pick(ctx, tier, reserve, r, blocklist); err == nil {
+				return result, nil
+			}
+		}
+		if len(results.Data) == 0 || extra == constants.MaxExtraSearchPages {
+			break
+		}
+
+		search.Page++
+		h.logger.Info("Every result was shown recently or blocked, searching the next page", "page", search.Page)
+		next, err := h.searchPage(ctx, search, blocklist)
+		if err != nil {
+			h.logger.Warn("Failed to search the next page", "error", err)
+			break
+		}
+		results = next
+	}
+
+	h.logger.Info("Every result was shown recently or blocked, picking from the first page")
+	return h.pick(ctx, first.Data, nil, r, blocklist)
+}
+
+// recentlyUsed returns the cache IDs of the wallpapers shown within window
+func (h *SearchHandler) recentlyUsed(window time.Duration) map[string]bool {
+	recent := make(map[string]bool)
+	if window <= 0 {
+		return recent
+	}
+	for _, wallpaper := range h.cache.GetHistoryBetween(time.Now().Add(-window), time.Time{}) {
+		recent[wallpaper.ID] = true
+	}
+	return recent
 }
 
-func (h *SearchHandler) getOrDownloadWithCache(ctx context.Context, results *wallhaven.SearchResults, r *rand.Rand, downloadPath, categories, purities string) (*wallhaven.Wallpaper, string, error) {
-	if len(results.Data) == 0 {
-		return nil, "", errors.ErrNoWallpapersFound
+// candidateTiers splits results into those never downloaded and those already
+// downloaded, in order of preference, leaving out any shown recently
+func (h *SearchHandler) candidateTiers(results []wallhaven.Wallpaper, recent map[string]bool, downloadPath string) [][]wallhaven.Wallpaper {
+	var fresh, downloaded []wallhaven.Wallpaper
+	for _, result := range results {
+		id := wallhaven.GenerateID(result.Path)
+		if recent[id] {
+			continue
+		}
+		if _, err := os.Stat(path.Join(downloadPath, path.Base(result.Path))); err == nil || h.cache.GetByID(id) != nil {
+			downloaded = append(downloaded, result)
+		} else {
+			fresh = append(fresh, result)
+		}
+	}
+	return [][]wallhaven.Wallpaper{fresh, downloaded}
+}
+
+// pick chooses a random candidate. Search listings don't name the uploader, so
+// when uploaders are blocked each pick is looked up and replaced if blocked, by
+// the first of reserve while any are left.
+func (h *SearchHandler) pick(ctx context.Context, candidates, reserve []wallhaven.Wallpaper, r *rand.Rand, blocklist *wallhaven.Blocklist) (wallhaven.Wallpaper, error) {
+	candidates = slices.Clone(candidates)
+	for len(candidates) > 0 {
+		i := r.Intn(len(candidates))
+		result := candidates[i]
+		if len(blocklist.Uploaders) == 0 {
+			return result, nil
+		}
+
+		info, err := h.api.GetWallpaper(ctx, wallhaven.WallpaperID(result.ID))
+		if err != nil {
+			h.logger.Warn("Failed to look up wallpaper uploader", "id", result.ID, "error", err)
+			return result, nil
+		}
+		if blocklist.Allows(info) {
+			// Keep what the lookup found so the download doesn't repeat it
+			result.Uploader, result.Tags = info.Uploader, info.Tags
+			return result, nil
+		}
+		h.logger.Info("Skipping blocked wallpaper", "id", result.ID)
+		candidates = slices.Delete(candidates, i, i+1)
+		if len(reserve) > 0 {
+			candidates, reserve = append(candidates, reserve[0]), reserve[1:]
+		}
 	}
+	return wallhaven.Wallpaper{}, errors.ErrNoWallpapersFound
+}
 
+// getOrDownloadWithCache returns the cache ID and local path of a search result,
+// downloading it unless it is already on disk
+func (h *SearchHandler) getOrDownloadWithCache(ctx context.Context, result wallhaven.Wallpaper, cfg *config.Config) (string, string, error) {
+	downloadPath := cfg.DownloadPath
 	if err := os.MkdirAll(downloadPath, 0o755); err != nil {
-		return nil, "", err
+		return "", "", err
 	}
 
-	result := results.Data[r.Intn(len(results.Data))]
+	id := wallhaven.GenerateID(result.Path)
 	fullPath := path.Join(downloadPath, path.Base(result.Path))
 
 	if _, err := os.Stat(fullPath); err == nil {
 		h.logger.Info("Using existing wallpaper", "path", fullPath)
 		// Ensure the wallpaper is in the cache (may be missing if migrated from old cache)
-		id := wallhaven.GenerateID(result.Path)
 		if existing := h.cache.GetByID(id); existing == nil {
-			if err := h.cache.AddWallpaper(&result, fullPath, categories, purities); err != nil {
+			h.fillDetails(ctx, &result, cfg)
+			if err := h.cache.AddWallpaper(&result, fullPath, cfg.Categories, cfg.Purity); err != nil {
 				h.logger.Warn("Failed to add existing wallpaper to cache", "error", err)
 			}
 		}
-		return &result, fullPath, nil
+		return id, fullPath, nil
 	}
 
 	if err := result.DownloadWithContext(ctx, downloadPath); err != nil {
-		return nil, "", err
+		return "", "", err
 	}
 
 	hash, _, err := wallhaven.CalculateFileHash(fullPath)
@@ -171,15 +343,74 @@ func (h *SearchHandler) getOrDownloadWithCache(ctx context.Context, results *wal
 		if duplicate := h.cache.FindDuplicate(hash); duplicate != nil {
 			h.logger.Info("Duplicate wallpaper detected", "existing", duplicate.Path, "new", fullPath)
 			os.Remove(fullPath)
-			return &result, duplicate.Path, nil
+			return duplicate.ID, duplicate.Path, nil
+		}
+	}
+
+	similar := h.findSimilar(fullPath, cfg.SimilarityThreshold)
+	if similar != nil {
+		resolution, _ := wallhaven.GetImageResolution(fullPath)
+		candidate := &wallhaven.WallpaperMetadata{Resolution: resolution}
+		if wallhaven.BestCopy([]*wallhaven.WallpaperMetadata{candidate, similar}) == similar {
+			h.logger.Info("Near-duplicate wallpaper detected, keeping existing copy", "existing", similar.Path, "new", fullPath)
+			os.Remove(fullPath)
+			return similar.ID, similar.Path, nil
 		}
 	}
 
-	if err := h.cache.AddWallpaper(&result, fullPath, categories, purities); err != nil {
+	h.fillDetails(ctx, &result, cfg)
+	if err := h.cache.AddWallpaper(&result, fullPath, cfg.Categories, cfg.Purity); err != nil {
 		h.logger.Warn("Failed to add wallpaper to cache", "error", err)
+		return id, fullPath, nil
+	}
+
+	if similar != nil {
+		h.logger.Info("Near-duplicate wallpaper detected, replacing lower resolution copy", "existing", similar.Path, "new", fullPath)
+		if err := h.cache.MergeDuplicate(id, similar.ID); err != nil {
+			h.logger.Warn("Failed to merge near-duplicate wallpaper", "error", err)
+		}
+	}
+
+	if err := result.DownloadThumbnailWithContext(ctx, h.cache.ThumbnailPath(id)); err != nil {
+		h.logger.Debug("Failed to download thumbnail", "error", err)
 	}
 
-	return &result, fullPath, nil
+	return id, fullPath, nil
+}
+
+// fillDetails looks up the uploader and tags search listings leave out, so the
+// cache can learn preferences from them. It costs an API request per download, so
+// it only runs for discover searches and when picking hasn't looked them up already.
+func (h *SearchHandler) fillDetails(ctx context.Context, result *wallhaven.Wallpaper, cfg *config.Config) {
+	if !cfg.Discover || result.ID == "" || result.Uploader != nil {
+		return
+	}
+	info, err := h.api.GetWallpaper(ctx, wallhaven.WallpaperID(result.ID))
+	if err != nil {
+		h.logger.Debug("Failed to look up wallpaper details", "id", result.ID, "error", err)
+		return
+	}
+	result.Uploader = info.Uploader
+	result.Tags = info.Tags
+}
+
+// findSimilar returns the closest cached near-duplicate of the image at filePath, if any
+func (h *SearchHandler) findSimilar(filePath string, threshold int) *wallhaven.WallpaperMetadata {
+	if threshold <= 0 {
+		return nil
+	}
+
+	phash, err := wallhaven.CalculatePerceptualHash(filePath)
+	if err != nil {
+		h.logger.Warn("Failed to calculate perceptual hash for downloaded file", "error", err)
+		return nil
+	}
+
+	similar := h.cache.FindSimilar(phash, threshold)
+	if len(similar) == 0 {
+		return nil
+	}
+	return similar[0]
 }
 
 func (h *SearchHandler) executeScript(scriptPath, imagePath string) error {
@@ -248,6 +479,22 @@ func (h *SearchHandler) GetFlags() []cli.Flag {
 			Value:   constants.DefaultAtLeast,
 			Usage:   "Minimum resolution",
 		},
+		&cli.IntFlag{
+			Name:    "similarity",
+			Aliases: []string{"sim"},
+			Value:   constants.DefaultSimilarityThreshold,
+			Usage:   "Treat downloads within this many perceptual hash bits of a cached wallpaper as duplicates (0 disables)",
+		},
+		&cli.BoolFlag{
+			Name:    "discover",
+			Aliases: []string{"d"},
+			Usage:   "Search for tags learned from ratings and favourites and prefer results like the best rated",
+		},
+		&cli.StringFlag{
+			Name:  "avoidRecent",
+			Value: constants.DefaultAvoidRecent,
+			Usage: "Skip results shown within this duration (e.g., '7d', '12h'), 0 to allow any",
+		},
 		&cli.StringFlag{
 			Name:      "scriptPath",
 			Aliases:   []string{"sp"},
@@ -5,14 +5,20 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli/v3"
 
 	"git.asdf.cafe/abs3nt/wallhaven_dl/config"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
 )
 
+// statsBarWidth is the width of the longest bar in the stats tables
+const statsBarWidth = 20
+
 // StatsHandler handles statistics command
 type StatsHandler struct {
 	cache  interfaces.WallpaperCache
@@ -29,7 +35,27 @@ func NewStatsHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *Stat
 
 // Handle processes the stats command
 func (h *StatsHandler) Handle(ctx context.Context, c *cli.Command) error {
-	stats := h.cache.GetStatistics()
+	since, until, err := parseTimeRange(c)
+	if err != nil {
+		return err
+	}
+
+	stats, err := h.cache.GetStatistics(since, until)
+	if err != nil {
+		h.logger.Error("Failed to gather statistics", "error", err)
+		return fmt.Errorf("failed to gather statistics: %w", err)
+	}
+
+	out, err := newRenderer(c)
+	if err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		out.mode = constants.OutputJSON
+	}
+	if !out.text() {
+		return out.render(stats)
+	}
 
 	fmt.Printf("\n╔═══════════════════════════════════════════════════╗\n")
 	fmt.Printf("║         Wallpaper Statistics & Insights          ║\n")
@@ -38,83 +64,115 @@ func (h *StatsHandler) Handle(ctx context.Context, c *cli.Command) error {
 	// Basic Stats
 	fmt.Printf("📊 Collection Overview\n")
 	fmt.Printf("─────────────────────────────────────────────────────\n")
-	fmt.Printf("  Total wallpapers:     %v\n", stats["total_wallpapers"])
-	fmt.Printf("  Valid wallpapers:     %v\n", stats["valid_wallpapers"])
-	fmt.Printf("  Invalid/missing:      %v\n", stats["invalid_wallpapers"])
-	fmt.Printf("  Favorite wallpapers:  %v\n", stats["favorite_count"])
-	fmt.Printf("  Total storage used:   %.2f MB\n", stats["total_size_mb"])
-	if avgRating, ok := stats["average_rating"].(float64); ok && avgRating > 0 {
-		fmt.Printf("  Average rating:       %.1f / 5\n", avgRating)
+	fmt.Printf("  Total wallpapers:     %d\n", stats.TotalWallpapers)
+	fmt.Printf("  Valid wallpapers:     %d\n", stats.ValidWallpapers)
+	fmt.Printf("  Invalid/missing:      %d\n", stats.InvalidWallpapers)
+	fmt.Printf("  Favorite wallpapers:  %d\n", stats.FavoriteCount)
+	fmt.Printf("  Total storage used:   %.2f MB\n", float64(stats.TotalSize)/1024/1024)
+	if stats.AverageRating > 0 {
+		fmt.Printf("  Average rating:       %.1f / 5\n", stats.AverageRating)
 	}
 	fmt.Printf("\n")
 
 	// Timeline
 	fmt.Printf("📅 Timeline\n")
 	fmt.Printf("─────────────────────────────────────────────────────\n")
-	if oldest, ok := stats["oldest_download"].(time.Time); ok && !oldest.IsZero() {
-		fmt.Printf("  Oldest download:      %s\n", oldest.Format("2006-01-02 15:04:05"))
+	if !stats.OldestDownload.IsZero() {
+		fmt.Printf("  Oldest download:      %s\n", stats.OldestDownload.Format("2006-01-02 15:04:05"))
 	}
-	if newest, ok := stats["newest_download"].(time.Time); ok && !newest.IsZero() {
-		fmt.Printf("  Newest download:      %s\n", newest.Format("2006-01-02 15:04:05"))
+	if !stats.NewestDownload.IsZero() {
+		fmt.Printf("  Newest download:      %s\n", stats.NewestDownload.Format("2006-01-02 15:04:05"))
 	}
 	fmt.Printf("\n")
 
 	// Recent Activity
 	fmt.Printf("⚡ Recent Activity\n")
 	fmt.Printf("─────────────────────────────────────────────────────\n")
-	fmt.Printf("  Unique wallpapers used (last 7 days):  %v\n", stats["unique_wallpapers_last_week"])
-	fmt.Printf("  Unique wallpapers used (last 30 days): %v\n", stats["unique_wallpapers_last_month"])
-	fmt.Printf("  Total history entries:                 %v\n", stats["total_history_entries"])
+	fmt.Printf("  Unique wallpapers used (last 7 days):  %d\n", stats.UniqueLastWeek)
+	fmt.Printf("  Unique wallpapers used (last 30 days): %d\n", stats.UniqueLastMonth)
+	fmt.Printf("  Total history entries:                 %d\n", stats.HistoryEntries)
 	fmt.Printf("\n")
 
+	if !since.IsZero() || !until.IsZero() {
+		fmt.Printf("🔎 Activity %s\n", describeWindow(since, until))
+		fmt.Printf("─────────────────────────────────────────────────────\n")
+		fmt.Printf("  Downloads:            %d\n", stats.Downloads)
+		fmt.Printf("  Times changed:        %d\n", stats.Uses)
+		fmt.Printf("  Unique wallpapers:    %d\n", stats.UniqueUsed)
+		fmt.Printf("\n")
+	}
+
 	// Current State
-	if current, ok := stats["current_wallpaper"].(string); ok && current != "" {
+	if stats.CurrentWallpaper != "" {
 		fmt.Printf("🖼️  Current State\n")
 		fmt.Printf("─────────────────────────────────────────────────────\n")
-		fmt.Printf("  Current wallpaper ID:  %s\n", current)
-		if previous, ok := stats["previous_wallpaper"].(string); ok && previous != "" {
-			fmt.Printf("  Previous wallpaper ID: %s\n", previous)
+		fmt.Printf("  Current wallpaper ID:  %s\n", stats.CurrentWallpaper)
+		if stats.PreviousWallpaper != "" {
+			fmt.Printf("  Previous wallpaper ID: %s\n", stats.PreviousWallpaper)
 		}
 		fmt.Printf("\n")
 	}
 
-	// Most Used Wallpapers - Using reflection/sprintf since we can't type assert the struct from GetStatistics
+	// Most Used Wallpapers
 	fmt.Printf("⭐ Top 5 Most Used Wallpapers\n")
 	fmt.Printf("─────────────────────────────────────────────────────\n")
-	if mostUsedRaw, ok := stats["most_used"]; ok {
-		// Use fmt to print the value - it will handle the struct slice
-		fmt.Printf("  %v\n", mostUsedRaw)
-	} else {
+	if len(stats.MostUsed) == 0 {
 		fmt.Printf("  No data available\n")
 	}
+	for i, usage := range stats.MostUsed {
+		fmt.Printf("  %d. %-20s %5d uses  %s\n", i+1, usage.ID, usage.UseCount, filepath.Base(usage.Path))
+	}
 	fmt.Printf("\n")
 
 	// Top Tags
 	fmt.Printf("🏷️  Top 10 Most Common Tags\n")
 	fmt.Printf("─────────────────────────────────────────────────────\n")
-	if topTagsRaw, ok := stats["top_tags"]; ok {
-		fmt.Printf("  %v\n", topTagsRaw)
-	} else {
+	if len(stats.TopTags) == 0 {
 		fmt.Printf("  No tags found\n")
 	}
+	for _, tag := range stats.TopTags {
+		fmt.Printf("  %-30s %5d  %s\n", tag.Tag, tag.Count, bar(tag.Count, stats.TopTags[0].Count))
+	}
 	fmt.Printf("\n")
 
 	// Resolution Distribution
 	fmt.Printf("📐 Resolution Distribution\n")
 	fmt.Printf("─────────────────────────────────────────────────────\n")
-	if resolutionsRaw, ok := stats["resolutions"]; ok {
-		fmt.Printf("  %v\n", resolutionsRaw)
-	} else {
+	if len(stats.Resolutions) == 0 {
 		fmt.Printf("  No resolution data\n")
 	}
+	for _, resolution := range stats.Resolutions {
+		fmt.Printf("  %-30s %5d  %s\n", resolution.Resolution, resolution.Count, bar(resolution.Count, stats.Resolutions[0].Count))
+	}
 	fmt.Printf("\n")
 
 	return nil
 }
 
+// describeWindow describes a --since/--until range for a section heading
+func describeWindow(since, until time.Time) string {
+	const layout = "2006-01-02 15:04"
+	switch {
+	case since.IsZero():
+		return "until " + until.Format(layout)
+	case until.IsZero():
+		return "since " + since.Format(layout)
+	default:
+		return since.Format(layout) + " to " + until.Format(layout)
+	}
+}
+
+// bar draws a bar proportional to value/maxValue, at most statsBarWidth wide
+func bar(value, maxValue int) string {
+	if maxValue <= 0 {
+		return ""
+	}
+	return strings.Repeat("█", max(1, value*statsBarWidth/maxValue))
+}
+
 // GetFlags returns the CLI flags for the stats command
 func (h *StatsHandler) GetFlags() []cli.Flag {
-	return []cli.Flag{
+	return append([]cli.Flag{
 		&cli.StringFlag{
 			Name:      "downloadPath",
 			Aliases:   []string{"dp"},
@@ -122,5 +180,9 @@ func (h *StatsHandler) GetFlags() []cli.Flag {
 			TakesFile: true,
 			Usage:     "Absolute path to download directory",
 		},
-	}
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the statistics as JSON (same as --output=json)",
+		},
+	}, timeRangeFlags()...)
 }
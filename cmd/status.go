@@ -0,0 +1,208 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
+)
+
+// StatusHandler prints the current wallpaper for status bars
+type StatusHandler struct {
+	cache     interfaces.WallpaperCache
+	validator interfaces.Validator
+	logger    *slog.Logger
+}
+
+// NewStatusHandler creates a new status handler
+func NewStatusHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *StatusHandler {
+	return &StatusHandler{
+		cache:     cache,
+		validator: validator.NewValidator(),
+		logger:    logger,
+	}
+}
+
+// waybarStatus is the JSON object waybar's custom module reads with return-type json
+type waybarStatus struct {
+	Text       string   `json:"text"`
+	Alt        string   `json:"alt"`
+	Tooltip    string   `json:"tooltip"`
+	Class      []string `json:"class"`
+	Percentage int      `json:"percentage"`
+}
+
+// Handle processes the status command
+func (h *StatusHandler) Handle(ctx context.Context, c *cli.Command) error {
+	bar := c.String("bar")
+	if err := h.validator.ValidateStatusBar(bar); err != nil {
+		return err
+	}
+	maxLength := int(c.Int("maxLength"))
+	showTags := c.Bool("tags")
+
+	status := func() string {
+		return formatStatus(currentWallpaper(h.cache), bar, maxLength, showTags)
+	}
+
+	if !c.Bool("follow") {
+		fmt.Println(status())
+		return nil
+	}
+
+	interval := c.Duration("interval")
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Other invocations change the view state in the database, so poll it and only
+	// print when the summary changes
+	last := status()
+	fmt.Println(last)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if current := status(); current != last {
+				fmt.Println(current)
+				last = current
+			}
+		}
+	}
+}
+
+// formatStatus renders a one-entry status for the given bar. A nil wallpaper
+// renders as an empty status so bars can hide the module.
+func formatStatus(wallpaper *wallhaven.WallpaperMetadata, bar string, maxLength int, showTags bool) string {
+	if wallpaper == nil {
+		if bar == constants.StatusBarWaybar {
+			data, _ := json.Marshal(waybarStatus{Alt: "empty", Class: []string{"empty"}})
+			return string(data)
+		}
+		return ""
+	}
+
+	name := truncate(strings.TrimSuffix(filepath.Base(wallpaper.Path), filepath.Ext(wallpaper.Path)), maxLength)
+	text := name
+	if wallpaper.Rating > 0 {
+		text += " " + strings.Repeat("★", wallpaper.Rating)
+	}
+	if wallpaper.IsFavorite {
+		text += " ♥"
+	}
+	if showTags && len(wallpaper.Tags) > 0 {
+		text += " [" + strings.Join(wallpaper.Tags[:min(len(wallpaper.Tags), constants.StatusMaxTags)], ", ") + "]"
+	}
+
+	switch bar {
+	case constants.StatusBarWaybar:
+		status := waybarStatus{
+			Text:       text,
+			Alt:        "default",
+			Tooltip:    statusTooltip(wallpaper),
+			Class:      []string{},
+			Percentage: wallpaper.Rating * 100 / constants.MaxRating,
+		}
+		if wallpaper.Rating > 0 {
+			status.Alt = "rated"
+			status.Class = append(status.Class, "rated")
+		}
+		if wallpaper.IsFavorite {
+			status.Alt = "favorite"
+			status.Class = append(status.Class, "favorite")
+		}
+		data, _ := json.Marshal(status)
+		return string(data)
+	case constants.StatusBarI3blocks:
+		// full_text, short_text, then an optional colour
+		lines := []string{text, name}
+		if wallpaper.IsFavorite {
+			lines = append(lines, constants.StatusFavoriteColor)
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return text
+	}
+}
+
+// statusTooltip describes a wallpaper over several lines for bar tooltips
+func statusTooltip(wallpaper *wallhaven.WallpaperMetadata) string {
+	lines := []string{displayID(wallpaper), wallpaper.Path}
+	if wallpaper.Resolution != "" {
+		lines = append(lines, "Resolution: "+wallpaper.Resolution)
+	}
+	if wallpaper.Rating > 0 {
+		lines = append(lines, fmt.Sprintf("Rating: %d/%d", wallpaper.Rating, constants.MaxRating))
+	}
+	if len(wallpaper.Tags) > 0 {
+		lines = append(lines, "Tags: "+strings.Join(wallpaper.Tags, ", "))
+	}
+	lines = append(lines, fmt.Sprintf("Used %d times", wallpaper.UseCount))
+	return strings.Join(lines, "\n")
+}
+
+// truncate shortens s to at most maxLength characters, marking the cut with an
+// ellipsis. A maxLength of zero or less disables truncation.
+func truncate(s string, maxLength int) string {
+	runes := []rune(s)
+	if maxLength <= 0 || len(runes) <= maxLength {
+		return s
+	}
+	if maxLength == 1 {
+		return "…"
+	}
+	return string(runes[:maxLength-1]) + "…"
+}
+
+// GetFlags returns the CLI flags for the status command
+func (h *StatusHandler) GetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "bar",
+			Aliases: []string{"b"},
+			Value:   constants.DefaultStatusBar,
+			Usage:   "Status bar format: " + strings.Join(constants.ValidStatusBars, ", "),
+		},
+		&cli.BoolFlag{
+			Name:    "follow",
+			Aliases: []string{"f"},
+			Usage:   "Keep running and print a new status whenever the wallpaper changes",
+		},
+		&cli.DurationFlag{
+			Name:    "interval",
+			Aliases: []string{"i"},
+			Value:   constants.DefaultStatusInterval * time.Second,
+			Usage:   "How often to check for changes when following",
+		},
+		&cli.IntFlag{
+			Name:  "maxLength",
+			Value: constants.DefaultStatusMaxLength,
+			Usage: "Truncate the wallpaper name to this many characters, 0 to disable",
+		},
+		&cli.BoolFlag{
+			Name:  "tags",
+			Usage: "Include the first few tags in the status text",
+		},
+	}
+}
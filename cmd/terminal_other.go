@@ -0,0 +1,14 @@
+//go:build !unix
+
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import "os"
+
+// resizeSignals are the signals sent when the terminal is resized
+var resizeSignals []os.Signal
+
+// terminalCellSize returns zeros as the pixel size isn't available on this platform
+func terminalCellSize(fd int) (width, height int) {
+	return 0, 0
+}
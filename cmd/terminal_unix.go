@@ -0,0 +1,24 @@
+//go:build unix
+
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// resizeSignals are the signals sent when the terminal is resized
+var resizeSignals = []os.Signal{syscall.SIGWINCH}
+
+// terminalCellSize returns the size of a character cell in pixels, or zeros when
+// the terminal doesn't report its pixel size
+func terminalCellSize(fd int) (width, height int) {
+	size, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+	if err != nil || size.Col == 0 || size.Row == 0 {
+		return 0, 0
+	}
+	return int(size.Xpixel) / int(size.Col), int(size.Ypixel) / int(size.Row)
+}
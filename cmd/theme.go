@@ -0,0 +1,188 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/config"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
+)
+
+// ThemeHandler handles colour scheme generation
+type ThemeHandler struct {
+	cache     interfaces.WallpaperCache
+	validator interfaces.Validator
+	logger    *slog.Logger
+}
+
+// NewThemeHandler creates a new theme handler
+func NewThemeHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *ThemeHandler {
+	return &ThemeHandler{
+		cache:     cache,
+		validator: validator.NewValidator(),
+		logger:    logger,
+	}
+}
+
+// Handle processes the theme command
+func (h *ThemeHandler) Handle(ctx context.Context, c *cli.Command) error {
+	formats := c.StringSlice("formats")
+	for _, format := range formats {
+		if err := h.validator.ValidateThemeFormat(format); err != nil {
+			return err
+		}
+	}
+
+	current := currentWallpaper(h.cache)
+	if current == nil {
+		fmt.Printf("No current wallpaper found\n")
+		return fmt.Errorf("no current wallpaper available")
+	}
+
+	files, err := h.Generate(current.Path, c.String("outputDir"), formats, c.String("templateDir"))
+	if err != nil {
+		h.logger.Error("Failed to generate theme", "error", err)
+		return err
+	}
+
+	fmt.Printf("Generated theme from %s:\n", filepath.Base(current.Path))
+	for _, file := range files {
+		fmt.Printf("  %s\n", file)
+	}
+	return nil
+}
+
+// HandleViewChange regenerates the default theme outputs for a newly viewed wallpaper.
+// It is registered as a cache view hook when automatic theming is enabled.
+func (h *ThemeHandler) HandleViewChange(wallpaperID string) {
+	wallpaper := h.cache.GetByID(wallpaperID)
+	if wallpaper == nil {
+		h.logger.Warn("Cannot regenerate theme, wallpaper not found", "id", wallpaperID)
+		return
+	}
+
+	if _, err := h.Generate(wallpaper.Path, config.GetDefaultThemePath(), constants.ValidThemeFormats, ""); err != nil {
+		h.logger.Warn("Failed to regenerate theme", "error", err)
+		return
+	}
+	h.logger.Info("Regenerated theme", "wallpaper", wallpaper.Path)
+}
+
+// Generate derives a colour scheme from imagePath and writes the requested formats
+// to outputDir. Every *.tmpl file in templateDir is rendered as well, with the
+// .tmpl suffix stripped from the output name. It returns the written file paths.
+func (h *ThemeHandler) Generate(imagePath, outputDir string, formats []string, templateDir string) ([]string, error) {
+	scheme, err := wallhaven.GenerateColorScheme(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate colour scheme: %w", err)
+	}
+
+	if err := os.MkdirAll(outputDir, constants.DirPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create theme directory: %w", err)
+	}
+
+	var written []string
+	for _, tmpl := range themeTemplates {
+		if !slices.Contains(formats, tmpl.format) {
+			continue
+		}
+		path := filepath.Join(outputDir, tmpl.filename)
+		if err := renderThemeTemplate(tmpl.format, tmpl.body, path, scheme); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+
+	if templateDir == "" {
+		return written, nil
+	}
+
+	userTemplates, err := filepath.Glob(filepath.Join(templateDir, "*.tmpl"))
+	if err != nil {
+		return written, fmt.Errorf("failed to list user templates: %w", err)
+	}
+	for _, tmplPath := range userTemplates {
+		body, err := os.ReadFile(tmplPath)
+		if err != nil {
+			return written, fmt.Errorf("failed to read template %s: %w", tmplPath, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(tmplPath), ".tmpl")
+		path := filepath.Join(outputDir, name)
+		if err := renderThemeTemplate(name, string(body), path, scheme); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+
+	return written, nil
+}
+
+var themeFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"shquote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+	"sub": func(a, b int) int { return a - b },
+}
+
+// renderThemeTemplate executes a template and atomically replaces path with the result
+func renderThemeTemplate(name, body, path string, scheme *wallhaven.ColorScheme) error {
+	tmpl, err := template.New(name).Funcs(themeFuncs).Parse(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, scheme); err != nil {
+		return fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), constants.FilePermissions); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// GetFlags returns the CLI flags for the theme command
+func (h *ThemeHandler) GetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      "outputDir",
+			Aliases:   []string{"od"},
+			Value:     config.GetDefaultThemePath(),
+			TakesFile: true,
+			Usage:     "Directory to write the generated colour scheme files to",
+		},
+		&cli.StringSliceFlag{
+			Name:    "formats",
+			Aliases: []string{"f"},
+			Value:   constants.ValidThemeFormats,
+			Usage:   "Formats to generate: " + strings.Join(constants.ValidThemeFormats, ", "),
+		},
+		&cli.StringFlag{
+			Name:      "templateDir",
+			Aliases:   []string{"td"},
+			Value:     "",
+			TakesFile: true,
+			Usage:     "Directory of additional *.tmpl templates to render",
+		},
+	}
+}
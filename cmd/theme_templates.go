@@ -0,0 +1,115 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import "git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+
+// themeTemplate describes a built-in colour scheme output
+type themeTemplate struct {
+	format   string
+	filename string
+	body     string
+}
+
+// themeTemplates are rendered with a *wallhaven.ColorScheme as data
+var themeTemplates = []themeTemplate{
+	{
+		format:   constants.ThemeFormatJSON,
+		filename: "colors.json",
+		body: `{
+  "wallpaper": {{json .Wallpaper}},
+  "special": {
+    "background": "{{.Background}}",
+    "foreground": "{{.Foreground}}",
+    "cursor": "{{.Cursor}}"
+  },
+  "colors": {
+{{- range $i, $c := .Colors}}{{if $i}},{{end}}
+    "color{{$i}}": "{{$c}}"
+{{- end}}
+  }
+}
+`,
+	},
+	{
+		format:   constants.ThemeFormatXresources,
+		filename: "colors.Xresources",
+		body: `*.foreground: {{.Foreground}}
+*.background: {{.Background}}
+*.cursorColor: {{.Cursor}}
+{{range $i, $c := .Colors}}*.color{{$i}}: {{$c}}
+{{end}}`,
+	},
+	{
+		format:   constants.ThemeFormatCSS,
+		filename: "colors.css",
+		body: `:root {
+  --wallpaper: url({{json .Wallpaper}});
+  --background: {{.Background}};
+  --foreground: {{.Foreground}};
+  --cursor: {{.Cursor}};
+{{range $i, $c := .Colors}}  --color{{$i}}: {{$c}};
+{{end}}}
+`,
+	},
+	{
+		format:   constants.ThemeFormatKitty,
+		filename: "colors-kitty.conf",
+		body: `foreground {{.Foreground}}
+background {{.Background}}
+cursor {{.Cursor}}
+{{range $i, $c := .Colors}}color{{$i}} {{$c}}
+{{end}}`,
+	},
+	{
+		format:   constants.ThemeFormatAlacritty,
+		filename: "colors-alacritty.toml",
+		body: `[colors.primary]
+background = "{{.Background}}"
+foreground = "{{.Foreground}}"
+
+[colors.cursor]
+cursor = "{{.Cursor}}"
+text = "{{.Background}}"
+
+[colors.normal]
+black = "{{index .Colors 0}}"
+red = "{{index .Colors 1}}"
+green = "{{index .Colors 2}}"
+yellow = "{{index .Colors 3}}"
+blue = "{{index .Colors 4}}"
+magenta = "{{index .Colors 5}}"
+cyan = "{{index .Colors 6}}"
+white = "{{index .Colors 7}}"
+
+[colors.bright]
+black = "{{index .Colors 8}}"
+red = "{{index .Colors 9}}"
+green = "{{index .Colors 10}}"
+yellow = "{{index .Colors 11}}"
+blue = "{{index .Colors 12}}"
+magenta = "{{index .Colors 13}}"
+cyan = "{{index .Colors 14}}"
+white = "{{index .Colors 15}}"
+`,
+	},
+	{
+		format:   constants.ThemeFormatFoot,
+		filename: "colors-foot.ini",
+		body: `[colors]
+foreground={{.Foreground.Strip}}
+background={{.Background.Strip}}
+{{range $i, $c := .Colors}}{{if lt $i 8}}regular{{$i}}{{else}}bright{{sub $i 8}}{{end}}={{$c.Strip}}
+{{end}}`,
+	},
+	{
+		format:   constants.ThemeFormatShell,
+		filename: "colors.sh",
+		body: `wallpaper={{shquote .Wallpaper}}
+background='{{.Background}}'
+foreground='{{.Foreground}}'
+cursor='{{.Cursor}}'
+{{range $i, $c := .Colors}}color{{$i}}='{{$c}}'
+{{end}}export wallpaper background foreground cursor{{range $i, $c := .Colors}} color{{$i}}{{end}}
+`,
+	},
+}
@@ -0,0 +1,135 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+)
+
+// TrashHandler handles listing, restoring and emptying the trash
+type TrashHandler struct {
+	cache  interfaces.WallpaperCache
+	logger *slog.Logger
+}
+
+// NewTrashHandler creates a new trash handler
+func NewTrashHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *TrashHandler {
+	return &TrashHandler{
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// HandleList lists the wallpapers in the trash
+func (h *TrashHandler) HandleList(ctx context.Context, c *cli.Command) error {
+	out, err := newRenderer(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := h.cache.ListTrash()
+	if err != nil {
+		h.logger.Error("Failed to list trash", "error", err)
+		return err
+	}
+	if !out.text() {
+		return out.render(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("Trash is empty\n")
+		return nil
+	}
+
+	var totalSize int64
+	for _, entry := range entries {
+		totalSize += entry.Size
+		fmt.Printf("%s  %s  %-8s %s (%.2f MB)\n",
+			entry.ID,
+			entry.TrashedAt.Format("2006-01-02 15:04"),
+			entry.Reason,
+			entry.Path,
+			float64(entry.Size)/1024/1024)
+	}
+	fmt.Printf("\n%d wallpapers, %.2f MB\n", len(entries), float64(totalSize)/1024/1024)
+	return nil
+}
+
+// HandleRestore moves wallpapers out of the trash back into the cache. Each
+// argument is a cache ID, wallhaven ID, original path or file name.
+func (h *TrashHandler) HandleRestore(ctx context.Context, c *cli.Command) error {
+	refs := c.Args().Slice()
+	if len(refs) == 0 {
+		return fmt.Errorf("a wallpaper ID or path is required")
+	}
+
+	entries, err := h.cache.ListTrash()
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		entry := findTrashEntry(entries, ref)
+		if entry == nil {
+			return fmt.Errorf("wallpaper not in trash: %s", ref)
+		}
+		wallpaper, err := h.cache.RestoreFromTrash(entry.ID)
+		if err != nil {
+			h.logger.Error("Failed to restore wallpaper", "error", err, "id", entry.ID)
+			return err
+		}
+		fmt.Printf("Restored: %s\n", wallpaper.Path)
+	}
+	return nil
+}
+
+// findTrashEntry matches ref against trashed wallpapers
+func findTrashEntry(entries []*wallhaven.TrashEntry, ref string) *wallhaven.TrashEntry {
+	abs, _ := filepath.Abs(ref)
+	for _, entry := range entries {
+		if entry.ID == ref || entry.Path == abs || filepath.Base(entry.Path) == ref ||
+			wallhaven.WallhavenID(entry.Path) == ref {
+			return entry
+		}
+	}
+	return nil
+}
+
+// HandleEmpty permanently deletes the wallpapers in the trash
+func (h *TrashHandler) HandleEmpty(ctx context.Context, c *cli.Command) error {
+	var count int
+	var err error
+	if olderThan := c.String("olderThan"); olderThan != "" {
+		duration, parseErr := parseDuration(olderThan)
+		if parseErr != nil {
+			return fmt.Errorf("invalid olderThan duration: %w", parseErr)
+		}
+		count, err = h.cache.PurgeTrash(duration)
+	} else {
+		count, err = h.cache.EmptyTrash()
+	}
+	if err != nil {
+		h.logger.Error("Failed to empty trash", "error", err)
+		return err
+	}
+
+	fmt.Printf("Deleted %d wallpapers from the trash\n", count)
+	return nil
+}
+
+// GetEmptyFlags returns the CLI flags for the trash empty command
+func (h *TrashHandler) GetEmptyFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "olderThan",
+			Usage: "Only delete wallpapers trashed longer ago than this (e.g., '7d', '1w')",
+		},
+	}
+}
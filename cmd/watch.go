@@ -0,0 +1,356 @@
+// Package cmd provides command handlers for the CLI
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v3"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/config"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/interfaces"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+)
+
+// WatchHandler handles keeping the cache in sync with watched directories
+type WatchHandler struct {
+	cache    interfaces.WallpaperCache
+	importer *ImportHandler
+	logger   *slog.Logger
+}
+
+// NewWatchHandler creates a new watch handler
+func NewWatchHandler(cache interfaces.WallpaperCache, logger *slog.Logger) *WatchHandler {
+	return &WatchHandler{
+		cache:    cache,
+		importer: NewImportHandler(cache, logger),
+		logger:   logger,
+	}
+}
+
+// pendingChanges collects file events until the files have settled, so partially
+// written files aren't imported and a rename's removal and creation are handled together
+type pendingChanges struct {
+	created map[string]time.Time
+	removed map[string]time.Time
+}
+
+func newPendingChanges() *pendingChanges {
+	return &pendingChanges{
+		created: make(map[string]time.Time),
+		removed: make(map[string]time.Time),
+	}
+}
+
+// due removes and returns the paths that haven't changed for at least settle
+func (p *pendingChanges) due(now time.Time, settle time.Duration) (created, removed []string) {
+	for path, at := range p.created {
+		if now.Sub(at) >= settle {
+			created = append(created, path)
+			delete(p.created, path)
+		}
+	}
+	for path, at := range p.removed {
+		if now.Sub(at) >= settle {
+			removed = append(removed, path)
+			delete(p.removed, path)
+		}
+	}
+	return created, removed
+}
+
+// Handle processes the watch command
+func (h *WatchHandler) Handle(ctx context.Context, c *cli.Command) error {
+	dirs := c.Args().Slice()
+	if len(dirs) == 0 {
+		dirs = []string{c.String("downloadPath")}
+	}
+	for i, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", dir, err)
+		}
+		if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+			return fmt.Errorf("not a directory: %s", dir)
+		}
+		dirs[i] = abs
+	}
+	recursive := c.Bool("recursive")
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Catch up on anything that changed while we weren't watching. Files are matched
+	// first so wallpapers moved in the meantime keep their entries.
+	pending := newPendingChanges()
+	for _, dir := range dirs {
+		files, err := findImages(dir, recursive)
+		if err != nil {
+			h.logger.Warn("Failed to scan directory", "dir", dir, "error", err)
+			continue
+		}
+		for _, file := range files {
+			pending.created[file] = time.Time{}
+		}
+	}
+	h.flush(pending, 0)
+	if err := h.cache.CleanupInvalidEntries(); err != nil {
+		h.logger.Warn("Failed to cleanup invalid cache entries", "error", err)
+	}
+
+	fmt.Printf("Watching %s\n", strings.Join(dirs, ", "))
+
+	if !c.Bool("poll") {
+		err := h.watchNotify(ctx, dirs, recursive, pending)
+		if err == nil {
+			return nil
+		}
+		h.logger.Warn("File notifications unavailable, falling back to polling", "error", err)
+	}
+	return h.watchPoll(ctx, dirs, recursive, c.Duration("interval"), pending)
+}
+
+// watchNotify processes file system notifications until ctx is cancelled. It only
+// returns an error when notifications can't be set up.
+func (h *WatchHandler) watchNotify(ctx context.Context, dirs []string, recursive bool, pending *pendingChanges) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	for _, dir := range dirs {
+		if err := addWatches(watcher, dir, recursive); err != nil {
+			return err
+		}
+	}
+
+	settle := constants.WatchSettleDelay * time.Second
+	ticker := time.NewTicker(settle / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			now := time.Now()
+
+			if event.Has(fsnotify.Create) && recursive && !isHidden(event.Name) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addWatches(watcher, event.Name, true); err != nil {
+						h.logger.Warn("Failed to watch directory", "dir", event.Name, "error", err)
+					}
+					// Files may have landed before the watch was added
+					files, _ := findImages(event.Name, true)
+					for _, file := range files {
+						pending.created[file] = now
+					}
+					continue
+				}
+			}
+
+			if !wallhaven.IsImageFile(event.Name) {
+				continue
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				pending.created[event.Name] = now
+			}
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				pending.removed[event.Name] = now
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			h.logger.Warn("File watcher error", "error", err)
+		case <-ticker.C:
+			h.flush(pending, settle)
+		}
+	}
+}
+
+// fileState identifies a version of a file for polling
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watchPoll rescans the directories every interval until ctx is cancelled
+func (h *WatchHandler) watchPoll(ctx context.Context, dirs []string, recursive bool, interval time.Duration, pending *pendingChanges) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	snapshot := scanDirs(dirs, recursive)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			now := time.Now()
+			current := scanDirs(dirs, recursive)
+			for path, state := range current {
+				if previous, ok := snapshot[path]; !ok || previous != state {
+					pending.created[path] = now
+				}
+			}
+			for path := range snapshot {
+				if _, ok := current[path]; !ok {
+					pending.removed[path] = now
+				}
+			}
+			snapshot = current
+
+			// A file unchanged across two scans has settled
+			h.flush(pending, min(interval, constants.WatchSettleDelay*time.Second))
+		}
+	}
+}
+
+// flush applies the pending changes that have settled. New files are handled
+// before removals so a rename updates the existing entry instead of replacing it.
+func (h *WatchHandler) flush(pending *pendingChanges, settle time.Duration) {
+	created, removed := pending.due(time.Now(), settle)
+	for _, path := range created {
+		h.handleCreated(path)
+	}
+	for _, path := range removed {
+		h.handleRemoved(path)
+	}
+}
+
+// handleCreated registers a new image, or points an existing entry at it when the
+// image is a cached wallpaper that was moved
+func (h *WatchHandler) handleCreated(path string) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	if h.cache.GetByPath(path) != nil {
+		return
+	}
+
+	hash, _, err := wallhaven.CalculateFileHash(path)
+	if err != nil {
+		h.logger.Warn("Failed to calculate hash", "path", path, "error", err)
+		return
+	}
+
+	if existing := h.cache.GetByHash(hash); existing != nil {
+		if _, err := os.Stat(existing.Path); os.IsNotExist(err) {
+			if err := h.cache.UpdatePath(existing.ID, path); err != nil {
+				h.logger.Warn("Failed to update moved wallpaper", "path", path, "error", err)
+				return
+			}
+			fmt.Printf("Moved: %s -> %s\n", existing.Path, path)
+			return
+		}
+		h.logger.Info("Ignoring copy of cached wallpaper", "path", path, "existing", existing.Path)
+		return
+	}
+
+	if _, err := h.importer.importFile(path, "", importInPlace, false); err != nil {
+		h.logger.Warn("Failed to import wallpaper", "path", path, "error", err)
+	}
+}
+
+// handleRemoved moves the cache entry of an image deleted outside the application
+// to the trash, so its metadata can be restored if the file comes back
+func (h *WatchHandler) handleRemoved(path string) {
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+
+	existing := h.cache.GetByPath(path)
+	if existing == nil {
+		return
+	}
+	if err := h.cache.RemoveWallpaper(existing.ID); err != nil {
+		h.logger.Warn("Failed to remove deleted wallpaper", "path", path, "error", err)
+		return
+	}
+	fmt.Printf("Removed: %s\n", path)
+}
+
+// addWatches watches dir, and its non-hidden subdirectories when recursive is set
+func addWatches(watcher *fsnotify.Watcher, dir string, recursive bool) error {
+	if !recursive {
+		return watcher.Add(dir)
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// scanDirs returns the state of every image in dirs
+func scanDirs(dirs []string, recursive bool) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, dir := range dirs {
+		files, err := findImages(dir, recursive)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if info, err := os.Stat(file); err == nil {
+				states[file] = fileState{size: info.Size(), modTime: info.ModTime()}
+			}
+		}
+	}
+	return states
+}
+
+// isHidden reports whether the last element of path starts with a dot
+func isHidden(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
+
+// GetFlags returns the CLI flags for the watch command
+func (h *WatchHandler) GetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      "downloadPath",
+			Aliases:   []string{"dp"},
+			Value:     config.GetDefaultDownloadPath(),
+			TakesFile: true,
+			Usage:     "Directory to watch when none are given",
+		},
+		&cli.BoolFlag{
+			Name:    "recursive",
+			Aliases: []string{"r"},
+			Usage:   "Watch subdirectories too",
+		},
+		&cli.BoolFlag{
+			Name:  "poll",
+			Usage: "Rescan directories periodically instead of using file system notifications",
+		},
+		&cli.DurationFlag{
+			Name:    "interval",
+			Aliases: []string{"i"},
+			Value:   constants.DefaultWatchInterval * time.Second,
+			Usage:   "How often to rescan directories when polling",
+		},
+	}
+}
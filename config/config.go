@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
 )
@@ -21,6 +22,15 @@ type Config struct {
 	Ratios      []string `json:"ratios"`
 	AtLeast     string   `json:"at_least"`
 
+	// Near-duplicate detection threshold in bits (0 disables)
+	SimilarityThreshold int `json:"similarity_threshold"`
+
+	// Searches skip wallpapers shown within this window (0 disables)
+	AvoidRecent time.Duration `json:"avoid_recent"`
+
+	// Compose searches from and rank results by the learned preferences
+	Discover bool `json:"discover"`
+
 	// Paths
 	DownloadPath string `json:"download_path"`
 	ScriptPath   string `json:"script_path"`
@@ -47,6 +57,30 @@ func GetDefaultDownloadPath() string {
 	return filepath.Join(home, "Pictures", "Wallpapers")
 }
 
+// GetAppCachePath returns the per-user cache directory for generated files,
+// honouring $XDG_CACHE_HOME
+func GetAppCachePath() string {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "."
+		}
+		base = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(base, constants.AppName)
+}
+
+// GetDefaultThemePath returns the default directory for generated colour schemes
+func GetDefaultThemePath() string {
+	return filepath.Join(GetAppCachePath(), "theme")
+}
+
+// GetDefaultLockscreenPath returns the default path of the generated lockscreen image
+func GetDefaultLockscreenPath() string {
+	return filepath.Join(GetAppCachePath(), "lockscreen.png")
+}
+
 // NewConfig creates a new configuration with defaults
 func NewConfig() *Config {
 	return &Config{
@@ -58,6 +92,7 @@ func NewConfig() *Config {
 		Page:            constants.DefaultMaxPages,
 		Ratios:          constants.DefaultRatios,
 		AtLeast:         constants.DefaultAtLeast,
+		SimilarityThreshold: constants.DefaultSimilarityThreshold,
 		DownloadPath:    GetDefaultDownloadPath(),
 		ScriptPath:      "",
 		CleanupMode:     constants.CleanupModeUnused,
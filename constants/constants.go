@@ -48,11 +48,134 @@ const (
 	CleanupModeUnused  = "unused"
 	CleanupModeOld     = "old"
 	CleanupModeInvalid = "invalid"
+	CleanupModeQuota   = "quota" // Whatever the cache quota would evict
+	CleanupModeOrphans = "orphans" // Images in the download directory the cache doesn't know
+	CleanupModeDuplicates = "duplicates" // All but the best copy of exact and near duplicates
+	CleanupModeLowRated = "low-rated"
+	CleanupModeResolution = "resolution" // Below a minimum resolution or outside the allowed ratios
+	CleanupModeOversize = "oversize"
 )
 
 // Valid cleanup modes
 var ValidCleanupModes = []string{
-	CleanupModeUnused, CleanupModeOld, CleanupModeInvalid,
+	CleanupModeUnused, CleanupModeOld, CleanupModeInvalid, CleanupModeQuota,
+	CleanupModeOrphans, CleanupModeDuplicates, CleanupModeLowRated, CleanupModeResolution, CleanupModeOversize,
+}
+
+// Eviction policies deciding which wallpapers go first when the cache is over quota
+const (
+	EvictionLRU         = "lru"          // Least recently used
+	EvictionLeastUsed   = "least-used"   // Lowest use count
+	EvictionLowestRated = "lowest-rated" // Lowest rating, then least used
+	EvictionOldest      = "oldest"       // Earliest downloaded
+)
+
+// Valid eviction policies
+var ValidEvictionPolicies = []string{EvictionLRU, EvictionLeastUsed, EvictionLowestRated, EvictionOldest}
+
+// Wallpaper source constants for commands that work on a set of cached wallpapers
+const (
+	SourceFavorites = "favorites"
+	SourceHistory   = "history"
+	SourceTags      = "tags"
+	SourceLibrary   = "library" // Every cached wallpaper
+)
+
+// Valid wallpaper sources
+var ValidSources = []string{SourceFavorites, SourceHistory, SourceTags, SourceLibrary}
+
+// Theme output format constants
+const (
+	ThemeFormatJSON       = "json"
+	ThemeFormatXresources = "xresources"
+	ThemeFormatCSS        = "css"
+	ThemeFormatKitty      = "kitty"
+	ThemeFormatAlacritty  = "alacritty"
+	ThemeFormatFoot       = "foot"
+	ThemeFormatShell      = "shell"
+)
+
+// Valid theme formats
+var ValidThemeFormats = []string{
+	ThemeFormatJSON, ThemeFormatXresources, ThemeFormatCSS, ThemeFormatKitty,
+	ThemeFormatAlacritty, ThemeFormatFoot, ThemeFormatShell,
+}
+
+// Crop mode constants for display variants
+const (
+	CropModeCenter = "center"
+	CropModeFocus  = "focus"
+)
+
+// Valid crop modes
+var ValidCropModes = []string{CropModeCenter, CropModeFocus}
+
+// Library document formats
+const (
+	LibraryFormatJSON = "json"
+	LibraryFormatCSV  = "csv"
+)
+
+// Valid library formats
+var ValidLibraryFormats = []string{LibraryFormatJSON, LibraryFormatCSV}
+
+// Library merge policies deciding whose rating and favourite status win on conflict
+const (
+	MergePolicyKeepNewer        = "keep-newer"         // The most recently used copy
+	MergePolicyKeepHigherRating = "keep-higher-rating" // The higher rating, favourite if either is
+)
+
+// Valid library merge policies
+var ValidMergePolicies = []string{MergePolicyKeepNewer, MergePolicyKeepHigherRating}
+
+// Output modes for listing commands
+const (
+	OutputText  = "text"
+	OutputJSON  = "json"
+	OutputJSONL = "jsonl" // One JSON object per line
+	OutputTSV   = "tsv"   // Tab-separated with a header row
+)
+
+// Valid output modes
+var ValidOutputModes = []string{OutputText, OutputJSON, OutputJSONL, OutputTSV}
+
+// Status bar output formats
+const (
+	StatusBarWaybar   = "waybar"   // One JSON object per line
+	StatusBarPolybar  = "polybar"  // One line of text
+	StatusBarI3blocks = "i3blocks" // Full text, short text and colour lines
+)
+
+// Valid status bar formats
+var ValidStatusBars = []string{StatusBarWaybar, StatusBarPolybar, StatusBarI3blocks}
+
+// Image preview protocols for the terminal browser
+const (
+	PreviewAuto  = "auto" // Detect from the environment
+	PreviewKitty = "kitty"
+	PreviewSixel = "sixel"
+	PreviewNone  = "none"
+)
+
+// Valid preview protocols
+var ValidPreviewModes = []string{PreviewAuto, PreviewKitty, PreviewSixel, PreviewNone}
+
+// Sources the terminal browser cycles through
+var BrowseSources = []string{SourceHistory, SourceFavorites, SourceLibrary}
+
+// Menu programs the pick command knows how to run
+const (
+	MenuFzf   = "fzf"
+	MenuRofi  = "rofi"
+	MenuDmenu = "dmenu"
+)
+
+// Command lines for the known menu programs. Anything else given as the menu is
+// run as a command line of its own.
+var MenuCommands = map[string][]string{
+	MenuFzf:   {"fzf", "--prompt", "wallpaper> "},
+	MenuRofi:  {"rofi", "-dmenu", "-i", "-p", "wallpaper", "-show-icons"},
+	MenuDmenu: {"dmenu", "-i", "-l", "20", "-p", "wallpaper"},
 }
 
 // Default values
@@ -63,8 +186,26 @@ const (
 	DefaultSort           = SortToplist
 	DefaultOrder          = OrderDesc
 	DefaultMaxPages       = 5
+	DefaultAvoidRecent    = "7d" // Searches skip wallpapers shown within this window
+	MaxExtraSearchPages   = 3    // Further pages searched when every result was shown recently
 	DefaultAtLeast        = "2560x1440"
 	DefaultCleanupOlderThan = "30d"
+	DefaultCleanupMaxRating = 1
+	DefaultCleanupMaxSizeMB = 25
+	RatioTolerance        = 0.01 // Relative difference allowed when matching aspect ratios
+	DefaultSimilarityThreshold = 10 // Max differing bits between perceptual hashes of near-duplicates
+	DefaultListLimit      = 50
+	DefaultContactSheetColumns = 5
+	DefaultCropMode        = CropModeCenter
+	DefaultLockscreenBlur  = 16 // Blur radius in pixels
+	DefaultLockscreenDim   = 30 // Percentage to darken by
+	DefaultBackupRetention = 10 // Number of database backups to keep
+	DefaultStatusBar       = StatusBarWaybar
+	DefaultStatusMaxLength = 40 // Characters of the wallpaper name shown in bars
+	DefaultPreviewCellWidth  = 8  // Assumed cell size in pixels when the terminal doesn't say
+	DefaultPreviewCellHeight = 16
+	DefaultMenu              = MenuFzf
+	DefaultEvictionPolicy    = EvictionLRU
 )
 
 // Default ratios
@@ -76,6 +217,8 @@ const (
 	AppVersion  = "2.0.0"
 	UserAgent   = "wallhaven_dl/2.0"
 	CacheDir    = ".cache"
+	DatabaseFile = "wallpapers.db"
+	BackupDir   = "backups"
 	MetadataFile = "metadata.json"
 )
 
@@ -92,12 +235,79 @@ const (
 // Cache constants
 const (
 	MaxHistorySize   = 100
+	MaxNavigationSize = 100 // Entries kept on the previous/next stack
 	MaxCacheSize     = 1000 // Maximum number of wallpapers in cache
 	MaxCacheSizeMB   = 5000 // Maximum cache size in megabytes (5GB)
+	EvictionTargetPercent = 90 // Evict down to this percentage of a quota once it is exceeded
 	MinRating        = 1
 	MaxRating        = 5
+	DatabaseBusyTimeout = 5000 // milliseconds to wait for another process's lock
 )
 
+// Thumbnail constants (matching wallhaven's small thumbnails)
+const (
+	ThumbnailDir    = "thumbs"
+	ThumbnailWidth  = 300
+	ThumbnailHeight = 200
+	VariantsDir     = ".variants"
+)
+
+// Preference learning constants
+const (
+	NeutralRating        = 3   // Ratings above this count as liked and below as disliked
+	FavoriteWeight       = 2.0 // Added to the score of a favourite
+	MaxUseWeight         = 1.0 // Most that repeated use adds to a wallpaper's score
+	DiscoverTopTags      = 5   // Liked tags discover picks its search tag from
+	DiscoverExcludedTags = 5   // Most disliked tags discover excludes
+	DiscoverCandidates   = 5   // Best ranked results discover picks from
+)
+
+// Trash constants
+const (
+	TrashDir             = "trash"
+	DefaultTrashDays     = 30 // Days a trashed wallpaper is kept before it is purged
+	TrashReasonRemoved   = "removed"
+	TrashReasonCleanup   = "cleanup"
+	TrashReasonEvicted   = "evicted"
+	TrashReasonBlocked   = "blocked"
+	TrashReasonDuplicate = "duplicate"
+)
+
+// Blocklist kinds
+const (
+	BlockKindWallpaper = "wallpaper" // A wallhaven wallpaper ID
+	BlockKindTag       = "tag"
+	BlockKindUploader  = "uploader"
+)
+
+// Valid blocklist kinds
+var ValidBlockKinds = []string{BlockKindWallpaper, BlockKindTag, BlockKindUploader}
+
+// Playlist play modes
+const (
+	PlaylistModeSequential = "sequential" // In playlist order, starting over after the last
+	PlaylistModeShuffle    = "shuffle"    // Every wallpaper once per round, in random order
+)
+
+// Valid playlist play modes
+var ValidPlaylistModes = []string{PlaylistModeSequential, PlaylistModeShuffle}
+
+// Watch constants
+const (
+	DefaultWatchInterval = 5 // seconds between scans when file notifications are unavailable
+	WatchSettleDelay     = 2 // seconds a file must be left alone before it's processed
+)
+
+// Status constants
+const (
+	DefaultStatusInterval = 2 // seconds between checks for a new wallpaper in follow mode
+	StatusFavoriteColor   = "#e5c07b"
+	StatusMaxTags         = 3 // Tags shown in bar text; tooltips list them all
+)
+
+// Image file extensions recognised when importing
+var ImageExtensions = []string{".jpg", ".jpeg", ".png"}
+
 // File permission constants
 const (
 	DirPermissions  = 0o755
@@ -18,38 +18,105 @@ type WallpaperCache interface {
 
 	// Retrieval operations
 	GetCurrent() *wallhaven.WallpaperMetadata
-	GetPrevious() *wallhaven.WallpaperMetadata
-	GetNext() *wallhaven.WallpaperMetadata
+	GetPrevious(steps int) *wallhaven.WallpaperMetadata
+	GetNext(steps int) *wallhaven.WallpaperMetadata
 	GetByID(id string) *wallhaven.WallpaperMetadata
 	GetHistory(limit int) []*wallhaven.WallpaperMetadata
+	GetHistoryBetween(since, until time.Time) []*wallhaven.WallpaperMetadata
+	GetAll() []*wallhaven.WallpaperMetadata
 	FindDuplicate(hash string) *wallhaven.WallpaperMetadata
-	GetStatistics() map[string]interface{}
+	GetByPath(filePath string) *wallhaven.WallpaperMetadata
+	GetByHash(hash string) *wallhaven.WallpaperMetadata
+	FindSimilar(phash uint64, maxDistance int) []*wallhaven.WallpaperMetadata
+	GetStatistics(since, until time.Time) (*wallhaven.Statistics, error)
 
 	// View state management
 	SetCurrentView(wallpaperID string) error
+	Navigate(steps int) (*wallhaven.WallpaperMetadata, error)
 	GetCurrentView() string
 
 	// Cleanup operations
 	GetOldWallpapers(olderThan time.Duration) []*wallhaven.WallpaperMetadata
 	GetUnusedWallpapers() []*wallhaven.WallpaperMetadata
+	PlanEviction() (*wallhaven.EvictionPlan, error)
+
+	// Preference learning
+	LearnPreferences() (*wallhaven.Preferences, error)
+
+	// Blocklist
+	Block(kind, value string) error
+	Unblock(kind, value string) (bool, error)
+	ListBlocked() ([]*wallhaven.BlockEntry, error)
+	GetBlocklist() (*wallhaven.Blocklist, error)
+
+	// Playlists
+	CreatePlaylist(name, mode string) error
+	DeletePlaylist(name string) error
+	GetPlaylist(name string) (*wallhaven.Playlist, error)
+	ListPlaylists() ([]*wallhaven.Playlist, error)
+	GetActivePlaylist() *wallhaven.Playlist
+	SetActivePlaylist(name string) error
+	SetPlaylistMode(name, mode string) error
+	ResetPlaylist(name string) error
+	AddToPlaylist(name string, ids []string, at int) (int, error)
+	RemoveFromPlaylist(name string, ids []string) (int, error)
+	GetPlaylistItems(name string) ([]*wallhaven.WallpaperMetadata, error)
+	AdvancePlaylist(name string, steps int) (*wallhaven.WallpaperMetadata, error)
+
+	// Trash
+	TrashWallpaper(id, reason string) error
+	TrashFile(filePath, reason string) error
+	ListTrash() ([]*wallhaven.TrashEntry, error)
+	RestoreFromTrash(id string) (*wallhaven.WallpaperMetadata, error)
+	PurgeTrash(olderThan time.Duration) (int, error)
+	EmptyTrash() (int, error)
+
+	// Near-duplicate detection
+	FindNearDuplicates(maxDistance int) [][]*wallhaven.WallpaperMetadata
+	BackfillPerceptualHashes() (int, error)
+	MergeDuplicate(keepID, removeID string) error
 
 	// Favorites and rating
 	ToggleFavorite(id string) error
 	SetRating(id string, rating int) error
 	GetFavorites() []*wallhaven.WallpaperMetadata
 	GetRandomFavorite() *wallhaven.WallpaperMetadata
+	NextShuffled(bag string, candidates []*wallhaven.WallpaperMetadata, weighted bool) (*wallhaven.WallpaperMetadata, error)
+	ResetShuffle(bag string) error
 	GetByRating(minRating int) []*wallhaven.WallpaperMetadata
 
 	// Tags
 	AddTags(id string, tags []string) error
 	RemoveTags(id string, tags []string) error
 	GetByTags(tags []string) []*wallhaven.WallpaperMetadata
+
+	// Thumbnails
+	ThumbnailPath(id string) string
+	GetThumbnail(metadata *wallhaven.WallpaperMetadata) (string, error)
+	GetDisplayVariant(metadata *wallhaven.WallpaperMetadata, width, height int, mode string) (string, error)
+
+	// Importing and library export
+	ImportWallpaper(filePath, sourceURL string, addedAt time.Time) (string, error)
+	UpdatePath(id, filePath string) error
+	ExportLibrary() (*wallhaven.Library, error)
+	MergeLibrary(library *wallhaven.Library, policy string, searchDirs []string) (*wallhaven.LibraryMergeResult, error)
+
+	// Database maintenance
+	MigrationStatus() (int, []wallhaven.MigrationInfo, error)
+	BackupDir() string
+	Backup(dir string, keep int) (string, error)
+	Restore(path string) (string, error)
+	CheckIntegrity() ([]string, error)
+	Vacuum() error
+	Analyze() error
+	GetDatabaseStats() (*wallhaven.DatabaseStats, error)
 }
 
 // WallpaperAPI defines the interface for wallpaper API operations
 type WallpaperAPI interface {
 	SearchWallpapers(ctx context.Context, search *wallhaven.Search) (*wallhaven.SearchResults, error)
 	DownloadWallpaper(ctx context.Context, wallpaper *wallhaven.Wallpaper, dir string) error
+	GetWallpaper(ctx context.Context, id wallhaven.WallpaperID) (*wallhaven.Wallpaper, error)
 }
 
 // ScriptExecutor defines the interface for script execution
@@ -89,5 +156,17 @@ type Validator interface {
 	ValidateSort(value string) error
 	ValidateOrder(value string) error
 	ValidateRating(value int) error
+	ValidatePercentage(value int) error
 	ValidateCleanupMode(value string) error
+	ValidateThemeFormat(value string) error
+	ValidateSource(value string) error
+	ValidateCropMode(value string) error
+	ValidateResolution(value string) error
+	ValidateLibraryFormat(value string) error
+	ValidateMergePolicy(value string) error
+	ValidateOutputMode(value string) error
+	ValidateStatusBar(value string) error
+	ValidatePreviewMode(value string) error
+	ValidateEvictionPolicy(value string) error
+	ValidatePlaylistMode(value string) error
 }
\ No newline at end of file
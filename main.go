@@ -7,12 +7,16 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v3"
 
 	"git.asdf.cafe/abs3nt/wallhaven_dl/cmd"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/src/wallhaven"
+	"git.asdf.cafe/abs3nt/wallhaven_dl/validator"
 )
 
 // wallhavenAPI implements the WallpaperAPI interface
@@ -26,6 +30,10 @@ func (api *wallhavenAPI) DownloadWallpaper(ctx context.Context, wallpaper *wallh
 	return wallpaper.DownloadWithContext(ctx, dir)
 }
 
+func (api *wallhavenAPI) GetWallpaper(ctx context.Context, id wallhaven.WallpaperID) (*wallhaven.Wallpaper, error) {
+	return wallhaven.GetWallpaperWithContext(ctx, id)
+}
+
 var Version = "dev"
 
 func main() {
@@ -39,6 +47,7 @@ func main() {
 	}
 
 	app := createCLIApp(cache, logger)
+	measureQuotaOn(app.Commands, cache)
 	
 	if err := app.Run(context.Background(), os.Args); err != nil {
 		logger.Error("Application failed", "error", err)
@@ -72,17 +81,132 @@ func createCLIApp(cache *wallhaven.WallpaperCache, logger *slog.Logger) *cli.Com
 	searchHandler := cmd.NewSearchHandler(cache, &wallhavenAPI{}, logger)
 	previousHandler := cmd.NewPreviousHandler(cache, logger)
 	nextHandler := cmd.NewNextHandler(cache, logger)
+	gotoHandler := cmd.NewGotoHandler(cache, logger)
 	historyHandler := cmd.NewHistoryHandler(cache, logger)
 	statsHandler := cmd.NewStatsHandler(cache, logger)
 	cleanupHandler := cmd.NewCleanupHandler(cache, logger)
+	trashHandler := cmd.NewTrashHandler(cache, logger)
+	blockHandler := cmd.NewBlockHandler(cache, logger)
 	favoritesHandler := cmd.NewFavoritesHandler(cache, logger)
+	playlistHandler := cmd.NewPlaylistHandler(cache, logger)
 	rateHandler := cmd.NewRateHandler(cache, logger)
+	statusHandler := cmd.NewStatusHandler(cache, logger)
+	browseHandler := cmd.NewBrowseHandler(cache, logger)
+	pickHandler := cmd.NewPickHandler(cache, logger)
+	themeHandler := cmd.NewThemeHandler(cache, logger)
+	dupesHandler := cmd.NewDupesHandler(cache, logger)
+	contactSheetHandler := cmd.NewContactSheetHandler(cache, logger)
+	lockscreenHandler := cmd.NewLockscreenHandler(cache, logger)
+	importHandler := cmd.NewImportHandler(cache, logger)
+	watchHandler := cmd.NewWatchHandler(cache, logger)
+	libraryHandler := cmd.NewLibraryHandler(cache, logger)
+	dbHandler := cmd.NewDBHandler(cache, logger)
+	v := validator.NewValidator()
 
 	return &cli.Command{
 		EnableShellCompletion: true,
 		Version:               Version,
 		Name:                  constants.AppName,
 		Usage:                 "Download wallpapers from wallhaven.cc",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "autoTheme",
+				Usage:   "Regenerate the colour scheme whenever the current wallpaper changes",
+				Sources: cli.EnvVars("WH_AUTO_THEME"),
+			},
+			&cli.BoolFlag{
+				Name:    "autoLockscreen",
+				Usage:   "Regenerate the lockscreen image whenever the current wallpaper changes",
+				Sources: cli.EnvVars("WH_AUTO_LOCKSCREEN"),
+			},
+			&cli.StringFlag{
+				Name:    "fitTo",
+				Usage:   "Scale and crop wallpapers to this resolution (e.g. 2560x1440) before applying them",
+				Sources: cli.EnvVars("WH_FIT_TO"),
+				Validator: v.ValidateResolution,
+			},
+			&cli.StringFlag{
+				Name:    "cropMode",
+				Value:   constants.DefaultCropMode,
+				Usage:   "How to crop fitted wallpapers: " + strings.Join(constants.ValidCropModes, ", "),
+				Sources: cli.EnvVars("WH_CROP_MODE"),
+				Validator: v.ValidateCropMode,
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Value:   constants.OutputText,
+				Usage:   "Output mode for listing commands: " + strings.Join(constants.ValidOutputModes, ", "),
+				Sources: cli.EnvVars("WH_OUTPUT"),
+				Validator: v.ValidateOutputMode,
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Go template applied to each result instead of --output (e.g. '{{.ID}} {{.Path}}')",
+			},
+			&cli.IntFlag{
+				Name:    "quotaCount",
+				Value:   constants.MaxCacheSize,
+				Usage:   "Most wallpapers to keep before evicting, 0 for no limit",
+				Sources: cli.EnvVars("WH_QUOTA_COUNT"),
+			},
+			&cli.IntFlag{
+				Name:    "quotaSizeMB",
+				Value:   constants.MaxCacheSizeMB,
+				Usage:   "Most megabytes of wallpapers to keep before evicting, 0 for no limit",
+				Sources: cli.EnvVars("WH_QUOTA_SIZE_MB"),
+			},
+			&cli.IntFlag{
+				Name:    "quotaDiskPercent",
+				Usage:   "Most of the free disk space the wallpapers may use, as a percentage, 0 for no limit",
+				Sources: cli.EnvVars("WH_QUOTA_DISK_PERCENT"),
+				Validator: v.ValidatePercentage,
+			},
+			&cli.StringFlag{
+				Name:    "evictionPolicy",
+				Value:   constants.DefaultEvictionPolicy,
+				Usage:   "Which wallpapers to evict first when over quota: " + strings.Join(constants.ValidEvictionPolicies, ", "),
+				Sources: cli.EnvVars("WH_EVICTION_POLICY"),
+				Validator: v.ValidateEvictionPolicy,
+			},
+			&cli.IntFlag{
+				Name:    "protectRating",
+				Usage:   "Never evict wallpapers rated at least this, 0 to protect none",
+				Sources: cli.EnvVars("WH_PROTECT_RATING"),
+			},
+			&cli.StringSliceFlag{
+				Name:    "protectTags",
+				Usage:   "Never evict wallpapers with any of these tags",
+				Sources: cli.EnvVars("WH_PROTECT_TAGS"),
+			},
+			&cli.IntFlag{
+				Name:    "trashDays",
+				Value:   constants.DefaultTrashDays,
+				Usage:   "Days to keep removed wallpapers in the trash before deleting them, 0 to keep them until emptied",
+				Sources: cli.EnvVars("WH_TRASH_DAYS"),
+			},
+		},
+		Before: func(ctx context.Context, c *cli.Command) (context.Context, error) {
+			cache.SetQuota(wallhaven.Quota{
+				MaxCount:       c.Int("quotaCount"),
+				MaxBytes:       int64(c.Int("quotaSizeMB")) * 1024 * 1024,
+				MaxDiskPercent: c.Int("quotaDiskPercent"),
+				Policy:         c.String("evictionPolicy"),
+				ProtectRating:  c.Int("protectRating"),
+				ProtectTags:    c.StringSlice("protectTags"),
+			})
+			if days := c.Int("trashDays"); days > 0 {
+				if _, err := cache.PurgeTrash(time.Duration(days) * 24 * time.Hour); err != nil {
+					logger.Warn("Failed to purge trash", "error", err)
+				}
+			}
+			if c.Bool("autoTheme") {
+				cache.OnViewChange(themeHandler.HandleViewChange)
+			}
+			if c.Bool("autoLockscreen") {
+				cache.OnViewChange(lockscreenHandler.HandleViewChange)
+			}
+			return ctx, nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "search",
@@ -118,6 +242,26 @@ func createCLIApp(cache *wallhaven.WallpaperCache, logger *slog.Logger) *cli.Com
 				Action: func(ctx context.Context, c *cli.Command) error {
 					return historyHandler.Handle(ctx, c)
 				},
+				Commands: []*cli.Command{
+					{
+						Name:      "apply",
+						Usage:     "Apply a history entry by its number in the listing or by ID",
+						ArgsUsage: "<number|id>",
+						Flags:     historyHandler.GetApplyFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return historyHandler.HandleApply(ctx, c)
+						},
+					},
+				},
+			},
+			{
+				Name:      "goto",
+				Usage:     "Switch to a wallpaper by cache ID, wallhaven ID or path",
+				ArgsUsage: "<id>",
+				Flags:     gotoHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return gotoHandler.Handle(ctx, c)
+				},
 			},
 			{
 				Name:    "stats",
@@ -137,6 +281,174 @@ func createCLIApp(cache *wallhaven.WallpaperCache, logger *slog.Logger) *cli.Com
 					return cleanupHandler.Handle(ctx, c)
 				},
 			},
+			{
+				Name:  "trash",
+				Usage: "List, restore or permanently delete removed wallpapers",
+				Commands: []*cli.Command{
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   "List wallpapers in the trash",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return trashHandler.HandleList(ctx, c)
+						},
+					},
+					{
+						Name:      "restore",
+						Usage:     "Move wallpapers out of the trash with their ratings, tags and history",
+						ArgsUsage: "<id|path>...",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return trashHandler.HandleRestore(ctx, c)
+						},
+					},
+					{
+						Name:  "empty",
+						Usage: "Permanently delete wallpapers in the trash",
+						Flags: trashHandler.GetEmptyFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return trashHandler.HandleEmpty(ctx, c)
+						},
+					},
+				},
+			},
+			{
+				Name:      "block",
+				Usage:     "Keep a wallpaper, tag or uploader out of searches (the current wallpaper by default)",
+				ArgsUsage: "[id|path...]",
+				Flags:     blockHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return blockHandler.Handle(ctx, c)
+				},
+				Commands: []*cli.Command{
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   "List blocked wallpapers, tags and uploaders",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return blockHandler.HandleList(ctx, c)
+						},
+					},
+					{
+						Name:      "remove",
+						Aliases:   []string{"rm"},
+						Usage:     "Take wallpapers, tags or uploaders off the blocklist",
+						ArgsUsage: "[id...]",
+						Flags:     blockHandler.GetRemoveFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return blockHandler.HandleRemove(ctx, c)
+						},
+					},
+				},
+			},
+			{
+				Name:    "dupes",
+				Aliases: []string{"duplicates"},
+				Usage:   "Find and remove near-duplicate wallpapers",
+				Flags:   dupesHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return dupesHandler.Handle(ctx, c)
+				},
+			},
+			{
+				Name:      "import",
+				Usage:     "Import an existing folder of images into the cache",
+				ArgsUsage: "<dir>",
+				Flags:     importHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return importHandler.Handle(ctx, c)
+				},
+			},
+			{
+				Name:      "watch",
+				Usage:     "Keep the cache in sync with images added, moved or deleted in directories",
+				ArgsUsage: "[dir...]",
+				Flags:     watchHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return watchHandler.Handle(ctx, c)
+				},
+			},
+			{
+				Name:  "library",
+				Usage: "Export and import ratings, favourites, tags and history",
+				Commands: []*cli.Command{
+					{
+						Name:  "export",
+						Usage: "Export the library as JSON or CSV",
+						Flags: libraryHandler.GetExportFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return libraryHandler.HandleExport(ctx, c)
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "Merge an exported library into this one",
+						ArgsUsage: "<file>",
+						Flags:     libraryHandler.GetImportFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return libraryHandler.HandleImport(ctx, c)
+						},
+					},
+				},
+			},
+			{
+				Name:  "db",
+				Usage: "Maintain the wallpaper database",
+				Commands: []*cli.Command{
+					{
+						Name:  "migrate",
+						Usage: "Bring the database schema up to date",
+						Flags: dbHandler.GetMigrateFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return dbHandler.HandleMigrate(ctx, c)
+						},
+					},
+					{
+						Name:  "backup",
+						Usage: "Write a timestamped copy of the database",
+						Flags: dbHandler.GetBackupFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return dbHandler.HandleBackup(ctx, c)
+						},
+					},
+					{
+						Name:      "restore",
+						Usage:     "Replace the database with a backup",
+						ArgsUsage: "<file>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return dbHandler.HandleRestore(ctx, c)
+						},
+					},
+					{
+						Name:    "check",
+						Aliases: []string{"integrity"},
+						Usage:   "Run integrity and foreign key checks",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return dbHandler.HandleCheck(ctx, c)
+						},
+					},
+					{
+						Name:  "vacuum",
+						Usage: "Rebuild the database file to reclaim space",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return dbHandler.HandleVacuum(ctx, c)
+						},
+					},
+					{
+						Name:  "analyze",
+						Usage: "Refresh query planner statistics",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return dbHandler.HandleAnalyze(ctx, c)
+						},
+					},
+					{
+						Name:  "stats",
+						Usage: "Show database size and per-table row counts",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return dbHandler.HandleStats(ctx, c)
+						},
+					},
+				},
+			},
 			{
 				Name:    "favorite",
 				Aliases: []string{"fav"},
@@ -168,6 +480,80 @@ func createCLIApp(cache *wallhaven.WallpaperCache, logger *slog.Logger) *cli.Com
 					},
 				},
 			},
+			{
+				Name:    "playlist",
+				Aliases: []string{"pl"},
+				Usage:   "Manage and play named, ordered sets of wallpapers",
+				Commands: []*cli.Command{
+					{
+						Name:      "create",
+						Usage:     "Create a playlist, optionally with its first wallpapers",
+						ArgsUsage: "<name> [id|path...]",
+						Flags:     playlistHandler.GetCreateFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return playlistHandler.HandleCreate(ctx, c)
+						},
+					},
+					{
+						Name:      "add",
+						Usage:     "Add wallpapers to a playlist (the current wallpaper by default)",
+						ArgsUsage: "<name> [id|path...]",
+						Flags:     playlistHandler.GetAddFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return playlistHandler.HandleAdd(ctx, c)
+						},
+					},
+					{
+						Name:      "remove",
+						Aliases:   []string{"rm"},
+						Usage:     "Remove wallpapers from a playlist (the current wallpaper by default)",
+						ArgsUsage: "<name> [id|path...]",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return playlistHandler.HandleRemove(ctx, c)
+						},
+					},
+					{
+						Name:      "delete",
+						Usage:     "Delete a playlist, keeping its wallpapers",
+						ArgsUsage: "<name>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return playlistHandler.HandleDelete(ctx, c)
+						},
+					},
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   "List playlists",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return playlistHandler.HandleList(ctx, c)
+						},
+					},
+					{
+						Name:      "show",
+						Usage:     "List a playlist's wallpapers in order",
+						ArgsUsage: "<name>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return playlistHandler.HandleShow(ctx, c)
+						},
+					},
+					{
+						Name:      "play",
+						Usage:     "Switch to a playlist's next wallpaper and make next and previous follow it",
+						ArgsUsage: "<name>",
+						Flags:     playlistHandler.GetPlayFlags(),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return playlistHandler.HandlePlay(ctx, c)
+						},
+					},
+					{
+						Name:  "stop",
+						Usage: "Stop playing the active playlist",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return playlistHandler.HandleStop(ctx, c)
+						},
+					},
+				},
+			},
 			{
 				Name:  "rate",
 				Usage: "Rate current wallpaper (1-5 stars)",
@@ -176,6 +562,78 @@ func createCLIApp(cache *wallhaven.WallpaperCache, logger *slog.Logger) *cli.Com
 					return rateHandler.Handle(ctx, c)
 				},
 			},
+			{
+				Name:  "status",
+				Usage: "Print the current wallpaper for waybar, polybar or i3blocks",
+				Flags: statusHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return statusHandler.Handle(ctx, c)
+				},
+			},
+			{
+				Name:  "browse",
+				Aliases: []string{"tui"},
+				Usage: "Browse history, favourites or the whole library in the terminal",
+				Flags: browseHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return browseHandler.Handle(ctx, c)
+				},
+			},
+			{
+				Name:  "pick",
+				Usage: "Choose a wallpaper to apply with fzf, rofi, dmenu or another menu",
+				Flags: pickHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return pickHandler.Handle(ctx, c)
+				},
+			},
+			{
+				Name:    "contact-sheet",
+				Aliases: []string{"sheet"},
+				Usage:   "Render a grid of wallpaper thumbnails to a PNG",
+				Flags:   contactSheetHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return contactSheetHandler.Handle(ctx, c)
+				},
+			},
+			{
+				Name:  "theme",
+				Usage: "Generate a terminal colour scheme from the current wallpaper",
+				Flags: themeHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return themeHandler.Handle(ctx, c)
+				},
+			},
+			{
+				Name:  "lockscreen",
+				Usage: "Generate a blurred and dimmed lockscreen image from the current wallpaper",
+				Flags: lockscreenHandler.GetFlags(),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return lockscreenHandler.Handle(ctx, c)
+				},
+			},
 		},
 	}
-}
\ No newline at end of file
+}
+// measureQuotaOn makes every command with a downloadPath flag measure the disk
+// percentage quota on the disk it saves wallpapers to. The quota itself is set by
+// the root command, which can't see its subcommands' flags.
+func measureQuotaOn(commands []*cli.Command, cache *wallhaven.WallpaperCache) {
+	for _, command := range commands {
+		measureQuotaOn(command.Commands, cache)
+		if !slices.ContainsFunc(command.Flags, func(f cli.Flag) bool { return slices.Contains(f.Names(), "downloadPath") }) {
+			continue
+		}
+
+		before := command.Before
+		command.Before = func(ctx context.Context, c *cli.Command) (context.Context, error) {
+			if err := cache.SetQuotaDir(c.String("downloadPath")); err != nil {
+				return ctx, err
+			}
+			if before != nil {
+				return before(ctx, c)
+			}
+			return ctx, nil
+		}
+	}
+}
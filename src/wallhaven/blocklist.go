@@ -0,0 +1,141 @@
+// Package wallhaven provides the blocklist that keeps unwanted wallpapers out of
+// searches
+package wallhaven
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// BlockEntry is one blocked wallpaper, tag or uploader
+type BlockEntry struct {
+	Kind    string    `json:"kind"`
+	Value   string    `json:"value"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Blocklist holds the wallhaven IDs, tags and uploaders that searches never pick
+type Blocklist struct {
+	Wallpapers []string
+	Tags       []string
+	Uploaders  []string
+}
+
+// Block adds a value of the given kind (one of constants.ValidBlockKinds) to the
+// blocklist
+func (c *WallpaperCache) Block(kind, value string) error {
+	if !slices.Contains(constants.ValidBlockKinds, kind) {
+		return fmt.Errorf("invalid block kind: %s", kind)
+	}
+	if value == "" {
+		return fmt.Errorf("nothing to block")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.db.Exec(`INSERT OR IGNORE INTO blocklist (kind, value, added_at) VALUES (?, ?, ?)`, kind, value, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to block %s: %w", kind, err)
+	}
+	return nil
+}
+
+// Unblock removes a value from the blocklist and reports whether it was there
+func (c *WallpaperCache) Unblock(kind, value string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result, err := c.db.Exec(`DELETE FROM blocklist WHERE kind = ? AND value = ?`, kind, value)
+	if err != nil {
+		return false, fmt.Errorf("failed to unblock %s: %w", kind, err)
+	}
+	removed, err := result.RowsAffected()
+	return removed > 0, err
+}
+
+// ListBlocked returns every blocklist entry, grouped by kind
+func (c *WallpaperCache) ListBlocked() ([]*BlockEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rows, err := c.db.Query(`SELECT kind, value, added_at FROM blocklist ORDER BY kind, added_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklist: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*BlockEntry
+	for rows.Next() {
+		entry := &BlockEntry{}
+		if err := rows.Scan(&entry.Kind, &entry.Value, &entry.AddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan blocklist entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// GetBlocklist returns the blocklist ready to apply to searches
+func (c *WallpaperCache) GetBlocklist() (*Blocklist, error) {
+	entries, err := c.ListBlocked()
+	if err != nil {
+		return nil, err
+	}
+
+	blocklist := &Blocklist{}
+	for _, entry := range entries {
+		switch entry.Kind {
+		case constants.BlockKindWallpaper:
+			blocklist.Wallpapers = append(blocklist.Wallpapers, entry.Value)
+		case constants.BlockKindTag:
+			blocklist.Tags = append(blocklist.Tags, entry.Value)
+		case constants.BlockKindUploader:
+			blocklist.Uploaders = append(blocklist.Uploaders, entry.Value)
+		}
+	}
+	return blocklist, nil
+}
+
+// ApplyTo adds the blocked tags to a query's excluded tags
+func (b *Blocklist) ApplyTo(q *Q) {
+	for _, tag := range b.Tags {
+		if !slices.Contains(q.ExcludeTags, tag) {
+			q.ExcludeTags = append(q.ExcludeTags, tag)
+		}
+	}
+}
+
+// Allows reports whether a wallpaper may be picked. Uploader and tags are only
+// checked when the wallpaper carries them, which search listings don't.
+func (b *Blocklist) Allows(w *Wallpaper) bool {
+	if slices.Contains(b.Wallpapers, w.ID) {
+		return false
+	}
+	if w.Uploader != nil && containsFold(b.Uploaders, w.Uploader.Username) {
+		return false
+	}
+	for _, tag := range w.Tags {
+		if containsFold(b.Tags, tag.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// RemoveBlocked drops the results the blocklist doesn't allow and returns how
+// many were dropped
+func (r *SearchResults) RemoveBlocked(b *Blocklist) int {
+	before := len(r.Data)
+	r.Data = slices.DeleteFunc(r.Data, func(w Wallpaper) bool { return !b.Allows(&w) })
+	return before - len(r.Data)
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, s) })
+}
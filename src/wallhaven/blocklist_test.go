@@ -0,0 +1,62 @@
+package wallhaven
+
+import (
+	"testing"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+func TestWallpaperCache_Blocklist(t *testing.T) {
+	cache, _ := newPopulatedTestCache(t, 0)
+
+	for _, entry := range []BlockEntry{
+		{Kind: constants.BlockKindWallpaper, Value: "abc123"},
+		{Kind: constants.BlockKindTag, Value: "cars"},
+		{Kind: constants.BlockKindUploader, Value: "someone"},
+		{Kind: constants.BlockKindTag, Value: "cars"},
+	} {
+		if err := cache.Block(entry.Kind, entry.Value); err != nil {
+			t.Fatalf("Block(%s, %s) error = %v", entry.Kind, entry.Value, err)
+		}
+	}
+	if err := cache.Block("colour", "red"); err == nil {
+		t.Error("Expected an unknown kind to be rejected")
+	}
+
+	entries, err := cache.ListBlocked()
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 distinct entries, got %d, %v", len(entries), err)
+	}
+
+	blocklist, err := cache.GetBlocklist()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := Q{Tags: []string{"space"}}
+	blocklist.ApplyTo(&q)
+	if got := q.toQuery().Get("q"); got != "+space-cars" {
+		t.Errorf("Expected blocked tags to be excluded from the query, got %q", got)
+	}
+
+	results := &SearchResults{Data: []Wallpaper{
+		{ID: "abc123"},
+		{ID: "def456"},
+		{ID: "ghi789", Uploader: &Uploader{Username: "SomeOne"}},
+		{ID: "jkl012", Tags: []Tag{{Name: "Cars"}}},
+	}}
+	if removed := results.RemoveBlocked(blocklist); removed != 3 {
+		t.Errorf("Expected 3 blocked results, got %d", removed)
+	}
+	if len(results.Data) != 1 || results.Data[0].ID != "def456" {
+		t.Errorf("Unexpected results left: %+v", results.Data)
+	}
+
+	removed, err := cache.Unblock(constants.BlockKindTag, "cars")
+	if err != nil || !removed {
+		t.Fatalf("Unblock() = %v, %v", removed, err)
+	}
+	if removed, _ := cache.Unblock(constants.BlockKindTag, "cars"); removed {
+		t.Error("Expected unblocking twice to report nothing removed")
+	}
+}
@@ -43,8 +43,12 @@ type WallpaperMetadata struct {
 
 // WallpaperCache manages wallpaper metadata and history using SQLite
 type WallpaperCache struct {
-	db *sql.DB
-	mu sync.RWMutex // protects database operations
+	db  *sql.DB
+	dir string
+	mu  sync.RWMutex // protects database operations
+
+	quota     Quota
+	viewHooks []func(wallpaperID string)
 }
 
 // NewWallpaperCache creates a new wallpaper cache instance with SQLite backend
@@ -53,70 +57,27 @@ func NewWallpaperCache(cacheDir string) (*WallpaperCache, error) {
 		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	dbPath := filepath.Join(cacheDir, "wallpapers.db")
-	db, err := sql.Open("sqlite", dbPath)
+	dbPath := filepath.Join(cacheDir, constants.DatabaseFile)
+	db, err := openDatabase(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	cache := &WallpaperCache{db: db}
+	cache := &WallpaperCache{db: db, dir: cacheDir, quota: DefaultQuota()}
 
-	if err := cache.initialize(); err != nil {
+	if err := cache.migrate(); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to initialize database: %w", err)
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return cache, nil
 }
 
-// initialize creates the database schema
-func (c *WallpaperCache) initialize() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS wallpapers (
-		id TEXT PRIMARY KEY,
-		path TEXT NOT NULL,
-		original_url TEXT NOT NULL,
-		hash TEXT NOT NULL,
-		size INTEGER NOT NULL,
-		downloaded_at DATETIME NOT NULL,
-		last_used DATETIME NOT NULL,
-		use_count INTEGER NOT NULL DEFAULT 1,
-		categories TEXT NOT NULL,
-		purities TEXT NOT NULL,
-		resolution TEXT,
-		is_favorite BOOLEAN NOT NULL DEFAULT 0,
-		rating INTEGER NOT NULL DEFAULT 0
-	);
-
-	CREATE TABLE IF NOT EXISTS wallpaper_tags (
-		wallpaper_id TEXT NOT NULL,
-		tag TEXT NOT NULL,
-		PRIMARY KEY (wallpaper_id, tag),
-		FOREIGN KEY (wallpaper_id) REFERENCES wallpapers(id) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS usage_history (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		wallpaper_id TEXT NOT NULL,
-		used_at DATETIME NOT NULL,
-		FOREIGN KEY (wallpaper_id) REFERENCES wallpapers(id) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS view_state (
-		id INTEGER PRIMARY KEY CHECK (id = 1),
-		current_wallpaper_id TEXT,
-		updated_at DATETIME NOT NULL
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_wallpapers_hash ON wallpapers(hash);
-	CREATE INDEX IF NOT EXISTS idx_wallpapers_last_used ON wallpapers(last_used);
-	CREATE INDEX IF NOT EXISTS idx_wallpapers_favorite ON wallpapers(is_favorite);
-	CREATE INDEX IF NOT EXISTS idx_usage_history_wallpaper_id ON usage_history(wallpaper_id);
-	CREATE INDEX IF NOT EXISTS idx_usage_history_used_at ON usage_history(used_at);
-	`
-
-	_, err := c.db.Exec(schema)
-	return err
+// openDatabase opens the database at path. Other processes such as a running
+// watch or status --follow share the file, so wait for their locks rather than
+// failing straight away.
+func openDatabase(path string) (*sql.DB, error) {
+	return sql.Open("sqlite", fmt.Sprintf("%s?_pragma=busy_timeout(%d)", path, constants.DatabaseBusyTimeout))
 }
 
 // Close closes the database connection
@@ -132,15 +93,30 @@ func (c *WallpaperCache) AddWallpaper(wallpaper *Wallpaper, filePath, categories
 	}
 
 	// Get image dimensions
-	resolution, err := getImageResolution(filePath)
+	resolution, err := GetImageResolution(filePath)
 	if err != nil {
 		slog.Warn("Failed to get image resolution", "path", filePath, "error", err)
 		resolution = "" // Leave empty if we can't determine it
 	}
 
+	// Perceptual hash for near-duplicate detection; NULL when the image can't be decoded
+	var phash sql.NullInt64
+	if ph, err := CalculatePerceptualHash(filePath); err != nil {
+		slog.Debug("Failed to calculate perceptual hash", "path", filePath, "error", err)
+	} else {
+		phash = sql.NullInt64{Int64: int64(ph), Valid: true}
+	}
+
 	id := GenerateID(wallpaper.Path)
 	now := time.Now()
 
+	// Uploader, colours and tags feed preference learning when wallhaven provided
+	// them. Wallhaven's tags are kept apart from the user's own in wallhaven_tags.
+	var uploader sql.NullString
+	if wallpaper.Uploader != nil {
+		uploader = sql.NullString{String: wallpaper.Uploader.Username, Valid: true}
+	}
+
 	c.mu.Lock()
 	tx, err := c.db.Begin()
 	if err != nil {
@@ -150,14 +126,21 @@ func (c *WallpaperCache) AddWallpaper(wallpaper *Wallpaper, filePath, categories
 	defer tx.Rollback()
 
 	_, err = tx.Exec(`
-		INSERT INTO wallpapers (id, path, original_url, hash, size, downloaded_at, last_used, use_count, categories, purities, resolution)
-		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?)
-	`, id, filePath, wallpaper.Path, hash, size, now, now, categories, purities, resolution)
+		INSERT INTO wallpapers (id, path, original_url, hash, size, downloaded_at, last_used, use_count, categories, purities, resolution, phash, uploader, colors)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?)
+	`, id, filePath, wallpaper.Path, hash, size, now, now, categories, purities, resolution, phash, uploader, strings.Join(wallpaper.Colors, ","))
 	if err != nil {
 		c.mu.Unlock()
 		return fmt.Errorf("failed to insert wallpaper: %w", err)
 	}
 
+	for _, tag := range wallpaper.Tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO wallhaven_tags (wallpaper_id, tag) VALUES (?, ?)`, id, tag.Name); err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("failed to add tag: %w", err)
+		}
+	}
+
 	// Add to usage history
 	_, err = tx.Exec(`INSERT INTO usage_history (wallpaper_id, used_at) VALUES (?, ?)`, id, now)
 	if err != nil {
@@ -172,11 +155,12 @@ func (c *WallpaperCache) AddWallpaper(wallpaper *Wallpaper, filePath, categories
 	c.mu.Unlock()
 
 	// Enforce cache limits after adding new wallpaper (no lock held)
-	return c.EnforceCacheLimits()
+	_, err = c.EnforceCacheLimits()
+	return err
 }
 
-// getImageResolution returns the resolution of an image as "WIDTHxHEIGHT"
-func getImageResolution(filePath string) (string, error) {
+// GetImageResolution returns the resolution of an image as "WIDTHxHEIGHT"
+func GetImageResolution(filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
@@ -230,20 +214,12 @@ func (c *WallpaperCache) MarkAsUsed(id string) error {
 	return tx.Commit()
 }
 
-// SetCurrentView updates the currently viewed wallpaper
-func (c *WallpaperCache) SetCurrentView(wallpaperID string) error {
+// OnViewChange registers a hook that runs after the current view changes
+func (c *WallpaperCache) OnViewChange(hook func(wallpaperID string)) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	_, err := c.db.Exec(`
-		INSERT INTO view_state (id, current_wallpaper_id, updated_at)
-		VALUES (1, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
-			current_wallpaper_id = excluded.current_wallpaper_id,
-			updated_at = excluded.updated_at
-	`, wallpaperID, time.Now())
-
-	return err
+	c.viewHooks = append(c.viewHooks, hook)
 }
 
 // GetCurrentView returns the ID of the currently viewed wallpaper
@@ -259,174 +235,16 @@ func (c *WallpaperCache) GetCurrentView() string {
 	return wallpaperID
 }
 
-// GetNext returns the wallpaper after the currently viewed one in history
-func (c *WallpaperCache) GetNext() *WallpaperMetadata {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// Get the currently viewed wallpaper
-	currentViewID := ""
-	c.db.QueryRow(`SELECT current_wallpaper_id FROM view_state WHERE id = 1`).Scan(&currentViewID)
-
-	// If no current view, return the most recent from history
-	if currentViewID == "" {
-		var wallpaperID string
-		err := c.db.QueryRow(`
-			SELECT wallpaper_id
-			FROM usage_history
-			GROUP BY wallpaper_id
-			ORDER BY MAX(used_at) DESC
-			LIMIT 1
-		`).Scan(&wallpaperID)
-
-		if err != nil {
-			return nil
-		}
-
-		var metadata WallpaperMetadata
-		err = c.db.QueryRow(`
-			SELECT id, path, original_url, hash, size, downloaded_at, last_used, use_count,
-			       categories, purities, COALESCE(resolution, ''), is_favorite, rating
-			FROM wallpapers
-			WHERE id = ?
-		`, wallpaperID).Scan(&metadata.ID, &metadata.Path, &metadata.OriginalURL, &metadata.Hash,
-			&metadata.Size, &metadata.DownloadedAt, &metadata.LastUsed, &metadata.UseCount,
-			&metadata.Categories, &metadata.Purities, &metadata.Resolution, &metadata.IsFavorite, &metadata.Rating)
-
-		if err != nil {
-			return nil
-		}
-
-		if _, err := os.Stat(metadata.Path); err != nil {
-			return nil
-		}
-
-		metadata.Tags = c.getTags(metadata.ID)
-		return &metadata
-	}
-
-	// Find the wallpaper that comes after the current view in history (more recent)
-	var wallpaperID string
-	err := c.db.QueryRow(`
-		SELECT wallpaper_id
-		FROM usage_history
-		WHERE wallpaper_id IN (
-			SELECT wallpaper_id
-			FROM usage_history
-			GROUP BY wallpaper_id
-			HAVING MAX(used_at) > (
-				SELECT MAX(used_at)
-				FROM usage_history
-				WHERE wallpaper_id = ?
-			)
-		)
-		GROUP BY wallpaper_id
-		ORDER BY MAX(used_at) ASC
-		LIMIT 1
-	`, currentViewID).Scan(&wallpaperID)
-
-	if err != nil {
-		return nil
-	}
-
-	// Get the wallpaper metadata
-	var metadata WallpaperMetadata
-	err = c.db.QueryRow(`
-		SELECT id, path, original_url, hash, size, downloaded_at, last_used, use_count,
-		       categories, purities, COALESCE(resolution, ''), is_favorite, rating
-		FROM wallpapers
-		WHERE id = ?
-	`, wallpaperID).Scan(&metadata.ID, &metadata.Path, &metadata.OriginalURL, &metadata.Hash,
-		&metadata.Size, &metadata.DownloadedAt, &metadata.LastUsed, &metadata.UseCount,
-		&metadata.Categories, &metadata.Purities, &metadata.Resolution, &metadata.IsFavorite, &metadata.Rating)
-
-	if err != nil {
-		return nil
-	}
-
-	// Check if file exists
-	if _, err := os.Stat(metadata.Path); err != nil {
-		return nil
-	}
-
-	metadata.Tags = c.getTags(metadata.ID)
-	return &metadata
-}
-
-// GetPrevious returns the wallpaper before the currently viewed one in history
-func (c *WallpaperCache) GetPrevious() *WallpaperMetadata {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// Get the currently viewed wallpaper
-	currentViewID := ""
-	c.db.QueryRow(`SELECT current_wallpaper_id FROM view_state WHERE id = 1`).Scan(&currentViewID)
-
-	var wallpaperID string
-	var err error
-
-	if currentViewID == "" {
-		// No current view set, return the second most recent from history
-		err = c.db.QueryRow(`
-			SELECT wallpaper_id
-			FROM usage_history
-			GROUP BY wallpaper_id
-			ORDER BY MAX(used_at) DESC
-			LIMIT 1 OFFSET 1
-		`).Scan(&wallpaperID)
-	} else {
-		// Find the wallpaper that comes before the current view in history
-		err = c.db.QueryRow(`
-			SELECT wallpaper_id
-			FROM usage_history
-			WHERE wallpaper_id IN (
-				SELECT wallpaper_id
-				FROM usage_history
-				GROUP BY wallpaper_id
-				HAVING MAX(used_at) < (
-					SELECT MAX(used_at)
-					FROM usage_history
-					WHERE wallpaper_id = ?
-				)
-			)
-			GROUP BY wallpaper_id
-			ORDER BY MAX(used_at) DESC
-			LIMIT 1
-		`, currentViewID).Scan(&wallpaperID)
-	}
-
-	if err != nil {
-		return nil
-	}
-
-	// Get the wallpaper metadata
-	var metadata WallpaperMetadata
-	err = c.db.QueryRow(`
-		SELECT id, path, original_url, hash, size, downloaded_at, last_used, use_count,
-		       categories, purities, COALESCE(resolution, ''), is_favorite, rating
-		FROM wallpapers
-		WHERE id = ?
-	`, wallpaperID).Scan(&metadata.ID, &metadata.Path, &metadata.OriginalURL, &metadata.Hash,
-		&metadata.Size, &metadata.DownloadedAt, &metadata.LastUsed, &metadata.UseCount,
-		&metadata.Categories, &metadata.Purities, &metadata.Resolution, &metadata.IsFavorite, &metadata.Rating)
-	if err != nil {
-		return nil
-	}
-
-	// Check if file exists
-	if _, err := os.Stat(metadata.Path); err != nil {
-		return nil
-	}
-
-	metadata.Tags = c.getTags(metadata.ID)
-	return &metadata
-}
-
 // GetByID returns a wallpaper by its ID
 func (c *WallpaperCache) GetByID(id string) *WallpaperMetadata {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	return c.getByID(id)
+}
+
+// getByID returns a wallpaper by its ID; the caller must hold the lock
+func (c *WallpaperCache) getByID(id string) *WallpaperMetadata {
 	var metadata WallpaperMetadata
 	err := c.db.QueryRow(`
 		SELECT id, path, original_url, hash, size, downloaded_at, last_used, use_count,
@@ -537,179 +355,51 @@ func (c *WallpaperCache) FindDuplicate(hash string) *WallpaperMetadata {
 	return &metadata
 }
 
-// GetStatistics returns statistics about the cache
-func (c *WallpaperCache) GetStatistics() map[string]interface{} {
+// GetHistory returns wallpapers ordered by most recent usage
+func (c *WallpaperCache) GetHistory(limit int) []*WallpaperMetadata {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	var totalCount, validCount int
-	var totalSize int64
-	var oldestDownload, newestDownload time.Time
-
-	c.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM wallpapers`).Scan(&totalCount, &totalSize)
-
-	// Count valid wallpapers (files that exist)
-	rows, err := c.db.Query(`SELECT path FROM wallpapers`)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var path string
-			if rows.Scan(&path) == nil {
-				if _, err := os.Stat(path); err == nil {
-					validCount++
-				}
-			}
-		}
-	}
-
-	c.db.QueryRow(`SELECT MIN(downloaded_at), MAX(downloaded_at) FROM wallpapers`).Scan(&oldestDownload, &newestDownload)
-
-	stats := map[string]interface{}{
-		"total_wallpapers":   totalCount,
-		"valid_wallpapers":   validCount,
-		"invalid_wallpapers": totalCount - validCount,
-		"total_size_mb":      float64(totalSize) / 1024 / 1024,
-		"oldest_download":    oldestDownload,
-		"newest_download":    newestDownload,
-	}
-
-	// Get current and previous wallpaper IDs
-	var currentID, previousID string
-	rows, err = c.db.Query(`
-		SELECT DISTINCT wallpaper_id
-		FROM usage_history
-		ORDER BY used_at DESC
-		LIMIT 2
-	`)
-	if err == nil {
-		defer rows.Close()
-		if rows.Next() {
-			rows.Scan(&currentID)
-			stats["current_wallpaper"] = currentID
-		}
-		if rows.Next() {
-			rows.Scan(&previousID)
-			stats["previous_wallpaper"] = previousID
-		}
-	}
-
-	// Get favorite count
-	var favoriteCount int
-	c.db.QueryRow(`SELECT COUNT(*) FROM wallpapers WHERE is_favorite = 1`).Scan(&favoriteCount)
-	stats["favorite_count"] = favoriteCount
-
-	// Get average rating
-	var avgRating float64
-	c.db.QueryRow(`SELECT COALESCE(AVG(rating), 0) FROM wallpapers WHERE rating > 0`).Scan(&avgRating)
-	stats["average_rating"] = avgRating
-
-	// Get top 5 most used wallpapers
-	type MostUsed struct {
-		ID       string
-		Path     string
-		UseCount int
-	}
-	mostUsed := []MostUsed{}
-	rows, err = c.db.Query(`
-		SELECT id, path, use_count
-		FROM wallpapers
-		ORDER BY use_count DESC
-		LIMIT 5
-	`)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var mu MostUsed
-			if rows.Scan(&mu.ID, &mu.Path, &mu.UseCount) == nil {
-				mostUsed = append(mostUsed, mu)
-			}
-		}
-	}
-	stats["most_used"] = mostUsed
-
-	// Get top 10 most common tags
-	type TagCount struct {
-		Tag   string
-		Count int
-	}
-	topTags := []TagCount{}
-	rows, err = c.db.Query(`
-		SELECT tag, COUNT(*) as count
-		FROM wallpaper_tags
-		GROUP BY tag
-		ORDER BY count DESC
-		LIMIT 10
-	`)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var tc TagCount
-			if rows.Scan(&tc.Tag, &tc.Count) == nil {
-				topTags = append(topTags, tc)
-			}
-		}
+	if limit <= 0 {
+		limit = 50 // Default limit
 	}
-	stats["top_tags"] = topTags
 
-	// Get resolution distribution
-	type ResolutionCount struct {
-		Resolution string
-		Count      int
-	}
-	resolutions := []ResolutionCount{}
-	rows, err = c.db.Query(`
-		SELECT COALESCE(resolution, 'unknown'), COUNT(*) as count
-		FROM wallpapers
-		GROUP BY resolution
-		ORDER BY count DESC
-		LIMIT 10
-	`)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var rc ResolutionCount
-			if rows.Scan(&rc.Resolution, &rc.Count) == nil {
-				resolutions = append(resolutions, rc)
-			}
-		}
+	rows, err := c.db.Query(`
+		SELECT DISTINCT w.id, w.path, w.original_url, w.hash, w.size, w.downloaded_at, w.last_used, w.use_count,
+		       w.categories, w.purities, COALESCE(w.resolution, ''), w.is_favorite, w.rating
+		FROM wallpapers w
+		JOIN usage_history uh ON w.id = uh.wallpaper_id
+		GROUP BY w.id
+		ORDER BY MAX(uh.used_at) DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil
 	}
-	stats["resolutions"] = resolutions
-
-	// Get usage activity (last 7 days, 30 days)
-	var weekCount, monthCount int
-	weekAgo := time.Now().AddDate(0, 0, -7)
-	monthAgo := time.Now().AddDate(0, -1, 0)
-	c.db.QueryRow(`SELECT COUNT(DISTINCT wallpaper_id) FROM usage_history WHERE used_at > ?`, weekAgo).Scan(&weekCount)
-	c.db.QueryRow(`SELECT COUNT(DISTINCT wallpaper_id) FROM usage_history WHERE used_at > ?`, monthAgo).Scan(&monthCount)
-	stats["unique_wallpapers_last_week"] = weekCount
-	stats["unique_wallpapers_last_month"] = monthCount
-
-	// Total usage history entries
-	var historyCount int
-	c.db.QueryRow(`SELECT COUNT(*) FROM usage_history`).Scan(&historyCount)
-	stats["total_history_entries"] = historyCount
+	defer rows.Close()
 
-	return stats
+	return c.scanWallpapers(rows)
 }
 
-// GetHistory returns wallpapers ordered by most recent usage
-func (c *WallpaperCache) GetHistory(limit int) []*WallpaperMetadata {
+// GetHistoryBetween returns every wallpaper used within the window, most recently
+// used first. A zero since or until leaves that end of the window open.
+func (c *WallpaperCache) GetHistoryBetween(since, until time.Time) []*WallpaperMetadata {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if limit <= 0 {
-		limit = 50 // Default limit
+	if until.IsZero() {
+		until = time.Now().AddDate(100, 0, 0)
 	}
 
 	rows, err := c.db.Query(`
-		SELECT DISTINCT w.id, w.path, w.original_url, w.hash, w.size, w.downloaded_at, w.last_used, w.use_count,
+		SELECT w.id, w.path, w.original_url, w.hash, w.size, w.downloaded_at, w.last_used, w.use_count,
 		       w.categories, w.purities, COALESCE(w.resolution, ''), w.is_favorite, w.rating
 		FROM wallpapers w
 		JOIN usage_history uh ON w.id = uh.wallpaper_id
+		WHERE uh.used_at >= ? AND uh.used_at <= ?
 		GROUP BY w.id
 		ORDER BY MAX(uh.used_at) DESC
-		LIMIT ?
-	`, limit)
+	`, since, until)
 	if err != nil {
 		return nil
 	}
@@ -785,34 +475,9 @@ func (c *WallpaperCache) scanWallpapers(rows *sql.Rows) []*WallpaperMetadata {
 	return wallpapers
 }
 
-// RemoveWallpaper removes a wallpaper from the cache and deletes the file
+// RemoveWallpaper moves a wallpaper and its file to the trash
 func (c *WallpaperCache) RemoveWallpaper(id string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Get the path first
-	var path string
-	err := c.db.QueryRow(`SELECT path FROM wallpapers WHERE id = ?`, id).Scan(&path)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("wallpaper not found in cache: %s", id)
-		}
-		return fmt.Errorf("failed to query wallpaper: %w", err)
-	}
-
-	// Remove file
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-		slog.Warn("Failed to remove wallpaper file", "path", path, "error", err)
-		return fmt.Errorf("failed to remove wallpaper file: %w", err)
-	}
-
-	// Remove from database (CASCADE will handle tags and history)
-	_, err = c.db.Exec(`DELETE FROM wallpapers WHERE id = ?`, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete wallpaper from database: %w", err)
-	}
-
-	return nil
+	return c.TrashWallpaper(id, constants.TrashReasonRemoved)
 }
 
 // CleanupInvalidEntries removes entries for files that no longer exist
@@ -846,17 +511,24 @@ func (c *WallpaperCache) CleanupInvalidEntries() error {
 	}
 	defer tx.Rollback()
 
+	var deleted []string
 	for _, id := range toRemove {
 		_, err := tx.Exec(`DELETE FROM wallpapers WHERE id = ?`, id)
 		if err != nil {
 			slog.Warn("Failed to delete invalid entry", "id", id, "error", err)
+			continue
 		}
+		deleted = append(deleted, id)
 	}
 
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	for _, id := range deleted {
+		c.removeDerived(id)
+	}
+
 	slog.Info("Cleaned up invalid cache entries", "count", len(toRemove))
 	return nil
 }
@@ -1035,6 +707,25 @@ func (c *WallpaperCache) GetByRating(minRating int) []*WallpaperMetadata {
 	return c.scanWallpapers(rows)
 }
 
+// GetAll returns every wallpaper in the cache, most recently used first
+func (c *WallpaperCache) GetAll() []*WallpaperMetadata {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rows, err := c.db.Query(`
+		SELECT id, path, original_url, hash, size, downloaded_at, last_used, use_count,
+		       categories, purities, COALESCE(resolution, ''), is_favorite, rating
+		FROM wallpapers
+		ORDER BY last_used DESC, downloaded_at DESC
+	`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	return c.scanWallpapers(rows)
+}
+
 // GetByTags returns wallpapers that have all the specified tags
 func (c *WallpaperCache) GetByTags(tags []string) []*WallpaperMetadata {
 	c.mu.RLock()
@@ -1083,80 +774,6 @@ func (c *WallpaperCache) GetRandomFavorite() *WallpaperMetadata {
 	return favorites[rand.IntN(len(favorites))]
 }
 
-// EnforceCacheLimits removes least recently used wallpapers if cache exceeds limits
-func (c *WallpaperCache) EnforceCacheLimits() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	var totalCount int
-	var totalSize int64
-	c.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM wallpapers`).Scan(&totalCount, &totalSize)
-
-	// Check if we're within limits
-	if totalCount <= constants.MaxCacheSize && totalSize <= int64(constants.MaxCacheSizeMB)*1024*1024 {
-		return nil
-	}
-
-	// Calculate targets (90% of max)
-	targetCount := constants.MaxCacheSize * 90 / 100
-	targetSize := int64(constants.MaxCacheSizeMB) * 1024 * 1024 * 90 / 100
-
-	// Get wallpapers to remove (oldest, non-favorite first)
-	rows, err := c.db.Query(`
-		SELECT id, path, size
-		FROM wallpapers
-		WHERE is_favorite = 0
-		ORDER BY last_used ASC
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to query wallpapers for cleanup: %w", err)
-	}
-	defer rows.Close()
-
-	var removed int
-	currentSize := totalSize
-	currentCount := totalCount
-
-	tx, err := c.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	for rows.Next() && (currentCount > targetCount || currentSize > targetSize) {
-		var id, path string
-		var size int64
-		if rows.Scan(&id, &path, &size) != nil {
-			continue
-		}
-
-		// Remove file
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-			slog.Warn("Failed to remove wallpaper during cache cleanup", "path", path, "error", err)
-		}
-
-		// Remove from database
-		_, err := tx.Exec(`DELETE FROM wallpapers WHERE id = ?`, id)
-		if err != nil {
-			slog.Warn("Failed to delete wallpaper from database", "id", id, "error", err)
-			continue
-		}
-
-		currentSize -= size
-		currentCount--
-		removed++
-	}
-
-	if removed > 0 {
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit cleanup transaction: %w", err)
-		}
-		slog.Info("Enforced cache limits", "removed", removed, "remaining", currentCount)
-	}
-
-	return nil
-}
-
 // GetUsageHistory returns the usage history for a wallpaper
 func (c *WallpaperCache) GetUsageHistory(id string, limit int) ([]time.Time, error) {
 	c.mu.RLock()
@@ -1206,3 +823,14 @@ func GenerateID(url string) string {
 	hash := sha256.Sum256([]byte(url))
 	return fmt.Sprintf("%x", hash)[:16]
 }
+
+// WallhavenID extracts the wallhaven ID from a "wallhaven-<id>.<ext>" file name,
+// returning an empty string for files that don't follow that naming
+func WallhavenID(filePath string) string {
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	id, ok := strings.CutPrefix(name, "wallhaven-")
+	if !ok || id == "" {
+		return ""
+	}
+	return id
+}
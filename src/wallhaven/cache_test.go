@@ -1,6 +1,7 @@
 package wallhaven
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -28,9 +29,12 @@ func TestNewWallpaperCache(t *testing.T) {
 	}
 
 	// Check initial state
-	stats := cache.GetStatistics()
-	if stats["total_wallpapers"].(int) != 0 {
-		t.Errorf("Expected empty cache, got %d wallpapers", stats["total_wallpapers"])
+	stats, err := cache.GetStatistics(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetStatistics() error = %v", err)
+	}
+	if stats.TotalWallpapers != 0 {
+		t.Errorf("Expected empty cache, got %d wallpapers", stats.TotalWallpapers)
 	}
 }
 
@@ -60,9 +64,12 @@ func TestWallpaperCache_AddWallpaper(t *testing.T) {
 	}
 
 	// Verify wallpaper was added
-	stats := cache.GetStatistics()
-	if stats["total_wallpapers"].(int) != 1 {
-		t.Errorf("Expected 1 wallpaper, got %d", stats["total_wallpapers"])
+	stats, err := cache.GetStatistics(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetStatistics() error = %v", err)
+	}
+	if stats.TotalWallpapers != 1 {
+		t.Errorf("Expected 1 wallpaper, got %d", stats.TotalWallpapers)
 	}
 
 	// Verify we can retrieve it
@@ -322,6 +329,42 @@ func TestWallpaperCache_Tags(t *testing.T) {
 	}
 }
 
+func TestWallpaperCache_GetHistoryBetween(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache, err := NewWallpaperCache(filepath.Join(tmpDir, ".cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	// Four wallpapers used a day apart, the last one most recently
+	var ids []string
+	for i := range 4 {
+		testFile := filepath.Join(tmpDir, fmt.Sprintf("test%d.jpg", i))
+		if err := os.WriteFile(testFile, []byte(fmt.Sprintf("content %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wallpaper := &Wallpaper{Path: fmt.Sprintf("https://example.com/test%d.jpg", i)}
+		if err := cache.AddWallpaper(wallpaper, testFile, "010", "110"); err != nil {
+			t.Fatal(err)
+		}
+		id := GenerateID(wallpaper.Path)
+		usedAt := time.Now().AddDate(0, 0, i-4)
+		if _, err := cache.db.Exec(`UPDATE usage_history SET used_at = ? WHERE wallpaper_id = ?`, usedAt, id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	history := cache.GetHistoryBetween(time.Now().AddDate(0, 0, -3).Add(-time.Hour), time.Now().AddDate(0, 0, -1).Add(-time.Hour))
+	if len(history) != 2 || history[0].ID != ids[2] || history[1].ID != ids[1] {
+		t.Errorf("Expected the two wallpapers used in the window, newest first, got %d", len(history))
+	}
+	if got := len(cache.GetHistoryBetween(time.Time{}, time.Time{})); got != 4 {
+		t.Errorf("Expected an open window to return all 4, got %d", got)
+	}
+}
+
 func TestGenerateID(t *testing.T) {
 	url1 := "https://example.com/test1.jpg"
 	url2 := "https://example.com/test2.jpg"
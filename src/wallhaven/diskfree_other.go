@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd)
+
+// Package wallhaven provides free disk space lookups for cache quotas
+package wallhaven
+
+import "fmt"
+
+// diskFree isn't implemented on this platform, so disk percentage quotas can't be used
+func diskFree(path string) (int64, error) {
+	return 0, fmt.Errorf("free disk space is not available on this platform")
+}
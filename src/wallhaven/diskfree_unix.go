@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+
+// Package wallhaven provides free disk space lookups for cache quotas
+package wallhaven
+
+import "golang.org/x/sys/unix"
+
+// diskFree returns the bytes available to unprivileged users on the filesystem
+// holding path
+func diskFree(path string) (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return int64(uint64(stat.Bavail) * uint64(stat.Bsize)), nil
+}
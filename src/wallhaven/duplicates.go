@@ -0,0 +1,266 @@
+// Package wallhaven provides near-duplicate detection for cached wallpapers
+package wallhaven
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// phashEntry is a wallpaper ID paired with its perceptual hash
+type phashEntry struct {
+	id    string
+	phash uint64
+}
+
+// loadPerceptualHashes returns every stored perceptual hash; the caller must hold the lock
+func (c *WallpaperCache) loadPerceptualHashes() ([]phashEntry, error) {
+	rows, err := c.db.Query(`SELECT id, phash FROM wallpapers WHERE phash IS NOT NULL ORDER BY downloaded_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query perceptual hashes: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []phashEntry
+	for rows.Next() {
+		var id string
+		var phash int64
+		if rows.Scan(&id, &phash) == nil {
+			entries = append(entries, phashEntry{id: id, phash: uint64(phash)})
+		}
+	}
+	return entries, rows.Err()
+}
+
+// FindSimilar returns wallpapers whose perceptual hash is within maxDistance bits
+// of phash, closest first
+func (c *WallpaperCache) FindSimilar(phash uint64, maxDistance int) []*WallpaperMetadata {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries, err := c.loadPerceptualHashes()
+	if err != nil {
+		return nil
+	}
+
+	type match struct {
+		id       string
+		distance int
+	}
+	var matches []match
+	for _, entry := range entries {
+		if d := HammingDistance(phash, entry.phash); d <= maxDistance {
+			matches = append(matches, match{id: entry.id, distance: d})
+		}
+	}
+	slices.SortFunc(matches, func(a, b match) int { return cmp.Compare(a.distance, b.distance) })
+
+	var similar []*WallpaperMetadata
+	for _, m := range matches {
+		if metadata := c.getByID(m.id); metadata != nil {
+			similar = append(similar, metadata)
+		}
+	}
+	return similar
+}
+
+// FindNearDuplicates groups cached wallpapers whose perceptual hashes are within
+// maxDistance bits of each other. Every member of a group is within maxDistance of
+// every other, so whichever copy is kept is a near-duplicate of each one dropped.
+// Only groups with at least two existing files are returned.
+func (c *WallpaperCache) FindNearDuplicates(maxDistance int) [][]*WallpaperMetadata {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries, err := c.loadPerceptualHashes()
+	if err != nil {
+		return nil
+	}
+
+	var live []phashEntry
+	metadata := make(map[string]*WallpaperMetadata)
+	for _, entry := range entries {
+		if m := c.getByID(entry.id); m != nil {
+			live = append(live, entry)
+			metadata[entry.id] = m
+		}
+	}
+
+	// Admit a wallpaper to a group only when it is close to every member. Linking
+	// pairs alone chains groups together: A close to B and B close to C would put A
+	// and C in one group however far apart they are.
+	grouped := make([]bool, len(live))
+	var groups [][]*WallpaperMetadata
+	for i := range live {
+		if grouped[i] {
+			continue
+		}
+		members := []phashEntry{live[i]}
+		for j := i + 1; j < len(live); j++ {
+			if grouped[j] {
+				continue
+			}
+			near := !slices.ContainsFunc(members, func(m phashEntry) bool {
+				return HammingDistance(m.phash, live[j].phash) > maxDistance
+			})
+			if near {
+				members = append(members, live[j])
+				grouped[j] = true
+			}
+		}
+		if len(members) < 2 {
+			continue
+		}
+
+		group := make([]*WallpaperMetadata, len(members))
+		for k, m := range members {
+			group[k] = metadata[m.id]
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// BackfillPerceptualHashes computes perceptual hashes for wallpapers cached before
+// they were recorded. It returns the number of wallpapers updated.
+func (c *WallpaperCache) BackfillPerceptualHashes() (int, error) {
+	c.mu.RLock()
+	rows, err := c.db.Query(`SELECT id, path FROM wallpapers WHERE phash IS NULL`)
+	if err != nil {
+		c.mu.RUnlock()
+		return 0, fmt.Errorf("failed to query wallpapers: %w", err)
+	}
+
+	pending := make(map[string]string)
+	for rows.Next() {
+		var id, path string
+		if rows.Scan(&id, &path) == nil {
+			pending[id] = path
+		}
+	}
+	rows.Close()
+	c.mu.RUnlock()
+
+	// Hash outside the lock since decoding full-size images is slow
+	hashes := make(map[string]uint64, len(pending))
+	for id, path := range pending {
+		phash, err := CalculatePerceptualHash(path)
+		if err != nil {
+			slog.Debug("Failed to calculate perceptual hash", "path", path, "error", err)
+			continue
+		}
+		hashes[id] = phash
+	}
+
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, phash := range hashes {
+		if _, err := tx.Exec(`UPDATE wallpapers SET phash = ? WHERE id = ?`, int64(phash), id); err != nil {
+			return 0, fmt.Errorf("failed to store perceptual hash: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(hashes), nil
+}
+
+// MergeDuplicate folds the wallpaper removeID into keepID and moves removeID and
+// its file to the trash. Favourite status, the higher rating, tags, use counts,
+// usage history and playlist membership are carried over so no curation is lost.
+// The merge and the trashing happen in one transaction. Restoring removeID later
+// brings back its own tags and history without taking them off keepID, so both
+// then count its uses.
+func (c *WallpaperCache) MergeDuplicate(keepID, removeID string) error {
+	if keepID == removeID {
+		return fmt.Errorf("cannot merge wallpaper into itself: %s", keepID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var keepPath, removePath string
+	if err := c.db.QueryRow(`SELECT path FROM wallpapers WHERE id = ?`, keepID).Scan(&keepPath); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("wallpaper not found in cache: %s", keepID)
+		}
+		return fmt.Errorf("failed to query wallpaper: %w", err)
+	}
+	if err := c.db.QueryRow(`SELECT path FROM wallpapers WHERE id = ?`, removeID).Scan(&removePath); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("wallpaper not found in cache: %s", removeID)
+		}
+		return fmt.Errorf("failed to query wallpaper: %w", err)
+	}
+
+	statements := []string{
+		`UPDATE wallpapers SET
+			is_favorite = is_favorite OR (SELECT is_favorite FROM wallpapers WHERE id = ?2),
+			rating = MAX(rating, (SELECT rating FROM wallpapers WHERE id = ?2)),
+			use_count = use_count + (SELECT use_count FROM wallpapers WHERE id = ?2)
+		WHERE id = ?1`,
+		`INSERT OR IGNORE INTO wallpaper_tags (wallpaper_id, tag)
+		SELECT ?1, tag FROM wallpaper_tags WHERE wallpaper_id = ?2`,
+		`INSERT OR IGNORE INTO wallhaven_tags (wallpaper_id, tag)
+		SELECT ?1, tag FROM wallhaven_tags WHERE wallpaper_id = ?2`,
+		`INSERT INTO usage_history (wallpaper_id, used_at)
+		SELECT ?1, used_at FROM usage_history WHERE wallpaper_id = ?2`,
+		`UPDATE navigation_stack SET wallpaper_id = ?1 WHERE wallpaper_id = ?2`,
+		`UPDATE view_state SET current_wallpaper_id = ?1 WHERE current_wallpaper_id = ?2`,
+		`UPDATE OR IGNORE playlist_items SET wallpaper_id = ?1 WHERE wallpaper_id = ?2`,
+		`DELETE FROM playlist_items WHERE wallpaper_id = ?2`,
+	}
+	merge := func(tx *sql.Tx) error {
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt, keepID, removeID); err != nil {
+				return fmt.Errorf("failed to merge duplicate: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if removePath == keepPath {
+		// Both entries point at one file, so there is nothing to trash
+		statements = append(statements,
+			`DELETE FROM wallpaper_tags WHERE wallpaper_id = ?2`,
+			`DELETE FROM wallhaven_tags WHERE wallpaper_id = ?2`,
+			`DELETE FROM usage_history WHERE wallpaper_id = ?2`,
+			`DELETE FROM wallpapers WHERE id = ?2`,
+		)
+		tx, err := c.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := merge(tx); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		c.removeDerived(removeID)
+	} else if err := c.trashWallpaperWith(removeID, constants.TrashReasonDuplicate, merge); err != nil {
+		// The merge is rolled back with the trashing, so a retry doesn't count twice
+		return err
+	}
+
+	slog.Info("Merged duplicate wallpaper", "kept", keepPath, "removed", removePath)
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package wallhaven provides image processing helpers for wallpapers
+package wallhaven
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// loadImage opens and decodes the image at filePath
+func loadImage(filePath string) (image.Image, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// toRGBA converts img to an *image.RGBA with its origin at (0, 0)
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resizeImage scales img to width x height by averaging the source pixels
+// that fall under each destination pixel
+func resizeImage(img image.Image, width, height int) *image.RGBA {
+	src := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if sw == 0 || sh == 0 || width <= 0 || height <= 0 {
+		return dst
+	}
+
+	for y := range height {
+		y0 := y * sh / height
+		y1 := max(y0+1, ((y+1)*sh+height-1)/height)
+		for x := range width {
+			x0 := x * sw / width
+			x1 := max(x0+1, ((x+1)*sw+width-1)/width)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// fillImage scales img to cover width x height and crops the overflow around the centre
+func fillImage(img image.Image, width, height int) *image.RGBA {
+	return resizeImage(subImage(img, centerCrop(img.Bounds(), width, height)), width, height)
+}
+
+// centerCrop returns the largest rectangle with the aspect ratio of width x height
+// centred inside bounds
+func centerCrop(bounds image.Rectangle, width, height int) image.Rectangle {
+	sw, sh := bounds.Dx(), bounds.Dy()
+	if sw == 0 || sh == 0 || width <= 0 || height <= 0 {
+		return bounds
+	}
+
+	cw, ch := sw, sw*height/width
+	if ch > sh {
+		cw, ch = sh*width/height, sh
+	}
+	x0 := bounds.Min.X + (sw-cw)/2
+	y0 := bounds.Min.Y + (sh-ch)/2
+	return image.Rect(x0, y0, x0+cw, y0+ch)
+}
+
+// focusCrop returns the rectangle with the aspect ratio of width x height that
+// covers the most detail in img. Detail is measured as edge energy on a downscaled
+// copy, and the window only slides along the axis that has to be cropped.
+func focusCrop(img image.Image, width, height int) image.Rectangle {
+	bounds := img.Bounds()
+	crop := centerCrop(bounds, width, height)
+	if crop == bounds {
+		return crop
+	}
+
+	// Work on a copy at most focusSampleSize pixels on its longest side
+	const focusSampleSize = 256
+	scale := float64(focusSampleSize) / float64(max(bounds.Dx(), bounds.Dy()))
+	scale = min(scale, 1)
+	sw := max(1, int(float64(bounds.Dx())*scale))
+	sh := max(1, int(float64(bounds.Dy())*scale))
+	small := resizeImage(img, sw, sh)
+
+	// Edge energy summed per column and per row
+	cols := make([]int, sw)
+	rows := make([]int, sh)
+	for y := 1; y < sh; y++ {
+		for x := 1; x < sw; x++ {
+			p := small.Pix[y*small.Stride+x*4:]
+			left := small.Pix[y*small.Stride+(x-1)*4:]
+			up := small.Pix[(y-1)*small.Stride+x*4:]
+			energy := abs(grey(p)-grey(left)) + abs(grey(p)-grey(up))
+			cols[x] += energy
+			rows[y] += energy
+		}
+	}
+
+	if crop.Dx() < bounds.Dx() {
+		offset := bestWindow(cols, int(float64(crop.Dx())*scale))
+		x0 := bounds.Min.X + min(int(float64(offset)/scale), bounds.Dx()-crop.Dx())
+		return image.Rect(x0, crop.Min.Y, x0+crop.Dx(), crop.Max.Y)
+	}
+	offset := bestWindow(rows, int(float64(crop.Dy())*scale))
+	y0 := bounds.Min.Y + min(int(float64(offset)/scale), bounds.Dy()-crop.Dy())
+	return image.Rect(crop.Min.X, y0, crop.Max.X, y0+crop.Dy())
+}
+
+// bestWindow returns the start of the window of the given size with the largest sum
+func bestWindow(values []int, size int) int {
+	if size <= 0 || size >= len(values) {
+		return 0
+	}
+
+	sum := 0
+	for _, v := range values[:size] {
+		sum += v
+	}
+	best, bestSum := 0, sum
+	for i := size; i < len(values); i++ {
+		sum += values[i] - values[i-size]
+		if sum > bestSum {
+			best, bestSum = i-size+1, sum
+		}
+	}
+	return best
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// subImage returns the portion of img inside r, copying only when img can't be sliced
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Rect, img, r.Min, draw.Src)
+	return dst
+}
+
+// saveImage encodes img as JPEG or PNG based on the extension of filePath and
+// atomically replaces filePath with the result
+func saveImage(img image.Image, filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), constants.DirPermissions); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp := filePath + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, constants.FilePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(out, img, &jpeg.Options{Quality: 90})
+	default:
+		err = png.Encode(out, img)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return os.Rename(tmp, filePath)
+}
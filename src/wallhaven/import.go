@@ -0,0 +1,137 @@
+// Package wallhaven provides importing and tracking of existing image files
+package wallhaven
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// fullImageURL is where wallhaven serves full-size images
+const fullImageURL = "https://w.wallhaven.cc/full"
+
+// IsImageFile reports whether filePath has one of constants.ImageExtensions
+func IsImageFile(filePath string) bool {
+	return slices.Contains(constants.ImageExtensions, strings.ToLower(filepath.Ext(filePath)))
+}
+
+// SourceURL returns the URL a local file was originally downloaded from. Files
+// named "wallhaven-<id>.<ext>" map to their wallhaven URL, so they share an ID
+// with the same wallpaper found by a search; anything else gets a file:// URL.
+func SourceURL(filePath string) string {
+	if id := WallhavenID(filePath); len(id) > 2 {
+		return fmt.Sprintf("%s/%s/%s", fullImageURL, id[:2], filepath.Base(filePath))
+	}
+
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		abs = filePath
+	}
+	return "file://" + abs
+}
+
+// ImportWallpaper registers an existing image file in the cache without marking it
+// as used, recording addedAt as its download time. Unlike AddWallpaper it does not
+// enforce cache limits, so a large import never evicts wallpapers. It returns the
+// new wallpaper's ID.
+func (c *WallpaperCache) ImportWallpaper(filePath, sourceURL string, addedAt time.Time) (string, error) {
+	hash, size, err := CalculateFileHash(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate hash: %w", err)
+	}
+
+	resolution, err := GetImageResolution(filePath)
+	if err != nil {
+		slog.Warn("Failed to get image resolution", "path", filePath, "error", err)
+		resolution = ""
+	}
+
+	var phash sql.NullInt64
+	if ph, err := CalculatePerceptualHash(filePath); err != nil {
+		slog.Debug("Failed to calculate perceptual hash", "path", filePath, "error", err)
+	} else {
+		phash = sql.NullInt64{Int64: int64(ph), Valid: true}
+	}
+
+	id := GenerateID(sourceURL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err = c.db.Exec(`
+		INSERT INTO wallpapers (id, path, original_url, hash, size, downloaded_at, last_used, use_count, categories, purities, resolution, phash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 0, '', '', ?, ?)
+	`, id, filePath, sourceURL, hash, size, addedAt, addedAt, resolution, phash)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert wallpaper: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetByPath returns the cache entry for filePath, whether or not the file still exists
+func (c *WallpaperCache) GetByPath(filePath string) *WallpaperMetadata {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.getEntry("path", filePath)
+}
+
+// GetByHash returns the cache entry with the given content hash. Unlike FindDuplicate
+// it also returns entries whose file has gone missing, which lets moved files be
+// matched back to their entry.
+func (c *WallpaperCache) GetByHash(hash string) *WallpaperMetadata {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.getEntry("hash", hash)
+}
+
+// getEntry returns the first wallpaper whose column equals value without checking
+// that its file exists; the caller must hold the lock
+func (c *WallpaperCache) getEntry(column string, value any) *WallpaperMetadata {
+	var metadata WallpaperMetadata
+	err := c.db.QueryRow(`
+		SELECT id, path, original_url, hash, size, downloaded_at, last_used, use_count,
+		       categories, purities, COALESCE(resolution, ''), is_favorite, rating
+		FROM wallpapers
+		WHERE `+column+` = ?
+		LIMIT 1
+	`, value).Scan(&metadata.ID, &metadata.Path, &metadata.OriginalURL, &metadata.Hash,
+		&metadata.Size, &metadata.DownloadedAt, &metadata.LastUsed, &metadata.UseCount,
+		&metadata.Categories, &metadata.Purities, &metadata.Resolution, &metadata.IsFavorite, &metadata.Rating)
+	if err != nil {
+		return nil
+	}
+
+	metadata.Tags = c.getTags(metadata.ID)
+	return &metadata
+}
+
+// UpdatePath records that a cached wallpaper's file has moved to filePath. Local
+// source URLs follow the file; the wallpaper keeps its ID.
+func (c *WallpaperCache) UpdatePath(id, filePath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result, err := c.db.Exec(`
+		UPDATE wallpapers SET
+			path = ?1,
+			original_url = CASE WHEN original_url LIKE 'file://%' THEN ?2 ELSE original_url END
+		WHERE id = ?3
+	`, filePath, SourceURL(filePath), id)
+	if err != nil {
+		return fmt.Errorf("failed to update wallpaper path: %w", err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("wallpaper not found in cache: %s", id)
+	}
+	return nil
+}
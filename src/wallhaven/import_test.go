@@ -0,0 +1,128 @@
+package wallhaven
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSourceURL(t *testing.T) {
+	tests := map[string]string{
+		"/pics/wallhaven-abc123.jpg": "https://w.wallhaven.cc/full/ab/wallhaven-abc123.jpg",
+		"/pics/holiday.png":          "file:///pics/holiday.png",
+	}
+
+	for path, want := range tests {
+		if got := SourceURL(path); got != want {
+			t.Errorf("SourceURL(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestWallpaperCache_ImportWallpaper(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, ".cache")
+
+	testFile := filepath.Join(tmpDir, "wallhaven-abc123.png")
+	writeTestImage(t, testFile, 200, 100, testBands...)
+
+	cache, err := NewWallpaperCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	addedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	id, err := cache.ImportWallpaper(testFile, SourceURL(testFile), addedAt)
+	if err != nil {
+		t.Fatalf("ImportWallpaper() error = %v", err)
+	}
+
+	// Imports share their ID with the same wallpaper found by a search
+	searched := &Wallpaper{Path: "https://w.wallhaven.cc/full/ab/wallhaven-abc123.png"}
+	if id != GenerateID(searched.Path) {
+		t.Errorf("Expected ID %s, got %s", GenerateID(searched.Path), id)
+	}
+
+	metadata := cache.GetByID(id)
+	if metadata == nil {
+		t.Fatal("Expected imported wallpaper in cache")
+	}
+	if metadata.Resolution != "200x100" {
+		t.Errorf("Expected resolution 200x100, got %s", metadata.Resolution)
+	}
+	if !metadata.DownloadedAt.Equal(addedAt) {
+		t.Errorf("Expected download time %v, got %v", addedAt, metadata.DownloadedAt)
+	}
+	if metadata.UseCount != 0 {
+		t.Errorf("Expected imported wallpaper to be unused, got use count %d", metadata.UseCount)
+	}
+
+	// Imports don't show up in the usage history
+	if history := cache.GetHistory(10); len(history) != 0 {
+		t.Errorf("Expected empty history, got %d entries", len(history))
+	}
+
+	hash, _, err := CalculateFileHash(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.FindDuplicate(hash) == nil {
+		t.Error("Expected imported file to be found by hash")
+	}
+}
+
+func TestWallpaperCache_UpdatePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, ".cache")
+
+	testFile := filepath.Join(tmpDir, "holiday.png")
+	writeTestImage(t, testFile, 200, 100, testBands...)
+
+	cache, err := NewWallpaperCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	id, err := cache.ImportWallpaper(testFile, SourceURL(testFile), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := CalculateFileHash(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries stay reachable by path and hash after their file moves
+	moved := filepath.Join(tmpDir, "renamed.png")
+	if err := os.Rename(testFile, moved); err != nil {
+		t.Fatal(err)
+	}
+	if entry := cache.GetByPath(testFile); entry == nil || entry.ID != id {
+		t.Fatalf("Expected entry %s at old path, got %v", id, entry)
+	}
+	if entry := cache.GetByHash(hash); entry == nil || entry.ID != id {
+		t.Fatalf("Expected entry %s by hash, got %v", id, entry)
+	}
+
+	if err := cache.UpdatePath(id, moved); err != nil {
+		t.Fatalf("UpdatePath() error = %v", err)
+	}
+
+	metadata := cache.GetByID(id)
+	if metadata == nil || metadata.Path != moved {
+		t.Fatalf("Expected wallpaper at %s, got %v", moved, metadata)
+	}
+	if metadata.OriginalURL != SourceURL(moved) {
+		t.Errorf("Expected local source URL to follow the file, got %s", metadata.OriginalURL)
+	}
+	if cache.GetByPath(testFile) != nil {
+		t.Error("Expected no entry at the old path")
+	}
+
+	if err := cache.UpdatePath("missing", moved); err == nil {
+		t.Error("Expected error updating unknown wallpaper")
+	}
+}
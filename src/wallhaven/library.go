@@ -0,0 +1,396 @@
+// Package wallhaven provides export and import of the wallpaper library
+package wallhaven
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// LibraryVersion is the version of the library document written by ExportLibrary
+const LibraryVersion = 1
+
+// Library is a portable document of the curated wallpaper library
+type Library struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Wallpapers []LibraryEntry `json:"wallpapers"`
+}
+
+// LibraryEntry is a wallpaper's metadata together with its usage history
+type LibraryEntry struct {
+	WallpaperMetadata
+	UsageHistory []time.Time `json:"usage_history"`
+}
+
+// LibraryMergeResult counts what happened to the entries of a merged library
+type LibraryMergeResult struct {
+	Added   int // Entries for files not in the cache yet
+	Merged  int // Entries combined with an existing wallpaper
+	Missing int // Entries skipped because their file couldn't be found
+}
+
+// libraryCSVHeader lists the columns of a CSV library; tags and usage history are
+// JSON arrays, so no character a tag may hold can split them
+var libraryCSVHeader = []string{
+	"id", "path", "original_url", "hash", "size", "downloaded_at", "last_used", "use_count",
+	"categories", "purities", "resolution", "is_favorite", "rating", "tags", "usage_history",
+}
+
+// ExportLibrary returns every cached wallpaper whose file exists, with its tags
+// and usage history
+func (c *WallpaperCache) ExportLibrary() (*Library, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rows, err := c.db.Query(`
+		SELECT id, path, original_url, hash, size, downloaded_at, last_used, use_count,
+		       categories, purities, COALESCE(resolution, ''), is_favorite, rating
+		FROM wallpapers
+		ORDER BY downloaded_at ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wallpapers: %w", err)
+	}
+	wallpapers := c.scanWallpapers(rows)
+	rows.Close()
+
+	history, err := c.loadUsageHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	library := &Library{Version: LibraryVersion, ExportedAt: time.Now()}
+	for _, wallpaper := range wallpapers {
+		library.Wallpapers = append(library.Wallpapers, LibraryEntry{
+			WallpaperMetadata: *wallpaper,
+			UsageHistory:      history[wallpaper.ID],
+		})
+	}
+	return library, nil
+}
+
+// loadUsageHistory returns every usage timestamp by wallpaper ID, oldest first;
+// the caller must hold the lock
+func (c *WallpaperCache) loadUsageHistory() (map[string][]time.Time, error) {
+	rows, err := c.db.Query(`SELECT wallpaper_id, used_at FROM usage_history ORDER BY used_at ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage history: %w", err)
+	}
+	defer rows.Close()
+
+	history := make(map[string][]time.Time)
+	for rows.Next() {
+		var id string
+		var usedAt time.Time
+		if rows.Scan(&id, &usedAt) == nil {
+			history[id] = append(history[id], usedAt)
+		}
+	}
+	return history, rows.Err()
+}
+
+// MergeLibrary merges a library document into the cache. Entries are matched to
+// cached wallpapers by ID and then by content hash. On a match tags and usage
+// history are combined, and policy (one of constants.ValidMergePolicies) decides
+// whose rating and favourite status win. Unmatched entries are added when their
+// file exists at the recorded path or under the same name in one of searchDirs.
+func (c *WallpaperCache) MergeLibrary(library *Library, policy string, searchDirs []string) (*LibraryMergeResult, error) {
+	if library.Version > LibraryVersion {
+		return nil, fmt.Errorf("unsupported library version %d (newest supported is %d)", library.Version, LibraryVersion)
+	}
+	if !slices.Contains(constants.ValidMergePolicies, policy) {
+		return nil, fmt.Errorf("invalid merge policy: %s", policy)
+	}
+
+	result := &LibraryMergeResult{}
+	for _, entry := range library.Wallpapers {
+		c.mu.RLock()
+		existing := c.getEntry("id", entry.ID)
+		if existing == nil && entry.Hash != "" {
+			existing = c.getEntry("hash", entry.Hash)
+		}
+		c.mu.RUnlock()
+
+		if existing == nil {
+			path := locateLibraryFile(entry.Path, searchDirs)
+			if path == "" {
+				result.Missing++
+				continue
+			}
+
+			id, err := c.ImportWallpaper(path, entry.OriginalURL, entry.DownloadedAt)
+			if err != nil {
+				return result, fmt.Errorf("failed to add %s: %w", path, err)
+			}
+			// Take everything from the document for a wallpaper we didn't have
+			existing = &WallpaperMetadata{ID: id}
+			if err := c.mergeLibraryEntry(existing, entry, constants.MergePolicyKeepNewer); err != nil {
+				return result, err
+			}
+			result.Added++
+			continue
+		}
+
+		if err := c.mergeLibraryEntry(existing, entry, policy); err != nil {
+			return result, err
+		}
+		result.Merged++
+	}
+	return result, nil
+}
+
+// mergeLibraryEntry folds a library entry into the cached wallpaper existing
+func (c *WallpaperCache) mergeLibraryEntry(existing *WallpaperMetadata, entry LibraryEntry, policy string) error {
+	rating, favorite := existing.Rating, existing.IsFavorite
+	switch policy {
+	case constants.MergePolicyKeepNewer:
+		if entry.LastUsed.After(existing.LastUsed) {
+			rating, favorite = entry.Rating, entry.IsFavorite
+		}
+	case constants.MergePolicyKeepHigherRating:
+		rating = max(rating, entry.Rating)
+		favorite = favorite || entry.IsFavorite
+	}
+
+	lastUsed := existing.LastUsed
+	if entry.LastUsed.After(lastUsed) {
+		lastUsed = entry.LastUsed
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE wallpapers SET
+			rating = ?, is_favorite = ?, last_used = ?, use_count = MAX(use_count, ?),
+			categories = CASE WHEN categories = '' THEN ? ELSE categories END,
+			purities = CASE WHEN purities = '' THEN ? ELSE purities END
+		WHERE id = ?
+	`, rating, favorite, lastUsed, entry.UseCount, entry.Categories, entry.Purities, existing.ID)
+	if err != nil {
+		return fmt.Errorf("failed to merge wallpaper: %w", err)
+	}
+
+	for _, tag := range entry.Tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO wallpaper_tags (wallpaper_id, tag) VALUES (?, ?)`, existing.ID, tag); err != nil {
+			return fmt.Errorf("failed to merge tags: %w", err)
+		}
+	}
+
+	// Usage timestamps already recorded are skipped so repeated merges are idempotent
+	known := make(map[int64]bool)
+	rows, err := tx.Query(`SELECT used_at FROM usage_history WHERE wallpaper_id = ?`, existing.ID)
+	if err != nil {
+		return fmt.Errorf("failed to query usage history: %w", err)
+	}
+	for rows.Next() {
+		var usedAt time.Time
+		if rows.Scan(&usedAt) == nil {
+			known[usedAt.UnixNano()] = true
+		}
+	}
+	rows.Close()
+
+	for _, usedAt := range entry.UsageHistory {
+		if known[usedAt.UnixNano()] {
+			continue
+		}
+		known[usedAt.UnixNano()] = true
+		if _, err := tx.Exec(`INSERT INTO usage_history (wallpaper_id, used_at) VALUES (?, ?)`, existing.ID, usedAt); err != nil {
+			return fmt.Errorf("failed to merge usage history: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// locateLibraryFile returns where a library entry's file can be found locally
+func locateLibraryFile(path string, searchDirs []string) string {
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	for _, dir := range searchDirs {
+		candidate := filepath.Join(dir, filepath.Base(path))
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// WriteLibrary encodes a library as JSON or CSV, per constants.ValidLibraryFormats
+func WriteLibrary(w io.Writer, library *Library, format string) error {
+	switch format {
+	case constants.LibraryFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(library)
+	case constants.LibraryFormatCSV:
+		return writeLibraryCSV(w, library)
+	default:
+		return fmt.Errorf("invalid library format: %s", format)
+	}
+}
+
+// ReadLibrary decodes a library written by WriteLibrary
+func ReadLibrary(r io.Reader, format string) (*Library, error) {
+	switch format {
+	case constants.LibraryFormatJSON:
+		var library Library
+		if err := json.NewDecoder(r).Decode(&library); err != nil {
+			return nil, fmt.Errorf("failed to decode library: %w", err)
+		}
+		return &library, nil
+	case constants.LibraryFormatCSV:
+		return readLibraryCSV(r)
+	default:
+		return nil, fmt.Errorf("invalid library format: %s", format)
+	}
+}
+
+func writeLibraryCSV(w io.Writer, library *Library) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(libraryCSVHeader); err != nil {
+		return err
+	}
+
+	for _, entry := range library.Wallpapers {
+		tags, err := encodeCSVList(entry.Tags)
+		if err != nil {
+			return err
+		}
+		history, err := encodeCSVList(entry.UsageHistory)
+		if err != nil {
+			return err
+		}
+		record := []string{
+			entry.ID, entry.Path, entry.OriginalURL, entry.Hash,
+			strconv.FormatInt(entry.Size, 10),
+			entry.DownloadedAt.Format(time.RFC3339Nano),
+			entry.LastUsed.Format(time.RFC3339Nano),
+			strconv.Itoa(entry.UseCount),
+			entry.Categories, entry.Purities, entry.Resolution,
+			strconv.FormatBool(entry.IsFavorite),
+			strconv.Itoa(entry.Rating),
+			tags,
+			history,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func readLibraryCSV(r io.Reader) (*Library, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(libraryCSVHeader)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read library header: %w", err)
+	}
+	if !slices.Equal(header, libraryCSVHeader) {
+		return nil, fmt.Errorf("unrecognised library columns: %s", strings.Join(header, ","))
+	}
+
+	library := &Library{Version: LibraryVersion}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read library: %w", err)
+		}
+
+		entry, err := parseLibraryRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid library entry on line %d: %w", line, err)
+		}
+		library.Wallpapers = append(library.Wallpapers, entry)
+	}
+	return library, nil
+}
+
+// parseLibraryRecord converts a CSV record in libraryCSVHeader order into an entry
+func parseLibraryRecord(record []string) (LibraryEntry, error) {
+	entry := LibraryEntry{WallpaperMetadata: WallpaperMetadata{
+		ID:          record[0],
+		Path:        record[1],
+		OriginalURL: record[2],
+		Hash:        record[3],
+		Categories:  record[8],
+		Purities:    record[9],
+		Resolution:  record[10],
+	}}
+
+	var err error
+	if entry.Size, err = strconv.ParseInt(record[4], 10, 64); err != nil {
+		return entry, fmt.Errorf("size: %w", err)
+	}
+	if entry.DownloadedAt, err = time.Parse(time.RFC3339Nano, record[5]); err != nil {
+		return entry, fmt.Errorf("downloaded_at: %w", err)
+	}
+	if entry.LastUsed, err = time.Parse(time.RFC3339Nano, record[6]); err != nil {
+		return entry, fmt.Errorf("last_used: %w", err)
+	}
+	if entry.UseCount, err = strconv.Atoi(record[7]); err != nil {
+		return entry, fmt.Errorf("use_count: %w", err)
+	}
+	if entry.IsFavorite, err = strconv.ParseBool(record[11]); err != nil {
+		return entry, fmt.Errorf("is_favorite: %w", err)
+	}
+	if entry.Rating, err = strconv.Atoi(record[12]); err != nil {
+		return entry, fmt.Errorf("rating: %w", err)
+	}
+	if err := decodeCSVList(record[13], &entry.Tags); err != nil {
+		return entry, fmt.Errorf("tags: %w", err)
+	}
+	if err := decodeCSVList(record[14], &entry.UsageHistory); err != nil {
+		return entry, fmt.Errorf("usage_history: %w", err)
+	}
+	return entry, nil
+}
+
+// encodeCSVList encodes a list as a JSON array for a single CSV field, leaving
+// the field empty when the list is
+func encodeCSVList[T any](list []T) (string, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeCSVList decodes a field written by encodeCSVList
+func decodeCSVList[T any](field string, list *[]T) error {
+	if field == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(field), list)
+}
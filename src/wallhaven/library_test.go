@@ -0,0 +1,177 @@
+package wallhaven
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// newLibraryTestCache creates a cache holding one rated, tagged wallpaper
+func newLibraryTestCache(t *testing.T, dir string) (*WallpaperCache, string) {
+	t.Helper()
+
+	testFile := filepath.Join(dir, "wallhaven-abc123.png")
+	writeTestImage(t, testFile, 200, 100, testBands...)
+
+	cache, err := NewWallpaperCache(filepath.Join(dir, ".cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	wallpaper := &Wallpaper{Path: "https://w.wallhaven.cc/full/ab/wallhaven-abc123.png"}
+	if err := cache.AddWallpaper(wallpaper, testFile, "010", "110"); err != nil {
+		t.Fatal(err)
+	}
+	return cache, GenerateID(wallpaper.Path)
+}
+
+func TestLibraryRoundTrip(t *testing.T) {
+	cache, id := newLibraryTestCache(t, t.TempDir())
+	if err := cache.SetRating(id, 4); err != nil {
+		t.Fatal(err)
+	}
+	// Separators and quotes inside tags survive the CSV field they share
+	if err := cache.AddTags(id, []string{"nature", "black;white", `say "hi", all`}); err != nil {
+		t.Fatal(err)
+	}
+
+	library, err := cache.ExportLibrary()
+	if err != nil {
+		t.Fatalf("ExportLibrary() error = %v", err)
+	}
+	if len(library.Wallpapers) != 1 || len(library.Wallpapers[0].UsageHistory) != 1 {
+		t.Fatalf("Expected one wallpaper with one use, got %+v", library.Wallpapers)
+	}
+
+	for _, format := range constants.ValidLibraryFormats {
+		var buf bytes.Buffer
+		if err := WriteLibrary(&buf, library, format); err != nil {
+			t.Fatalf("WriteLibrary(%s) error = %v", format, err)
+		}
+		decoded, err := ReadLibrary(&buf, format)
+		if err != nil {
+			t.Fatalf("ReadLibrary(%s) error = %v", format, err)
+		}
+
+		got, want := decoded.Wallpapers[0], library.Wallpapers[0]
+		if got.ID != want.ID || got.Rating != 4 || !slices.Equal(got.Tags, want.Tags) {
+			t.Errorf("%s: expected %+v, got %+v", format, want, got)
+		}
+		if !got.UsageHistory[0].Equal(want.UsageHistory[0]) {
+			t.Errorf("%s: expected usage %v, got %v", format, want.UsageHistory, got.UsageHistory)
+		}
+	}
+}
+
+func TestWallpaperCache_MergeLibrary(t *testing.T) {
+	cache, id := newLibraryTestCache(t, t.TempDir())
+	if err := cache.SetRating(id, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AddTags(id, []string{"nature"}); err != nil {
+		t.Fatal(err)
+	}
+
+	local := cache.GetByID(id)
+	older := local.LastUsed.Add(-time.Hour)
+	library := &Library{Version: LibraryVersion, Wallpapers: []LibraryEntry{
+		{
+			WallpaperMetadata: WallpaperMetadata{
+				ID: id, Hash: local.Hash, Rating: 5, IsFavorite: true,
+				LastUsed: older, UseCount: 3, Tags: []string{"blue"},
+			},
+			UsageHistory: []time.Time{older},
+		},
+		{
+			WallpaperMetadata: WallpaperMetadata{ID: "elsewhere", Path: "/nowhere/wallhaven-zzz999.png"},
+		},
+	}}
+
+	// The local copy was used more recently, so keep-newer keeps its rating
+	result, err := cache.MergeLibrary(library, constants.MergePolicyKeepNewer, nil)
+	if err != nil {
+		t.Fatalf("MergeLibrary() error = %v", err)
+	}
+	if result.Merged != 1 || result.Missing != 1 {
+		t.Errorf("Expected 1 merged and 1 missing, got %+v", result)
+	}
+
+	merged := cache.GetByID(id)
+	if merged.Rating != 2 || merged.IsFavorite {
+		t.Errorf("Expected keep-newer to keep the local rating, got %d (favorite %v)", merged.Rating, merged.IsFavorite)
+	}
+	if !slices.Contains(merged.Tags, "nature") || !slices.Contains(merged.Tags, "blue") {
+		t.Errorf("Expected tags to be combined, got %v", merged.Tags)
+	}
+	if merged.UseCount != 3 {
+		t.Errorf("Expected use count 3, got %d", merged.UseCount)
+	}
+
+	// keep-higher-rating takes the better rating and favourite status
+	if _, err := cache.MergeLibrary(library, constants.MergePolicyKeepHigherRating, nil); err != nil {
+		t.Fatal(err)
+	}
+	merged = cache.GetByID(id)
+	if merged.Rating != 5 || !merged.IsFavorite {
+		t.Errorf("Expected rating 5 and favourite, got %d (favorite %v)", merged.Rating, merged.IsFavorite)
+	}
+
+	// Merging the same history twice doesn't duplicate it
+	history, err := cache.GetUsageHistory(id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("Expected 2 usage entries, got %d", len(history))
+	}
+
+	library.Version = LibraryVersion + 1
+	if _, err := cache.MergeLibrary(library, constants.MergePolicyKeepNewer, nil); err == nil {
+		t.Error("Expected error merging a newer library version")
+	}
+}
+
+func TestWallpaperCache_MergeLibraryAddsFiles(t *testing.T) {
+	source, id := newLibraryTestCache(t, t.TempDir())
+	if err := source.ToggleFavorite(id); err != nil {
+		t.Fatal(err)
+	}
+	library, err := source.ExportLibrary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file lives under a different directory on the new machine
+	targetDir := t.TempDir()
+	writeTestImage(t, filepath.Join(targetDir, "wallhaven-abc123.png"), 200, 100, testBands...)
+	target, err := NewWallpaperCache(filepath.Join(targetDir, ".cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	library.Wallpapers[0].Path = "/old/machine/wallhaven-abc123.png"
+	result, err := target.MergeLibrary(library, constants.MergePolicyKeepNewer, []string{targetDir})
+	if err != nil {
+		t.Fatalf("MergeLibrary() error = %v", err)
+	}
+	if result.Added != 1 {
+		t.Fatalf("Expected 1 added, got %+v", result)
+	}
+
+	added := target.GetByID(id)
+	if added == nil || !added.IsFavorite {
+		t.Fatalf("Expected favourite wallpaper %s, got %+v", id, added)
+	}
+	if added.Path != filepath.Join(targetDir, "wallhaven-abc123.png") {
+		t.Errorf("Expected local path, got %s", added.Path)
+	}
+	if history := target.GetHistory(10); len(history) != 1 {
+		t.Errorf("Expected the usage history to be carried over, got %d entries", len(history))
+	}
+}
@@ -0,0 +1,123 @@
+// Package wallhaven provides lockscreen image generation
+package wallhaven
+
+import (
+	"fmt"
+	"image"
+)
+
+// LockscreenEffects controls how a lockscreen image is derived from a wallpaper
+type LockscreenEffects struct {
+	Blur     int // Blur radius in pixels, 0 to disable
+	Dim      int // Percentage to darken by, 0-100
+	Pixelate int // Block size in pixels, 0 or 1 to disable
+}
+
+// blurPasses is the number of box blur passes, which together approximate a Gaussian blur
+const blurPasses = 3
+
+// CreateLockscreen writes a copy of the image at src with the given effects applied to dst
+func CreateLockscreen(src, dst string, effects LockscreenEffects) error {
+	img, err := loadImage(src)
+	if err != nil {
+		return err
+	}
+
+	lockscreen := ApplyLockscreenEffects(img, effects)
+	if err := saveImage(lockscreen, dst); err != nil {
+		return fmt.Errorf("failed to save lockscreen: %w", err)
+	}
+	return nil
+}
+
+// ApplyLockscreenEffects returns a copy of img pixelated, blurred and dimmed in that order
+func ApplyLockscreenEffects(img image.Image, effects LockscreenEffects) *image.RGBA {
+	bounds := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	copy(out.Pix, toRGBA(img).Pix)
+
+	if effects.Pixelate > 1 {
+		out = pixelate(out, effects.Pixelate)
+	}
+	if effects.Blur > 0 {
+		for range blurPasses {
+			boxBlur(out, effects.Blur)
+		}
+	}
+	if effects.Dim > 0 {
+		dim(out, effects.Dim)
+	}
+	return out
+}
+
+// pixelate averages img over block x block squares
+func pixelate(img *image.RGBA, block int) *image.RGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	small := resizeImage(img, max(1, (w+block-1)/block), max(1, (h+block-1)/block))
+
+	out := image.NewRGBA(img.Rect)
+	for y := range h {
+		row := small.Pix[(y/block)*small.Stride:]
+		for x := range w {
+			copy(out.Pix[y*out.Stride+x*4:y*out.Stride+x*4+4], row[(x/block)*4:])
+		}
+	}
+	return out
+}
+
+// boxBlur blurs img in place with a (2*radius+1) wide box, horizontally then vertically.
+// Samples beyond the edges are clamped to the nearest pixel.
+func boxBlur(img *image.RGBA, radius int) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if w == 0 || h == 0 {
+		return
+	}
+
+	line := make([]uint8, max(w, h)*4)
+	blurLine := func(pix []uint8, offset, stride, n int) {
+		for i := range n {
+			copy(line[i*4:i*4+4], pix[offset+i*stride:])
+		}
+		at := func(i int) []uint8 {
+			i = min(max(i, 0), n-1)
+			return line[i*4 : i*4+4]
+		}
+
+		var sum [4]int
+		for i := -radius; i <= radius; i++ {
+			p := at(i)
+			for c := range sum {
+				sum[c] += int(p[c])
+			}
+		}
+		size := 2*radius + 1
+		for i := range n {
+			d := pix[offset+i*stride:]
+			for c := range sum {
+				d[c] = uint8(sum[c] / size)
+			}
+			in, out := at(i+radius+1), at(i-radius)
+			for c := range sum {
+				sum[c] += int(in[c]) - int(out[c])
+			}
+		}
+	}
+
+	for y := range h {
+		blurLine(img.Pix, y*img.Stride, 4, w)
+	}
+	for x := range w {
+		blurLine(img.Pix, x*4, img.Stride, h)
+	}
+}
+
+// dim darkens img in place by percent
+func dim(img *image.RGBA, percent int) {
+	keep := 100 - min(percent, 100)
+	for i := 0; i < len(img.Pix); i += 4 {
+		p := img.Pix[i : i+3]
+		for c := range p {
+			p[c] = uint8(int(p[c]) * keep / 100)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package wallhaven
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyLockscreenEffects(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	black := color.RGBA{A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := range 40 {
+		c := black
+		if x >= 20 {
+			c = white
+		}
+		for y := range 20 {
+			img.Set(x, y, c)
+		}
+	}
+
+	// Blurring softens the hard edge in the middle but leaves the far sides alone
+	blurred := ApplyLockscreenEffects(img, LockscreenEffects{Blur: 3})
+	if edge := blurred.RGBAAt(20, 10).R; edge == 0 || edge == 255 {
+		t.Errorf("Expected blurred edge to be grey, got %d", edge)
+	}
+	if far := blurred.RGBAAt(39, 10).R; far != 255 {
+		t.Errorf("Expected far side to stay white, got %d", far)
+	}
+
+	// Dimming darkens every pixel by the percentage
+	dimmed := ApplyLockscreenEffects(img, LockscreenEffects{Dim: 50})
+	if got := dimmed.RGBAAt(30, 10).R; got != 127 {
+		t.Errorf("Expected dimmed white of 127, got %d", got)
+	}
+
+	// The source image is left untouched
+	if got := img.RGBAAt(30, 10).R; got != 255 {
+		t.Errorf("Expected source to be unchanged, got %d", got)
+	}
+}
+
+func TestPixelate(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+
+	out := ApplyLockscreenEffects(img, LockscreenEffects{Pixelate: 4})
+	if out.Rect != img.Rect {
+		t.Fatalf("Expected pixelated image to keep its size, got %v", out.Rect)
+	}
+
+	// Every pixel in a block shares the block's average colour
+	want := out.RGBAAt(0, 0)
+	if want.R == 0 || want.R == 255 {
+		t.Errorf("Expected averaged block colour, got %v", want)
+	}
+	if got := out.RGBAAt(3, 3); got != want {
+		t.Errorf("Expected %v across the block, got %v", want, got)
+	}
+	if got := out.RGBAAt(4, 4); got.R != 0 {
+		t.Errorf("Expected neighbouring block to stay black, got %v", got)
+	}
+}
+
+func TestCreateLockscreen(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "wallpaper.png")
+	writeTestImage(t, src, 120, 60, testBands...)
+
+	dst := filepath.Join(tmpDir, "cache", "lockscreen.png")
+	if err := CreateLockscreen(src, dst, LockscreenEffects{Blur: 4, Dim: 30}); err != nil {
+		t.Fatalf("CreateLockscreen() error = %v", err)
+	}
+	if size := imageSize(t, dst); size != image.Pt(120, 60) {
+		t.Errorf("Expected 120x60 lockscreen, got %v", size)
+	}
+}
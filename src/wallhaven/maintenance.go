@@ -0,0 +1,334 @@
+// Package wallhaven provides maintenance operations for the cache database
+package wallhaven
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// DatabaseStats describes the size and contents of the cache database
+type DatabaseStats struct {
+	Path          string
+	FileSize      int64
+	PageSize      int64
+	PageCount     int64
+	FreePages     int64
+	SchemaVersion int
+	Tables        []TableStats
+}
+
+// TableStats describes a single table; Bytes includes the table's indexes
+type TableStats struct {
+	Name  string
+	Rows  int64
+	Bytes int64
+}
+
+// manualBackupPattern matches backups written by Backup, which are the only ones
+// subject to retention; pre-migration and pre-restore backups are kept
+var manualBackupPattern = regexp.MustCompile(`^wallpapers-\d{8}-\d{6}\.\d{3}\.db$`)
+
+// backupName returns a timestamped backup file name with an optional suffix
+func backupName(suffix string) string {
+	return "wallpapers-" + time.Now().Format("20060102-150405.000") + suffix + ".db"
+}
+
+// dbPath returns the location of the database file
+func (c *WallpaperCache) dbPath() string {
+	return filepath.Join(c.dir, constants.DatabaseFile)
+}
+
+// BackupDir returns the default directory for database backups
+func (c *WallpaperCache) BackupDir() string {
+	return filepath.Join(c.dir, constants.BackupDir)
+}
+
+// Backup writes a consistent copy of the live database to a timestamped file in
+// dir, then deletes all but the newest keep backups there. A keep of zero or less
+// disables pruning. It returns the path of the new backup.
+func (c *WallpaperCache) Backup(dir string, keep int) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	path := filepath.Join(dir, backupName(""))
+	if err := c.backupTo(path); err != nil {
+		return "", err
+	}
+
+	if keep > 0 {
+		if err := pruneBackups(dir, keep); err != nil {
+			slog.Warn("Failed to prune old backups", "dir", dir, "error", err)
+		}
+	}
+	return path, nil
+}
+
+// pruneBackups removes all but the newest keep backups written by Backup in dir
+func pruneBackups(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && manualBackupPattern.MatchString(entry.Name()) {
+			backups = append(backups, entry.Name())
+		}
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+
+	// Timestamped names sort chronologically
+	slices.Sort(backups)
+	for _, name := range backups[:len(backups)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+		slog.Debug("Removed old backup", "name", name)
+	}
+	return nil
+}
+
+// ValidateBackup checks that path is an intact cache database this build can open
+func ValidateBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("not a valid database: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup failed integrity check: %s", result)
+	}
+
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'wallpapers'`).Scan(&tables); err != nil || tables == 0 {
+		return fmt.Errorf("backup does not contain a wallpapers table")
+	}
+
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read backup schema version: %w", err)
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("backup schema version %d is newer than this build supports (%d)", version, SchemaVersion)
+	}
+	return nil
+}
+
+// Restore replaces the live database with the backup at path after validating it.
+// The current database is backed up first, and the restored one is migrated to the
+// current schema. It returns the path of the safety backup.
+func (c *WallpaperCache) Restore(path string) (string, error) {
+	if err := ValidateBackup(path); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	safety := filepath.Join(c.BackupDir(), backupName("-pre-restore"))
+	if err := c.backupTo(safety); err != nil {
+		return "", fmt.Errorf("failed to back up current database: %w", err)
+	}
+
+	if err := c.db.Close(); err != nil {
+		return safety, fmt.Errorf("failed to close database: %w", err)
+	}
+
+	dbPath := c.dbPath()
+	copyErr := copyFile(path, dbPath)
+	if copyErr == nil {
+		for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+			os.Remove(dbPath + suffix)
+		}
+	}
+
+	// Reopen whatever is in place so the cache stays usable even if the copy failed
+	db, err := openDatabase(dbPath)
+	if err != nil {
+		return safety, fmt.Errorf("failed to reopen database: %w", err)
+	}
+	c.db = db
+
+	if copyErr != nil {
+		return safety, fmt.Errorf("failed to restore backup: %w", copyErr)
+	}
+	if err := c.migrate(); err != nil {
+		return safety, fmt.Errorf("failed to migrate restored database: %w", err)
+	}
+	return safety, nil
+}
+
+// copyFile copies src over dst via a temporary file so dst is never half-written
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, constants.FilePermissions)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// CheckIntegrity runs SQLite's integrity and foreign key checks and returns the
+// problems found. Foreign keys aren't enforced, so rows left behind by deleted
+// wallpapers show up here.
+func (c *WallpaperCache) CheckIntegrity() ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var problems []string
+
+	rows, err := c.db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	for rows.Next() {
+		var result string
+		if rows.Scan(&result) == nil && result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	rows.Close()
+
+	rows, err = c.db.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run foreign key check: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if rows.Scan(&table, &rowid, &parent, &fkid) == nil {
+			problems = append(problems, fmt.Sprintf("%s row %d references a missing %s row", table, rowid.Int64, parent))
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Vacuum rebuilds the database file, reclaiming space left by deleted rows
+func (c *WallpaperCache) Vacuum() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
+
+// Analyze refreshes the statistics SQLite's query planner uses
+func (c *WallpaperCache) Analyze() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.db.Exec(`ANALYZE`); err != nil {
+		return fmt.Errorf("failed to analyze database: %w", err)
+	}
+	return nil
+}
+
+// GetDatabaseStats returns the database's file size, page usage and per-table sizes
+func (c *WallpaperCache) GetDatabaseStats() (*DatabaseStats, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := &DatabaseStats{Path: c.dbPath()}
+	if info, err := os.Stat(stats.Path); err == nil {
+		stats.FileSize = info.Size()
+	}
+
+	for pragma, dest := range map[string]*int64{
+		"page_size":      &stats.PageSize,
+		"page_count":     &stats.PageCount,
+		"freelist_count": &stats.FreePages,
+	} {
+		if err := c.db.QueryRow(`PRAGMA ` + pragma).Scan(dest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pragma, err)
+		}
+	}
+
+	version, err := c.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	stats.SchemaVersion = version
+
+	rows, err := c.db.Query(`
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+
+	// Page usage per table including its indexes; dbstat may be unavailable
+	sizes := make(map[string]int64)
+	if rows, err := c.db.Query(`
+		SELECT m.tbl_name, SUM(s.pgsize)
+		FROM dbstat s JOIN sqlite_master m ON m.name = s.name
+		GROUP BY m.tbl_name
+	`); err == nil {
+		for rows.Next() {
+			var name string
+			var size int64
+			if rows.Scan(&name, &size) == nil {
+				sizes[name] = size
+			}
+		}
+		rows.Close()
+	}
+
+	for _, name := range names {
+		table := TableStats{Name: name, Bytes: sizes[name]}
+		if err := c.db.QueryRow(`SELECT COUNT(*) FROM "` + name + `"`).Scan(&table.Rows); err != nil {
+			return nil, fmt.Errorf("failed to count rows in %s: %w", name, err)
+		}
+		stats.Tables = append(stats.Tables, table)
+	}
+	return stats, nil
+}
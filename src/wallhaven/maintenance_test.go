@@ -0,0 +1,142 @@
+package wallhaven
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+func TestWallpaperCache_BackupRetention(t *testing.T) {
+	cache, _ := newLibraryTestCache(t, t.TempDir())
+	dir := cache.BackupDir()
+
+	// Backups from migrations and restores are never pruned
+	if err := os.MkdirAll(dir, constants.DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	migrationBackup := filepath.Join(dir, "wallpapers-20200101-000000.000-v0.db")
+	if err := os.WriteFile(migrationBackup, nil, constants.FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"wallpapers-20200101-000000.000.db", "wallpapers-20200102-000000.000.db"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, constants.FilePermissions); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := cache.Backup(dir, 2)
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	if err := ValidateBackup(path); err != nil {
+		t.Errorf("Expected a valid backup, got %v", err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "*.db"))
+	if len(backups) != 3 {
+		t.Fatalf("Expected 3 files after pruning, got %v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "wallpapers-20200101-000000.000.db")); !os.IsNotExist(err) {
+		t.Error("Expected the oldest backup to be pruned")
+	}
+	if _, err := os.Stat(migrationBackup); err != nil {
+		t.Error("Expected the migration backup to be kept")
+	}
+}
+
+func TestWallpaperCache_Restore(t *testing.T) {
+	cache, id := newLibraryTestCache(t, t.TempDir())
+	if err := cache.SetRating(id, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := cache.Backup(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.SetRating(id, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	safety, err := cache.Restore(backup)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if got := cache.GetByID(id); got == nil || got.Rating != 3 {
+		t.Errorf("Expected the restored rating 3, got %+v", got)
+	}
+	if err := ValidateBackup(safety); err != nil {
+		t.Errorf("Expected a valid safety backup, got %v", err)
+	}
+
+	// Anything that isn't a cache database is rejected before touching the live one
+	bogus := filepath.Join(t.TempDir(), "bogus.db")
+	if err := os.WriteFile(bogus, []byte("not a database"), constants.FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Restore(bogus); err == nil {
+		t.Error("Expected error restoring an invalid backup")
+	}
+	if got := cache.GetByID(id); got == nil {
+		t.Error("Expected the live database to be untouched")
+	}
+}
+
+func TestWallpaperCache_CheckIntegrity(t *testing.T) {
+	cache, id := newLibraryTestCache(t, t.TempDir())
+
+	problems, err := cache.CheckIntegrity()
+	if err != nil {
+		t.Fatalf("CheckIntegrity() error = %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected a healthy database, got %v", problems)
+	}
+
+	// Foreign keys aren't enforced, so deleting the parent row leaves orphans
+	if _, err := cache.db.Exec(`DELETE FROM wallpapers WHERE id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+	problems, err = cache.CheckIntegrity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) == 0 {
+		t.Error("Expected orphaned usage history to be reported")
+	}
+}
+
+func TestWallpaperCache_GetDatabaseStats(t *testing.T) {
+	cache, _ := newLibraryTestCache(t, t.TempDir())
+
+	if err := cache.Vacuum(); err != nil {
+		t.Fatalf("Vacuum() error = %v", err)
+	}
+	if err := cache.Analyze(); err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+
+	stats, err := cache.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("GetDatabaseStats() error = %v", err)
+	}
+	if stats.SchemaVersion != SchemaVersion || stats.FileSize == 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	found := false
+	for _, table := range stats.Tables {
+		if table.Name == "wallpapers" {
+			found = true
+			if table.Rows != 1 {
+				t.Errorf("Expected 1 wallpaper row, got %d", table.Rows)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected stats for the wallpapers table")
+	}
+}
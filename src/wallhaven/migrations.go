@@ -0,0 +1,346 @@
+// Package wallhaven provides schema migrations for the wallpaper cache
+package wallhaven
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// migration upgrades the schema from version-1 to version. The schema version is
+// stored in PRAGMA user_version.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in order. Append new migrations here and
+// never edit one that has been released. Databases created before versioning
+// report version 0 but may already contain some of these changes, so early
+// migrations are written to be idempotent.
+var migrations = []migration{
+	{1, "Create wallpapers, tags, usage history and view state tables", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS wallpapers (
+			id TEXT PRIMARY KEY,
+			path TEXT NOT NULL,
+			original_url TEXT NOT NULL,
+			hash TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			downloaded_at DATETIME NOT NULL,
+			last_used DATETIME NOT NULL,
+			use_count INTEGER NOT NULL DEFAULT 1,
+			categories TEXT NOT NULL,
+			purities TEXT NOT NULL,
+			resolution TEXT,
+			is_favorite BOOLEAN NOT NULL DEFAULT 0,
+			rating INTEGER NOT NULL DEFAULT 0
+		);
+
+		CREATE TABLE IF NOT EXISTS wallpaper_tags (
+			wallpaper_id TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (wallpaper_id, tag),
+			FOREIGN KEY (wallpaper_id) REFERENCES wallpapers(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS usage_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			wallpaper_id TEXT NOT NULL,
+			used_at DATETIME NOT NULL,
+			FOREIGN KEY (wallpaper_id) REFERENCES wallpapers(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS view_state (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			current_wallpaper_id TEXT,
+			updated_at DATETIME NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_wallpapers_hash ON wallpapers(hash);
+		CREATE INDEX IF NOT EXISTS idx_wallpapers_last_used ON wallpapers(last_used);
+		CREATE INDEX IF NOT EXISTS idx_wallpapers_favorite ON wallpapers(is_favorite);
+		CREATE INDEX IF NOT EXISTS idx_usage_history_wallpaper_id ON usage_history(wallpaper_id);
+		CREATE INDEX IF NOT EXISTS idx_usage_history_used_at ON usage_history(used_at);
+		`)
+		return err
+	}},
+	{2, "Add perceptual hashes for near-duplicate detection", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "wallpapers", "phash", "INTEGER"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_wallpapers_phash ON wallpapers(phash)`)
+		return err
+	}},
+	{3, "Add display variants table", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS wallpaper_variants (
+			wallpaper_id TEXT NOT NULL,
+			resolution TEXT NOT NULL,
+			mode TEXT NOT NULL,
+			path TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (wallpaper_id, resolution, mode),
+			FOREIGN KEY (wallpaper_id) REFERENCES wallpapers(id) ON DELETE CASCADE
+		)`)
+		return err
+	}},
+	{4, "Add navigation stack for previous and next", func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS navigation_stack (
+			position INTEGER PRIMARY KEY,
+			wallpaper_id TEXT NOT NULL,
+			added_at DATETIME NOT NULL,
+			FOREIGN KEY (wallpaper_id) REFERENCES wallpapers(id) ON DELETE CASCADE
+		)`); err != nil {
+			return err
+		}
+		if err := addColumn(tx, "view_state", "stack_position", "INTEGER"); err != nil {
+			return err
+		}
+
+		// Seed the stack with the order previous and next used to reconstruct from
+		// usage history, and point it at the current view
+		if _, err := tx.Exec(`
+		INSERT INTO navigation_stack (wallpaper_id, added_at)
+		SELECT wallpaper_id, last_used FROM (
+			SELECT wallpaper_id, MAX(used_at) AS last_used
+			FROM usage_history
+			GROUP BY wallpaper_id
+			ORDER BY last_used DESC
+			LIMIT ?
+		) ORDER BY last_used ASC`, constants.MaxNavigationSize); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+		UPDATE view_state SET stack_position = COALESCE(
+			(SELECT MAX(position) FROM navigation_stack WHERE wallpaper_id = view_state.current_wallpaper_id),
+			(SELECT MAX(position) FROM navigation_stack)
+		)`)
+		return err
+	}},
+	{5, "Add trash for removed wallpapers", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS trash (
+			wallpaper_id TEXT PRIMARY KEY,
+			original_path TEXT NOT NULL,
+			trash_path TEXT NOT NULL,
+			entry TEXT NOT NULL,
+			phash INTEGER,
+			reason TEXT NOT NULL,
+			trashed_at DATETIME NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_trash_trashed_at ON trash(trashed_at);
+		`)
+		return err
+	}},
+	{6, "Add blocklist for wallpapers, tags and uploaders", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS blocklist (
+			kind TEXT NOT NULL,
+			value TEXT NOT NULL,
+			added_at DATETIME NOT NULL,
+			PRIMARY KEY (kind, value)
+		)`)
+		return err
+	}},
+	{7, "Record uploader and colours for preference learning", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "wallpapers", "uploader", "TEXT"); err != nil {
+			return err
+		}
+		return addColumn(tx, "wallpapers", "colors", "TEXT")
+	}},
+	{8, "Add shuffle bags for no-repeat rotation", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS shuffle_bags (
+			bag TEXT NOT NULL,
+			wallpaper_id TEXT NOT NULL,
+			sort_key REAL NOT NULL,
+			drawn INTEGER,
+			PRIMARY KEY (bag, wallpaper_id)
+		)`)
+		return err
+	}},
+	{9, "Add playlists", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS playlists (
+			name TEXT PRIMARY KEY,
+			mode TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT -1,
+			active INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS playlist_items (
+			playlist TEXT NOT NULL,
+			wallpaper_id TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			PRIMARY KEY (playlist, wallpaper_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_playlist_items_position ON playlist_items(playlist, position);
+		`)
+		return err
+	}},
+	{10, "Keep wallhaven's tags apart from the user's", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS wallhaven_tags (
+			wallpaper_id TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (wallpaper_id, tag)
+		)`)
+		return err
+	}},
+	{11, "Keep uploader and colours of trashed wallpapers", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "trash", "uploader", "TEXT"); err != nil {
+			return err
+		}
+		return addColumn(tx, "trash", "colors", "TEXT")
+	}},
+}
+
+// SchemaVersion is the schema version this build migrates databases to
+var SchemaVersion = migrations[len(migrations)-1].version
+
+// MigrationInfo describes a schema migration and whether it has been applied
+type MigrationInfo struct {
+	Version     int
+	Description string
+	Applied     bool
+}
+
+// migrate applies pending migrations in order, each in its own transaction. An
+// existing database is backed up before the first migration runs.
+func (c *WallpaperCache) migrate() error {
+	current, err := c.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > SchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, SchemaVersion)
+	}
+	if current == SchemaVersion {
+		return nil
+	}
+
+	populated, err := c.hasTables()
+	if err != nil {
+		return err
+	}
+	if populated {
+		backup := filepath.Join(c.BackupDir(), backupName(fmt.Sprintf("-v%d", current)))
+		if err := c.backupTo(backup); err != nil {
+			return fmt.Errorf("failed to back up database before migrating: %w", err)
+		}
+		slog.Info("Backed up database before migrating", "path", backup)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := c.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+		slog.Debug("Applied migration", "version", m.version, "description", m.description)
+	}
+
+	if populated {
+		slog.Info("Migrated database", "from", current, "to", SchemaVersion)
+	}
+	return nil
+}
+
+// applyMigration runs a migration and records its version atomically
+func (c *WallpaperCache) applyMigration(m migration) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	// PRAGMA doesn't accept bound parameters
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, m.version)); err != nil {
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// schemaVersion returns the version recorded in the database
+func (c *WallpaperCache) schemaVersion() (int, error) {
+	var version int
+	if err := c.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// hasTables reports whether the database contains any tables yet
+func (c *WallpaperCache) hasTables() (bool, error) {
+	var count int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	return count > 0, nil
+}
+
+// backupTo writes a consistent copy of the database to path
+func (c *WallpaperCache) backupTo(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), constants.DirPermissions); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if _, err := c.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// MigrationStatus returns the database's schema version and every known migration
+func (c *WallpaperCache) MigrationStatus() (int, []MigrationInfo, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	current, err := c.schemaVersion()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	infos := make([]MigrationInfo, len(migrations))
+	for i, m := range migrations {
+		infos[i] = MigrationInfo{Version: m.version, Description: m.description, Applied: m.version <= current}
+	}
+	return current, infos, nil
+}
+
+// addColumn adds a column to a table if it does not exist yet
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil && name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package wallhaven
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// legacySchema is the schema databases were created with before migrations existed
+const legacySchema = `
+CREATE TABLE wallpapers (
+	id TEXT PRIMARY KEY,
+	path TEXT NOT NULL,
+	original_url TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	downloaded_at DATETIME NOT NULL,
+	last_used DATETIME NOT NULL,
+	use_count INTEGER NOT NULL DEFAULT 1,
+	categories TEXT NOT NULL,
+	purities TEXT NOT NULL,
+	resolution TEXT,
+	is_favorite BOOLEAN NOT NULL DEFAULT 0,
+	rating INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE wallpaper_tags (wallpaper_id TEXT NOT NULL, tag TEXT NOT NULL, PRIMARY KEY (wallpaper_id, tag));
+CREATE TABLE usage_history (id INTEGER PRIMARY KEY AUTOINCREMENT, wallpaper_id TEXT NOT NULL, used_at DATETIME NOT NULL);
+CREATE TABLE view_state (id INTEGER PRIMARY KEY CHECK (id = 1), current_wallpaper_id TEXT, updated_at DATETIME NOT NULL);
+`
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d", i, i+1, m.version)
+		}
+		if m.description == "" {
+			t.Errorf("Migration %d has no description", m.version)
+		}
+	}
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), ".cache")
+
+	cache, err := NewWallpaperCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	current, infos, err := cache.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus() error = %v", err)
+	}
+	if current != SchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", SchemaVersion, current)
+	}
+	for _, info := range infos {
+		if !info.Applied {
+			t.Errorf("Expected migration %d to be applied", info.Version)
+		}
+	}
+
+	// There is nothing to back up in a new database
+	if _, err := os.Stat(filepath.Join(cacheDir, constants.BackupDir)); !os.IsNotExist(err) {
+		t.Error("Expected no backup for a new database")
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, ".cache")
+	if err := os.MkdirAll(cacheDir, constants.DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	testFile := filepath.Join(tmpDir, "wallhaven-abc123.png")
+	writeTestImage(t, testFile, 200, 100, testBands...)
+
+	db, err := sql.Open("sqlite", filepath.Join(cacheDir, constants.DatabaseFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO wallpapers (id, path, original_url, hash, size, downloaded_at, last_used, categories, purities, rating)
+		VALUES ('old', ?, 'https://example.com/old.png', 'hash', 1, datetime('now'), datetime('now'), '010', '110', 4);
+		INSERT INTO usage_history (wallpaper_id, used_at) VALUES ('old', datetime('now'));
+		INSERT INTO view_state (id, current_wallpaper_id, updated_at) VALUES (1, 'old', datetime('now'));
+	`, testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	cache, err := NewWallpaperCache(cacheDir)
+	if err != nil {
+		t.Fatalf("NewWallpaperCache() error = %v", err)
+	}
+	defer cache.Close()
+
+	if current, _, _ := cache.MigrationStatus(); current != SchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", SchemaVersion, current)
+	}
+
+	// Existing data survives and new columns work
+	if old := cache.GetByID("old"); old == nil || old.Rating != 4 {
+		t.Errorf("Expected legacy wallpaper to survive migration, got %+v", old)
+	}
+	if _, err := cache.BackfillPerceptualHashes(); err != nil {
+		t.Errorf("Expected phash column after migration: %v", err)
+	}
+
+	// The navigation stack is seeded from usage history
+	var position int64
+	if err := cache.db.QueryRow(`SELECT stack_position FROM view_state WHERE id = 1`).Scan(&position); err != nil || position != 1 {
+		t.Errorf("Expected the current view at stack position 1, got %d (%v)", position, err)
+	}
+
+	// The pre-migration backup holds the legacy database
+	backups, err := filepath.Glob(filepath.Join(cacheDir, constants.BackupDir, "*-v0.db"))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected one pre-migration backup, got %v (%v)", backups, err)
+	}
+	backup, err := sql.Open("sqlite", backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	var version, count int
+	if err := backup.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil || version != 0 {
+		t.Errorf("Expected backup at version 0, got %d (%v)", version, err)
+	}
+	if err := backup.QueryRow(`SELECT COUNT(*) FROM wallpapers`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected backup to hold 1 wallpaper, got %d (%v)", count, err)
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), ".cache")
+
+	cache, err := NewWallpaperCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, SchemaVersion+1)); err != nil {
+		t.Fatal(err)
+	}
+	cache.Close()
+
+	if _, err := NewWallpaperCache(cacheDir); err == nil {
+		t.Error("Expected error opening a database from a newer version")
+	}
+}
@@ -0,0 +1,229 @@
+// Package wallhaven provides the browser-style back and forward stack behind the
+// previous and next commands
+package wallhaven
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// dbQuerier is satisfied by both *sql.DB and *sql.Tx
+type dbQuerier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// SetCurrentView makes a wallpaper the current one. Like opening a page in a
+// browser, it drops anything ahead of the current stack position and pushes the
+// wallpaper on top, unless it is already the current entry.
+func (c *WallpaperCache) SetCurrentView(wallpaperID string) error {
+	c.mu.Lock()
+	err := c.pushView(wallpaperID)
+	hooks := c.viewHooks
+	c.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	// Run hooks without the lock held so they can query the cache
+	for _, hook := range hooks {
+		hook(wallpaperID)
+	}
+	return nil
+}
+
+// pushView records wallpaperID as the current view on top of the stack; the
+// caller must hold the lock
+func (c *WallpaperCache) pushView(wallpaperID string) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	position := stackPosition(tx)
+
+	var currentID string
+	tx.QueryRow(`SELECT wallpaper_id FROM navigation_stack WHERE position = ?`, position).Scan(&currentID)
+	if currentID != wallpaperID {
+		if _, err := tx.Exec(`DELETE FROM navigation_stack WHERE position > ?`, position); err != nil {
+			return fmt.Errorf("failed to truncate navigation stack: %w", err)
+		}
+		result, err := tx.Exec(`INSERT INTO navigation_stack (wallpaper_id, added_at) VALUES (?, ?)`, wallpaperID, now)
+		if err != nil {
+			return fmt.Errorf("failed to push onto navigation stack: %w", err)
+		}
+		if position, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to read navigation stack position: %w", err)
+		}
+
+		// Forget the oldest entries once the stack is full
+		if _, err := tx.Exec(`DELETE FROM navigation_stack WHERE position <= ?`, position-constants.MaxNavigationSize); err != nil {
+			return fmt.Errorf("failed to trim navigation stack: %w", err)
+		}
+	}
+
+	if err := setView(tx, wallpaperID, position, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Navigate moves the current view the given number of steps along the stack,
+// backwards when steps is negative, and returns the wallpaper it lands on
+func (c *WallpaperCache) Navigate(steps int) (*WallpaperMetadata, error) {
+	if steps == 0 {
+		return nil, fmt.Errorf("steps must not be zero")
+	}
+
+	c.mu.Lock()
+	wallpaper, err := c.navigate(steps)
+	hooks := c.viewHooks
+	c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hook := range hooks {
+		hook(wallpaper.ID)
+	}
+	return wallpaper, nil
+}
+
+// navigate moves the stack position; the caller must hold the lock
+func (c *WallpaperCache) navigate(steps int) (*WallpaperMetadata, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	position, wallpaperID, ok := stackEntry(tx, steps)
+	if !ok {
+		if steps < 0 {
+			return nil, fmt.Errorf("no previous wallpaper available")
+		}
+		return nil, fmt.Errorf("no next wallpaper available")
+	}
+
+	// Resolve the wallpaper before moving so a failure leaves the view where it was
+	wallpaper := c.getByID(wallpaperID)
+	if wallpaper == nil {
+		return nil, fmt.Errorf("wallpaper not found in cache: %s", wallpaperID)
+	}
+
+	if err := setView(tx, wallpaperID, position, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return wallpaper, nil
+}
+
+// GetPrevious returns the wallpaper the given number of steps back on the
+// navigation stack without moving along it
+func (c *WallpaperCache) GetPrevious(steps int) *WallpaperMetadata {
+	return c.peek(-max(steps, 1))
+}
+
+// GetNext returns the wallpaper the given number of steps forward on the
+// navigation stack without moving along it
+func (c *WallpaperCache) GetNext(steps int) *WallpaperMetadata {
+	return c.peek(max(steps, 1))
+}
+
+// peek returns the wallpaper steps away from the current stack position, or nil
+// if there is none
+func (c *WallpaperCache) peek(steps int) *WallpaperMetadata {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, wallpaperID, ok := stackEntry(c.db, steps)
+	if !ok {
+		return nil
+	}
+
+	return c.getByID(wallpaperID)
+}
+
+// stackPosition returns the current position on the navigation stack, which is
+// the top when no view has been recorded
+func stackPosition(q dbQuerier) int64 {
+	var position sql.NullInt64
+	q.QueryRow(`SELECT stack_position FROM view_state WHERE id = 1`).Scan(&position)
+	if position.Valid {
+		return position.Int64
+	}
+
+	var top int64
+	q.QueryRow(`SELECT COALESCE(MAX(position), 0) FROM navigation_stack`).Scan(&top)
+	return top
+}
+
+// stackEntry finds the entry steps away from the current position. Entries for
+// wallpapers that have since been removed or whose file is gone are skipped, so
+// the view never lands on one. Both directions are a range scan over the primary
+// key that stops at the entry found.
+func stackEntry(q dbQuerier, steps int) (int64, string, bool) {
+	position := stackPosition(q)
+
+	query := `
+		SELECT s.position, s.wallpaper_id, w.path
+		FROM navigation_stack s
+		JOIN wallpapers w ON w.id = s.wallpaper_id
+		WHERE s.position > ?
+		ORDER BY s.position ASC`
+	if steps < 0 {
+		query = `
+		SELECT s.position, s.wallpaper_id, w.path
+		FROM navigation_stack s
+		JOIN wallpapers w ON w.id = s.wallpaper_id
+		WHERE s.position < ?
+		ORDER BY s.position DESC`
+		steps = -steps
+	}
+
+	rows, err := q.Query(query, position)
+	if err != nil {
+		return 0, "", false
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var wallpaperID, path string
+		if err := rows.Scan(&position, &wallpaperID, &path); err != nil {
+			return 0, "", false
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if steps--; steps == 0 {
+			return position, wallpaperID, true
+		}
+	}
+	return 0, "", false
+}
+
+// setView stores the current wallpaper and its stack position
+func setView(tx *sql.Tx, wallpaperID string, position int64, now time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO view_state (id, current_wallpaper_id, stack_position, updated_at)
+		VALUES (1, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			current_wallpaper_id = excluded.current_wallpaper_id,
+			stack_position = excluded.stack_position,
+			updated_at = excluded.updated_at
+	`, wallpaperID, position, now)
+	if err != nil {
+		return fmt.Errorf("failed to update view state: %w", err)
+	}
+	return nil
+}
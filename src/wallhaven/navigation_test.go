@@ -0,0 +1,153 @@
+package wallhaven
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newPopulatedTestCache returns a cache holding count wallpapers and their IDs
+func newPopulatedTestCache(t *testing.T, count int) (*WallpaperCache, []string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	cache, err := NewWallpaperCache(filepath.Join(tmpDir, ".cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	var ids []string
+	for i := range count {
+		testFile := filepath.Join(tmpDir, fmt.Sprintf("test%d.jpg", i))
+		if err := os.WriteFile(testFile, []byte(fmt.Sprintf("content %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wallpaper := &Wallpaper{Path: fmt.Sprintf("https://example.com/test%d.jpg", i)}
+		if err := cache.AddWallpaper(wallpaper, testFile, "010", "110"); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, GenerateID(wallpaper.Path))
+	}
+	return cache, ids
+}
+
+func TestWallpaperCache_Navigation(t *testing.T) {
+	cache, ids := newPopulatedTestCache(t, 5)
+
+	if cache.GetPrevious(1) != nil {
+		t.Error("Expected nothing to go back to on an empty stack")
+	}
+
+	for _, id := range ids[:4] {
+		if err := cache.SetCurrentView(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Setting the current wallpaper again doesn't push a duplicate
+	if err := cache.SetCurrentView(ids[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cache.GetPrevious(1); got == nil || got.ID != ids[2] {
+		t.Errorf("Expected previous to be %s, got %+v", ids[2], got)
+	}
+	if got := cache.GetPrevious(3); got == nil || got.ID != ids[0] {
+		t.Errorf("Expected three steps back to be %s, got %+v", ids[0], got)
+	}
+	if cache.GetPrevious(4) != nil || cache.GetNext(1) != nil {
+		t.Error("Expected nothing beyond either end of the stack")
+	}
+
+	got, err := cache.Navigate(-2)
+	if err != nil || got.ID != ids[1] {
+		t.Fatalf("Navigate(-2) = %+v, %v; want %s", got, err, ids[1])
+	}
+	if cache.GetCurrentView() != ids[1] {
+		t.Errorf("Expected current view %s, got %s", ids[1], cache.GetCurrentView())
+	}
+	if got := cache.GetNext(2); got == nil || got.ID != ids[3] {
+		t.Errorf("Expected two steps forward to be %s, got %+v", ids[3], got)
+	}
+	if _, err := cache.Navigate(3); err == nil {
+		t.Error("Expected an error navigating past the top of the stack")
+	}
+
+	// Reusing a wallpaper from earlier pushes it rather than jumping back to it,
+	// and drops everything that was ahead
+	if err := cache.SetCurrentView(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if cache.GetNext(1) != nil {
+		t.Error("Expected the forward entries to be dropped")
+	}
+	want := []string{ids[1], ids[0]}
+	for i, id := range want {
+		if got := cache.GetPrevious(i + 1); got == nil || got.ID != id {
+			t.Errorf("Expected %d steps back to be %s, got %+v", i+1, id, got)
+		}
+	}
+
+	// Removed wallpapers are skipped
+	if err := cache.RemoveWallpaper(ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.GetPrevious(1); got == nil || got.ID != ids[0] {
+		t.Errorf("Expected previous to skip the removed wallpaper, got %+v", got)
+	}
+}
+
+func TestWallpaperCache_NavigationSkipsMissingFiles(t *testing.T) {
+	cache, ids := newPopulatedTestCache(t, 3)
+
+	for _, id := range ids {
+		if err := cache.SetCurrentView(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A wallpaper whose file was deleted outside the application stays cached
+	if err := os.Remove(cache.GetByID(ids[1]).Path); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.GetPrevious(1); got == nil || got.ID != ids[0] {
+		t.Errorf("Expected previous to skip the missing file, got %+v", got)
+	}
+	got, err := cache.Navigate(-1)
+	if err != nil || got.ID != ids[0] {
+		t.Fatalf("Navigate(-1) = %+v, %v; want %s", got, err, ids[0])
+	}
+
+	// With nothing live behind it the view stays put
+	if err := os.Remove(got.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Navigate(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Navigate(-1); err == nil {
+		t.Error("Expected an error with only missing files behind")
+	}
+	if cache.GetCurrentView() != ids[2] {
+		t.Errorf("Expected the view to stay on %s, got %s", ids[2], cache.GetCurrentView())
+	}
+}
+
+func TestWallpaperCache_NavigationLimit(t *testing.T) {
+	cache, ids := newPopulatedTestCache(t, 2)
+
+	for i := range 150 {
+		if err := cache.SetCurrentView(ids[i%2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int
+	if err := cache.db.QueryRow(`SELECT COUNT(*) FROM navigation_stack`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 100 {
+		t.Errorf("Expected the stack to be trimmed to 100 entries, got %d", count)
+	}
+}
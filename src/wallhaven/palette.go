@@ -0,0 +1,255 @@
+// Package wallhaven provides colour palette extraction for wallpapers
+package wallhaven
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+)
+
+// maxPaletteSamples bounds the number of pixels sampled when extracting a palette
+const maxPaletteSamples = 64 * 1024
+
+// HexColor is an 8-bit RGB colour that renders as #rrggbb
+type HexColor struct {
+	R, G, B uint8
+}
+
+// String returns the colour as #rrggbb
+func (c HexColor) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Strip returns the colour as rrggbb without the leading hash
+func (c HexColor) Strip() string {
+	return c.String()[1:]
+}
+
+// RGB returns the colour as a comma separated "r,g,b" triple
+func (c HexColor) RGB() string {
+	return fmt.Sprintf("%d,%d,%d", c.R, c.G, c.B)
+}
+
+// MarshalJSON encodes the colour as a #rrggbb string
+func (c HexColor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// luminance returns the relative luminance of the colour in the range 0-1
+func (c HexColor) luminance() float64 {
+	return (0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)) / 255
+}
+
+// hue returns the hue of the colour in degrees
+func (c HexColor) hue() float64 {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxC := math.Max(r, math.Max(g, b))
+	minC := math.Min(r, math.Min(g, b))
+	delta := maxC - minC
+	if delta == 0 {
+		return 0
+	}
+
+	var h float64
+	switch maxC {
+	case r:
+		h = math.Mod((g-b)/delta, 6)
+	case g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// blend mixes the colour with other by the given amount (0 keeps c, 1 returns other)
+func (c HexColor) blend(other HexColor, amount float64) HexColor {
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a)*(1-amount) + float64(b)*amount))
+	}
+	return HexColor{R: mix(c.R, other.R), G: mix(c.G, other.G), B: mix(c.B, other.B)}
+}
+
+var (
+	black = HexColor{}
+	white = HexColor{R: 255, G: 255, B: 255}
+)
+
+// ColorScheme is a 16-colour terminal scheme derived from a wallpaper
+type ColorScheme struct {
+	Wallpaper  string       `json:"wallpaper"`
+	Background HexColor     `json:"background"`
+	Foreground HexColor     `json:"foreground"`
+	Cursor     HexColor     `json:"cursor"`
+	Colors     [16]HexColor `json:"colors"`
+}
+
+// ExtractPalette returns up to n dominant colours of an image, most common first
+func ExtractPalette(img image.Image, n int) []HexColor {
+	bounds := img.Bounds()
+	if n <= 0 || bounds.Empty() {
+		return nil
+	}
+
+	step := 1
+	for (bounds.Dx()/step)*(bounds.Dy()/step) > maxPaletteSamples {
+		step++
+	}
+
+	var pixels []HexColor
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			pixels = append(pixels, HexColor{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8)})
+		}
+	}
+
+	// Median cut: repeatedly split the bucket with the most spread along its widest channel
+	buckets := [][]HexColor{pixels}
+	for len(buckets) < n {
+		best, bestChannel, bestScore := -1, 0, 0
+		for i, bucket := range buckets {
+			channel, spread := widestChannel(bucket)
+			if score := spread * len(bucket); spread > 0 && score > bestScore {
+				best, bestChannel, bestScore = i, channel, score
+			}
+		}
+		if best < 0 {
+			break // every bucket is a single colour
+		}
+
+		bucket := buckets[best]
+		slices.SortFunc(bucket, func(a, b HexColor) int {
+			return cmp.Compare(channelValue(a, bestChannel), channelValue(b, bestChannel))
+		})
+
+		// Split on a value boundary near the median so equal colours stay together
+		median := channelValue(bucket[len(bucket)/2], bestChannel)
+		split, _ := slices.BinarySearchFunc(bucket, median, func(c HexColor, v uint8) int {
+			return cmp.Compare(channelValue(c, bestChannel), v)
+		})
+		if split == 0 {
+			split, _ = slices.BinarySearchFunc(bucket, median+1, func(c HexColor, v uint8) int {
+				return cmp.Compare(channelValue(c, bestChannel), v)
+			})
+		}
+
+		buckets[best] = bucket[:split]
+		buckets = append(buckets, bucket[split:])
+	}
+
+	slices.SortFunc(buckets, func(a, b []HexColor) int { return cmp.Compare(len(b), len(a)) })
+	palette := make([]HexColor, 0, len(buckets))
+	for _, bucket := range buckets {
+		palette = append(palette, averageColor(bucket))
+	}
+	return palette
+}
+
+// widestChannel returns the index (0=R, 1=G, 2=B) of the channel with the largest range and that range
+func widestChannel(pixels []HexColor) (int, int) {
+	lo := [3]uint8{255, 255, 255}
+	hi := [3]uint8{}
+	for _, p := range pixels {
+		for i := range 3 {
+			v := channelValue(p, i)
+			lo[i] = min(lo[i], v)
+			hi[i] = max(hi[i], v)
+		}
+	}
+
+	widest := 0
+	for i := 1; i < 3; i++ {
+		if hi[i]-lo[i] > hi[widest]-lo[widest] {
+			widest = i
+		}
+	}
+	return widest, int(hi[widest] - lo[widest])
+}
+
+func channelValue(c HexColor, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+func averageColor(pixels []HexColor) HexColor {
+	var r, g, b int
+	for _, p := range pixels {
+		r += int(p.R)
+		g += int(p.G)
+		b += int(p.B)
+	}
+	n := len(pixels)
+	return HexColor{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n)}
+}
+
+// NewColorScheme builds a terminal colour scheme from a palette of dominant colours.
+// The darkest colour becomes the background, the lightest the foreground, and the
+// remaining colours (ordered by hue) fill the six accent slots.
+func NewColorScheme(palette []HexColor) *ColorScheme {
+	if len(palette) == 0 {
+		palette = []HexColor{black, white}
+	}
+
+	byLuminance := slices.Clone(palette)
+	slices.SortFunc(byLuminance, func(a, b HexColor) int { return cmp.Compare(a.luminance(), b.luminance()) })
+
+	darkest := byLuminance[0]
+	lightest := byLuminance[len(byLuminance)-1]
+
+	background := darkest.blend(black, 0.6)
+	foreground := lightest.blend(white, 0.75)
+
+	accents := slices.Clone(byLuminance[1:])
+	if len(accents) == 0 {
+		accents = []HexColor{lightest}
+	}
+	slices.SortFunc(accents, func(a, b HexColor) int { return cmp.Compare(a.hue(), b.hue()) })
+
+	scheme := &ColorScheme{
+		Background: background,
+		Foreground: foreground,
+		Cursor:     foreground,
+	}
+
+	scheme.Colors[0] = background
+	scheme.Colors[8] = background.blend(white, 0.3)
+	for i := range 6 {
+		accent := accents[i%len(accents)]
+		// Keep accents readable against the dark background
+		for step := 0; accent.luminance() < 0.35 && step < 10; step++ {
+			accent = accent.blend(white, 0.15)
+		}
+		scheme.Colors[i+1] = accent
+		scheme.Colors[i+9] = accent.blend(white, 0.2)
+	}
+	scheme.Colors[7] = foreground.blend(background, 0.15)
+	scheme.Colors[15] = foreground
+
+	return scheme
+}
+
+// GenerateColorScheme derives a colour scheme from the image at filePath
+func GenerateColorScheme(filePath string) (*ColorScheme, error) {
+	img, err := loadImage(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := NewColorScheme(ExtractPalette(img, 8))
+	scheme.Wallpaper = filePath
+	return scheme, nil
+}
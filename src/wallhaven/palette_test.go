@@ -0,0 +1,98 @@
+package wallhaven
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestImage writes a PNG split into vertical bands of the given colours
+func writeTestImage(t *testing.T, path string, width, height int, bands ...color.RGBA) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		c := bands[x*len(bands)/width]
+		for y := range height {
+			img.Set(x, y, c)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractPalette(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	for x := range 100 {
+		for y := range 100 {
+			if x < 75 {
+				img.Set(x, y, color.RGBA{R: 200, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 200, A: 255})
+			}
+		}
+	}
+
+	palette := ExtractPalette(img, 2)
+	if len(palette) != 2 {
+		t.Fatalf("Expected 2 colours, got %d", len(palette))
+	}
+
+	// Most common colour comes first
+	if palette[0] != (HexColor{R: 200}) {
+		t.Errorf("Expected dominant colour #c80000, got %s", palette[0])
+	}
+	if palette[1] != (HexColor{B: 200}) {
+		t.Errorf("Expected second colour #0000c8, got %s", palette[1])
+	}
+}
+
+func TestGenerateColorScheme(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.png")
+	writeTestImage(t, path, 64, 16,
+		color.RGBA{R: 20, G: 20, B: 30, A: 255},
+		color.RGBA{R: 180, G: 60, B: 60, A: 255},
+		color.RGBA{R: 60, G: 160, B: 80, A: 255},
+		color.RGBA{R: 230, G: 230, B: 220, A: 255},
+	)
+
+	scheme, err := GenerateColorScheme(path)
+	if err != nil {
+		t.Fatalf("GenerateColorScheme() error = %v", err)
+	}
+
+	if scheme.Wallpaper != path {
+		t.Errorf("Expected wallpaper %s, got %s", path, scheme.Wallpaper)
+	}
+	if scheme.Background.luminance() >= scheme.Foreground.luminance() {
+		t.Errorf("Expected background %s to be darker than foreground %s", scheme.Background, scheme.Foreground)
+	}
+	if scheme.Colors[0] != scheme.Background || scheme.Colors[15] != scheme.Foreground {
+		t.Error("Expected color0 and color15 to match background and foreground")
+	}
+}
+
+func TestHexColor(t *testing.T) {
+	c := HexColor{R: 0x12, G: 0xab, B: 0xff}
+
+	if c.String() != "#12abff" {
+		t.Errorf("Expected #12abff, got %s", c.String())
+	}
+	if c.Strip() != "12abff" {
+		t.Errorf("Expected 12abff, got %s", c.Strip())
+	}
+	if c.RGB() != "18,171,255" {
+		t.Errorf("Expected 18,171,255, got %s", c.RGB())
+	}
+}
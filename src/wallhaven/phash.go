@@ -0,0 +1,99 @@
+// Package wallhaven provides perceptual hashing for near-duplicate detection
+package wallhaven
+
+import (
+	"cmp"
+	"image"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CalculatePerceptualHash computes a 64-bit difference hash (dHash) of the image at
+// filePath. Unlike CalculateFileHash it is stable across resizing and re-encoding.
+func CalculatePerceptualHash(filePath string) (uint64, error) {
+	img, err := loadImage(filePath)
+	if err != nil {
+		return 0, err
+	}
+	return perceptualHash(img), nil
+}
+
+// perceptualHash shrinks img to 9x8 greyscale and sets one bit per pixel that is
+// brighter than its right-hand neighbour
+func perceptualHash(img image.Image) uint64 {
+	small := resizeImage(img, 9, 8)
+
+	var hash uint64
+	for y := range 8 {
+		for x := range 8 {
+			left := small.Pix[y*small.Stride+x*4:]
+			right := small.Pix[y*small.Stride+(x+1)*4:]
+			if grey(left) > grey(right) {
+				hash |= 1 << (y*8 + x)
+			}
+		}
+	}
+	return hash
+}
+
+// grey returns the luma of an RGBA pixel
+func grey(p []uint8) int {
+	return (299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])) / 1000
+}
+
+// HammingDistance returns the number of bits that differ between two perceptual hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// ResolutionPixels returns the pixel count of a "WIDTHxHEIGHT" resolution, or 0 if unknown
+func ResolutionPixels(resolution string) int {
+	w, h, ok := ParseResolution(resolution)
+	if !ok {
+		return 0
+	}
+	return w * h
+}
+
+// ParseResolution splits a "WIDTHxHEIGHT" resolution into its dimensions
+func ParseResolution(resolution string) (int, int, bool) {
+	ws, hs, ok := strings.Cut(resolution, "x")
+	if !ok {
+		return 0, 0, false
+	}
+	w, err := strconv.Atoi(ws)
+	if err != nil {
+		return 0, 0, false
+	}
+	h, err := strconv.Atoi(hs)
+	if err != nil {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+// BestCopy returns the copy of a near-duplicate group worth keeping: favourites
+// first, then the highest resolution, then the highest rating and use count
+func BestCopy(group []*WallpaperMetadata) *WallpaperMetadata {
+	if len(group) == 0 {
+		return nil
+	}
+	return slices.MaxFunc(group, compareCopies)
+}
+
+// compareCopies orders two copies of the same image by how worth keeping they are
+func compareCopies(a, b *WallpaperMetadata) int {
+	if a.IsFavorite != b.IsFavorite {
+		if a.IsFavorite {
+			return 1
+		}
+		return -1
+	}
+	return cmp.Or(
+		cmp.Compare(ResolutionPixels(a.Resolution), ResolutionPixels(b.Resolution)),
+		cmp.Compare(a.Rating, b.Rating),
+		cmp.Compare(a.UseCount, b.UseCount),
+	)
+}
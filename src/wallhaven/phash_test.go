@@ -0,0 +1,154 @@
+package wallhaven
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+var testBands = []color.RGBA{
+	{R: 20, G: 20, B: 30, A: 255},
+	{R: 180, G: 60, B: 60, A: 255},
+	{R: 60, G: 160, B: 80, A: 255},
+	{R: 230, G: 230, B: 220, A: 255},
+	{R: 90, G: 40, B: 160, A: 255},
+}
+
+func TestCalculatePerceptualHash(t *testing.T) {
+	tmpDir := t.TempDir()
+	large := filepath.Join(tmpDir, "large.png")
+	small := filepath.Join(tmpDir, "small.png")
+	other := filepath.Join(tmpDir, "other.png")
+
+	writeTestImage(t, large, 400, 200, testBands...)
+	writeTestImage(t, small, 100, 50, testBands...)
+	writeTestImage(t, other, 400, 200, testBands[4], testBands[3], testBands[0], testBands[2], testBands[1])
+
+	largeHash, err := CalculatePerceptualHash(large)
+	if err != nil {
+		t.Fatalf("CalculatePerceptualHash() error = %v", err)
+	}
+	smallHash, err := CalculatePerceptualHash(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherHash, err := CalculatePerceptualHash(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := HammingDistance(largeHash, smallHash); d > constants.DefaultSimilarityThreshold {
+		t.Errorf("Expected resized copy to be within %d bits, got %d", constants.DefaultSimilarityThreshold, d)
+	}
+	if d := HammingDistance(largeHash, otherHash); d <= constants.DefaultSimilarityThreshold {
+		t.Errorf("Expected different image to differ by more than %d bits, got %d", constants.DefaultSimilarityThreshold, d)
+	}
+}
+
+func TestWallpaperCache_NearDuplicates(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, ".cache")
+
+	large := filepath.Join(tmpDir, "large.png")
+	small := filepath.Join(tmpDir, "small.png")
+	writeTestImage(t, large, 400, 200, testBands...)
+	writeTestImage(t, small, 100, 50, testBands...)
+
+	cache, err := NewWallpaperCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	smallWallpaper := &Wallpaper{Path: "https://example.com/small.png"}
+	largeWallpaper := &Wallpaper{Path: "https://example.com/large.png"}
+	if err := cache.AddWallpaper(smallWallpaper, small, "010", "110"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AddWallpaper(largeWallpaper, large, "010", "110"); err != nil {
+		t.Fatal(err)
+	}
+
+	smallID := GenerateID(smallWallpaper.Path)
+	largeID := GenerateID(largeWallpaper.Path)
+	if err := cache.SetRating(smallID, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AddTags(smallID, []string{"landscape"}); err != nil {
+		t.Fatal(err)
+	}
+
+	groups := cache.FindNearDuplicates(constants.DefaultSimilarityThreshold)
+	if len(groups) != 1 || len(groups[0]) != 2 {
+		t.Fatalf("Expected one group of 2 near-duplicates, got %v", groups)
+	}
+
+	keep := BestCopy(groups[0])
+	if keep.ID != largeID {
+		t.Errorf("Expected higher resolution copy to be kept, got %s", keep.Path)
+	}
+
+	if err := cache.MergeDuplicate(largeID, smallID); err != nil {
+		t.Fatalf("MergeDuplicate() error = %v", err)
+	}
+
+	if _, err := os.Stat(small); !os.IsNotExist(err) {
+		t.Error("Expected removed duplicate file to be moved away")
+	}
+	trashed, err := cache.ListTrash()
+	if err != nil || len(trashed) != 1 || trashed[0].ID != smallID || trashed[0].Reason != constants.TrashReasonDuplicate {
+		t.Fatalf("Expected the removed duplicate in the trash, got %+v, %v", trashed, err)
+	}
+	if _, err := os.Stat(trashed[0].TrashPath); err != nil {
+		t.Errorf("Expected the duplicate's file in the trash: %v", err)
+	}
+
+	merged := cache.GetByID(largeID)
+	if merged == nil {
+		t.Fatal("Expected kept wallpaper to remain in cache")
+	}
+	if merged.Rating != 5 {
+		t.Errorf("Expected rating to carry over, got %d", merged.Rating)
+	}
+	if len(merged.Tags) != 1 || merged.Tags[0] != "landscape" {
+		t.Errorf("Expected tags to carry over, got %v", merged.Tags)
+	}
+	if merged.UseCount != 2 {
+		t.Errorf("Expected use counts to be summed, got %d", merged.UseCount)
+	}
+}
+
+func TestBestCopyPrefersFavorite(t *testing.T) {
+	favorite := &WallpaperMetadata{ID: "fav", Resolution: "1280x720", IsFavorite: true}
+	larger := &WallpaperMetadata{ID: "large", Resolution: "3840x2160"}
+
+	if keep := BestCopy([]*WallpaperMetadata{larger, favorite}); keep != favorite {
+		t.Errorf("Expected favourite to be kept, got %s", keep.ID)
+	}
+}
+
+func TestWallpaperCache_NearDuplicatesDoNotChain(t *testing.T) {
+	cache, ids := newPopulatedTestCache(t, 3)
+
+	// Each hash is 4 bits from the next, so the first and last are 8 apart
+	for i, phash := range []int64{0x0, 0xf, 0xff} {
+		if _, err := cache.db.Exec(`UPDATE wallpapers SET phash = ? WHERE id = ?`, phash, ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups := cache.FindNearDuplicates(4)
+	if len(groups) != 1 || len(groups[0]) != 2 {
+		t.Fatalf("Expected one group of 2 near-duplicates, got %v", groups)
+	}
+	inGroup := func(id string) bool {
+		return slices.ContainsFunc(groups[0], func(w *WallpaperMetadata) bool { return w.ID == id })
+	}
+	if inGroup(ids[0]) && inGroup(ids[2]) {
+		t.Error("Expected wallpapers 8 bits apart not to share a group")
+	}
+}
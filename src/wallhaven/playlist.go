@@ -0,0 +1,417 @@
+// Package wallhaven provides named, ordered playlists of cached wallpapers
+package wallhaven
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// Playlist is a named, ordered set of wallpapers played in sequence or shuffled
+type Playlist struct {
+	Name      string    `json:"name"`
+	Mode      string    `json:"mode"`
+	Active    bool      `json:"active"`
+	Count     int       `json:"count"`
+	Current   string    `json:"current,omitempty"` // ID of the wallpaper played last
+	CreatedAt time.Time `json:"created_at"`
+
+	position int64 // Item position played last, -1 before the first
+}
+
+// playlistColumns are the columns scanPlaylist reads
+const playlistColumns = `
+	p.name, p.mode, p.active, p.position, p.created_at,
+	(SELECT COUNT(*) FROM playlist_items i WHERE i.playlist = p.name),
+	COALESCE((SELECT wallpaper_id FROM playlist_items i WHERE i.playlist = p.name AND i.position = p.position), '')`
+
+// scanPlaylist reads a playlist selected with playlistColumns
+func scanPlaylist(row interface{ Scan(...any) error }) (*Playlist, error) {
+	p := &Playlist{}
+	err := row.Scan(&p.Name, &p.Mode, &p.Active, &p.position, &p.CreatedAt, &p.Count, &p.Current)
+	return p, err
+}
+
+// playlistBag names the shuffle bag a playlist draws from in shuffle mode
+func playlistBag(name string) string {
+	return "playlist:" + name
+}
+
+// CreatePlaylist creates an empty playlist played in the given mode (one of
+// constants.ValidPlaylistModes)
+func (c *WallpaperCache) CreatePlaylist(name, mode string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("a playlist name is required")
+	}
+	if !slices.Contains(constants.ValidPlaylistModes, mode) {
+		return fmt.Errorf("invalid playlist mode: %s", mode)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result, err := c.db.Exec(`INSERT OR IGNORE INTO playlists (name, mode, created_at) VALUES (?, ?, ?)`,
+		name, mode, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to create playlist: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("playlist already exists: %s", name)
+	}
+	return nil
+}
+
+// DeletePlaylist deletes a playlist. Its wallpapers stay in the cache.
+func (c *WallpaperCache) DeletePlaylist(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM playlists WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete playlist: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("playlist not found: %s", name)
+	}
+	if _, err := tx.Exec(`DELETE FROM playlist_items WHERE playlist = ?`, name); err != nil {
+		return fmt.Errorf("failed to delete playlist items: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM shuffle_bags WHERE bag = ?`, playlistBag(name)); err != nil {
+		return fmt.Errorf("failed to delete playlist shuffle bag: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetPlaylist returns a playlist by name
+func (c *WallpaperCache) GetPlaylist(name string) (*Playlist, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	playlist, err := scanPlaylist(c.db.QueryRow(`SELECT `+playlistColumns+` FROM playlists p WHERE p.name = ?`, name))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("playlist not found: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query playlist: %w", err)
+	}
+	return playlist, nil
+}
+
+// ListPlaylists returns every playlist, by name
+func (c *WallpaperCache) ListPlaylists() ([]*Playlist, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rows, err := c.db.Query(`SELECT ` + playlistColumns + ` FROM playlists p ORDER BY p.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query playlists: %w", err)
+	}
+	defer rows.Close()
+
+	var playlists []*Playlist
+	for rows.Next() {
+		playlist, err := scanPlaylist(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan playlist: %w", err)
+		}
+		playlists = append(playlists, playlist)
+	}
+	return playlists, rows.Err()
+}
+
+// GetActivePlaylist returns the playlist next and previous step through, or nil
+// when none is playing
+func (c *WallpaperCache) GetActivePlaylist() *Playlist {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	playlist, err := scanPlaylist(c.db.QueryRow(`SELECT ` + playlistColumns + ` FROM playlists p WHERE p.active LIMIT 1`))
+	if err != nil {
+		return nil
+	}
+	return playlist
+}
+
+// SetActivePlaylist makes a playlist the one next and previous step through, or
+// stops playing any when name is empty
+func (c *WallpaperCache) SetActivePlaylist(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if name != "" {
+		var exists bool
+		if err := c.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM playlists WHERE name = ?)`, name).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to query playlist: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("playlist not found: %s", name)
+		}
+	}
+
+	if _, err := c.db.Exec(`UPDATE playlists SET active = (name = ?)`, name); err != nil {
+		return fmt.Errorf("failed to set active playlist: %w", err)
+	}
+	return nil
+}
+
+// SetPlaylistMode changes how a playlist is played
+func (c *WallpaperCache) SetPlaylistMode(name, mode string) error {
+	if !slices.Contains(constants.ValidPlaylistModes, mode) {
+		return fmt.Errorf("invalid playlist mode: %s", mode)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result, err := c.db.Exec(`UPDATE playlists SET mode = ? WHERE name = ?`, mode, name)
+	if err != nil {
+		return fmt.Errorf("failed to set playlist mode: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("playlist not found: %s", name)
+	}
+	return nil
+}
+
+// ResetPlaylist starts a playlist over: from the first wallpaper in sequential
+// mode and with a new round in shuffle mode
+func (c *WallpaperCache) ResetPlaylist(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.db.Exec(`UPDATE playlists SET position = -1 WHERE name = ?`, name); err != nil {
+		return fmt.Errorf("failed to reset playlist: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM shuffle_bags WHERE bag = ?`, playlistBag(name)); err != nil {
+		return fmt.Errorf("failed to reset playlist shuffle bag: %w", err)
+	}
+	return nil
+}
+
+// AddToPlaylist adds wallpapers to a playlist in the given order and returns how
+// many were added. Wallpapers already in it are skipped. With at between 1 and
+// the playlist's length they go before the wallpaper at that place, otherwise
+// they are appended.
+func (c *WallpaperCache) AddToPlaylist(name string, ids []string, at int) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current int64
+	if err := tx.QueryRow(`SELECT position FROM playlists WHERE name = ?`, name).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("playlist not found: %s", name)
+		}
+		return 0, fmt.Errorf("failed to query playlist: %w", err)
+	}
+
+	members, positions, err := playlistPositions(tx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	var added []string
+	for _, id := range ids {
+		if _, ok := members[id]; !ok && !slices.Contains(added, id) {
+			added = append(added, id)
+		}
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	var base int64
+	if len(positions) > 0 {
+		base = positions[len(positions)-1] + 1
+	}
+	if at >= 1 && at <= len(positions) {
+		// Make room, keeping the playlist's place on the wallpaper it was on
+		base = positions[at-1]
+		shift := int64(len(added))
+		if _, err := tx.Exec(`UPDATE playlist_items SET position = position + ? WHERE playlist = ? AND position >= ?`,
+			shift, name, base); err != nil {
+			return 0, fmt.Errorf("failed to reorder playlist: %w", err)
+		}
+		if current >= base {
+			if _, err := tx.Exec(`UPDATE playlists SET position = position + ? WHERE name = ?`, shift, name); err != nil {
+				return 0, fmt.Errorf("failed to update playlist position: %w", err)
+			}
+		}
+	}
+
+	for i, id := range added {
+		if _, err := tx.Exec(`INSERT INTO playlist_items (playlist, wallpaper_id, position) VALUES (?, ?, ?)`,
+			name, id, base+int64(i)); err != nil {
+			return 0, fmt.Errorf("failed to add to playlist: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(added), nil
+}
+
+// RemoveFromPlaylist removes wallpapers from a playlist and returns how many were
+// in it
+func (c *WallpaperCache) RemoveFromPlaylist(name string, ids []string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var removed int64
+	for _, id := range ids {
+		result, err := c.db.Exec(`DELETE FROM playlist_items WHERE playlist = ? AND wallpaper_id = ?`, name, id)
+		if err != nil {
+			return int(removed), fmt.Errorf("failed to remove from playlist: %w", err)
+		}
+		rows, _ := result.RowsAffected()
+		removed += rows
+	}
+	return int(removed), nil
+}
+
+// GetPlaylistItems returns a playlist's wallpapers in order, skipping any whose
+// file is gone
+func (c *WallpaperCache) GetPlaylistItems(name string) ([]*WallpaperMetadata, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	items, _, err := c.playlistItems(name)
+	return items, err
+}
+
+// playlistItems returns a playlist's wallpapers in order along with each one's
+// position; the caller must hold the lock
+func (c *WallpaperCache) playlistItems(name string) ([]*WallpaperMetadata, map[string]int64, error) {
+	rows, err := c.db.Query(`
+		SELECT w.id, w.path, w.original_url, w.hash, w.size, w.downloaded_at, w.last_used,
+		       w.use_count, w.categories, w.purities, COALESCE(w.resolution, ''), w.is_favorite, w.rating
+		FROM playlist_items i
+		JOIN wallpapers w ON w.id = i.wallpaper_id
+		WHERE i.playlist = ?
+		ORDER BY i.position
+	`, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query playlist items: %w", err)
+	}
+	items := c.scanWallpapers(rows)
+	rows.Close()
+
+	positions, _, err := playlistPositions(c.db, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, positions, nil
+}
+
+// playlistPositions returns the position of every wallpaper in a playlist, and
+// the positions in order
+func playlistPositions(q dbQuerier, name string) (map[string]int64, []int64, error) {
+	rows, err := q.Query(`SELECT wallpaper_id, position FROM playlist_items WHERE playlist = ? ORDER BY position`, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query playlist items: %w", err)
+	}
+	defer rows.Close()
+
+	members := make(map[string]int64)
+	var positions []int64
+	for rows.Next() {
+		var id string
+		var position int64
+		if err := rows.Scan(&id, &position); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan playlist item: %w", err)
+		}
+		members[id] = position
+		positions = append(positions, position)
+	}
+	return members, positions, rows.Err()
+}
+
+// AdvancePlaylist moves a playlist the given number of steps, backwards when
+// steps is negative, records its new place and returns the wallpaper there.
+// Sequential playlists start over at either end; shuffled ones draw from their
+// shuffle bag and only step forward.
+func (c *WallpaperCache) AdvancePlaylist(name string, steps int) (*WallpaperMetadata, error) {
+	if steps == 0 {
+		return nil, fmt.Errorf("steps must not be zero")
+	}
+
+	playlist, err := c.GetPlaylist(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	items, positions, err := c.playlistItems(name)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("playlist is empty: %s", name)
+	}
+
+	var next *WallpaperMetadata
+	if playlist.Mode == constants.PlaylistModeShuffle {
+		if steps < 0 {
+			return nil, fmt.Errorf("a shuffled playlist can only step forward")
+		}
+		for range steps {
+			if next, err = c.NextShuffled(playlistBag(name), items, false); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		next = items[stepIndex(items, positions, playlist.position, steps)]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.db.Exec(`UPDATE playlists SET position = ? WHERE name = ?`, positions[next.ID], name); err != nil {
+		return nil, fmt.Errorf("failed to update playlist position: %w", err)
+	}
+	return next, nil
+}
+
+// stepIndex returns the index into items that lies steps away from position,
+// wrapping around at either end. The wallpaper at position may have been removed
+// since, so the place is found by position rather than by index.
+func stepIndex(items []*WallpaperMetadata, positions map[string]int64, position int64, steps int) int {
+	var before, after int
+	for _, item := range items {
+		if positions[item.ID] < position {
+			before++
+		}
+		if positions[item.ID] <= position {
+			after++
+		}
+	}
+
+	index := after + steps - 1
+	if steps < 0 {
+		index = before + steps
+	}
+	n := len(items)
+	return ((index % n) + n) % n
+}
@@ -0,0 +1,109 @@
+package wallhaven
+
+import (
+	"testing"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+func TestWallpaperCache_Playlist(t *testing.T) {
+	cache, ids := newPopulatedTestCache(t, 4)
+
+	if err := cache.CreatePlaylist("calm", constants.PlaylistModeSequential); err != nil {
+		t.Fatalf("CreatePlaylist() error = %v", err)
+	}
+	if err := cache.CreatePlaylist("calm", constants.PlaylistModeSequential); err == nil {
+		t.Error("Expected a duplicate playlist to be rejected")
+	}
+	if err := cache.CreatePlaylist("loud", "backwards"); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+
+	if added, err := cache.AddToPlaylist("calm", []string{ids[0], ids[2], ids[0]}, 0); err != nil || added != 2 {
+		t.Fatalf("AddToPlaylist() = %d, %v", added, err)
+	}
+	if added, err := cache.AddToPlaylist("calm", []string{ids[1], ids[2]}, 2); err != nil || added != 1 {
+		t.Fatalf("AddToPlaylist() at 2 = %d, %v", added, err)
+	}
+
+	order := func() []string {
+		t.Helper()
+		items, err := cache.GetPlaylistItems("calm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, item := range items {
+			got = append(got, item.ID)
+		}
+		return got
+	}
+	if got := order(); len(got) != 3 || got[0] != ids[0] || got[1] != ids[1] || got[2] != ids[2] {
+		t.Fatalf("Expected the inserted wallpaper second, got %v", got)
+	}
+
+	advance := func(steps int, want string) {
+		t.Helper()
+		w, err := cache.AdvancePlaylist("calm", steps)
+		if err != nil {
+			t.Fatalf("AdvancePlaylist(%d) error = %v", steps, err)
+		}
+		if w.ID != want {
+			t.Errorf("AdvancePlaylist(%d) = %s, want %s", steps, w.ID, want)
+		}
+	}
+	advance(1, ids[0])
+	advance(1, ids[1])
+	advance(2, ids[0])  // Wraps past the end
+	advance(-1, ids[2]) // and back past the start
+
+	// Removing the current wallpaper keeps the playlist's place
+	advance(-1, ids[1])
+	if removed, err := cache.RemoveFromPlaylist("calm", []string{ids[1]}); err != nil || removed != 1 {
+		t.Fatalf("RemoveFromPlaylist() = %d, %v", removed, err)
+	}
+	advance(1, ids[2])
+
+	// Inserting before the current wallpaper doesn't move the playlist off it
+	if _, err := cache.AddToPlaylist("calm", []string{ids[3]}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if playlist, _ := cache.GetPlaylist("calm"); playlist.Current != ids[2] || playlist.Count != 3 {
+		t.Errorf("Expected %s current of 3, got %s of %d", ids[2], playlist.Current, playlist.Count)
+	}
+
+	if cache.GetActivePlaylist() != nil {
+		t.Error("Expected no active playlist before playing")
+	}
+	if err := cache.SetActivePlaylist("calm"); err != nil {
+		t.Fatal(err)
+	}
+	if active := cache.GetActivePlaylist(); active == nil || active.Name != "calm" {
+		t.Errorf("GetActivePlaylist() = %+v", active)
+	}
+
+	if err := cache.SetPlaylistMode("calm", constants.PlaylistModeShuffle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.AdvancePlaylist("calm", -1); err == nil {
+		t.Error("Expected a shuffled playlist to refuse stepping back")
+	}
+	seen := make(map[string]bool)
+	for range 3 {
+		w, err := cache.AdvancePlaylist("calm", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[w.ID] {
+			t.Errorf("Shuffled playlist repeated %s within a round", w.ID)
+		}
+		seen[w.ID] = true
+	}
+
+	if err := cache.DeletePlaylist("calm"); err != nil {
+		t.Fatal(err)
+	}
+	if playlists, _ := cache.ListPlaylists(); len(playlists) != 0 {
+		t.Errorf("Expected no playlists after deleting, got %d", len(playlists))
+	}
+}
@@ -0,0 +1,186 @@
+// Package wallhaven provides a preference model learned from ratings, favourites
+// and use
+package wallhaven
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// Preferences weighs tags, uploaders and colours by how the wallpapers carrying
+// them were received. Positive weights are liked and negative ones disliked.
+type Preferences struct {
+	Tags      map[string]float64 `json:"tags"`
+	Uploaders map[string]float64 `json:"uploaders"`
+	Colors    map[string]float64 `json:"colors"`
+}
+
+// featureScore is the total score of the wallpapers sharing a feature
+type featureScore struct {
+	sum   float64
+	count int
+}
+
+// tally accumulates the scores of each feature
+type tally map[string]*featureScore
+
+func (t tally) add(feature string, score float64) {
+	if feature == "" {
+		return
+	}
+	entry, ok := t[feature]
+	if !ok {
+		entry = &featureScore{}
+		t[feature] = entry
+	}
+	entry.sum += score
+	entry.count++
+}
+
+// weights turns the tallies into weights, shrinking features seen only a few
+// times towards zero so one lucky wallpaper doesn't dominate
+func (t tally) weights() map[string]float64 {
+	weights := make(map[string]float64, len(t))
+	for feature, entry := range t {
+		weights[feature] = entry.sum / float64(entry.count+1)
+	}
+	return weights
+}
+
+// preferenceScore is how much a wallpaper was liked: its rating relative to
+// NeutralRating, a bonus for favourites and a little for repeated use
+func preferenceScore(rating int, favorite bool, useCount int) float64 {
+	var score float64
+	if rating > 0 {
+		score += float64(rating - constants.NeutralRating)
+	}
+	if favorite {
+		score += constants.FavoriteWeight
+	}
+	if useCount > 1 {
+		score += math.Min(math.Log2(float64(useCount))/4, constants.MaxUseWeight)
+	}
+	return score
+}
+
+// LearnPreferences builds the preference model from every cached wallpaper
+func (c *WallpaperCache) LearnPreferences() (*Preferences, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rows, err := c.db.Query(`
+		SELECT id, rating, is_favorite, use_count, COALESCE(uploader, ''), COALESCE(colors, '')
+		FROM wallpapers
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wallpapers: %w", err)
+	}
+
+	scores := make(map[string]float64)
+	uploaders, colors := tally{}, tally{}
+	for rows.Next() {
+		var id, uploader, colorList string
+		var rating, useCount int
+		var favorite bool
+		if err := rows.Scan(&id, &rating, &favorite, &useCount, &uploader, &colorList); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan wallpaper: %w", err)
+		}
+
+		score := preferenceScore(rating, favorite, useCount)
+		scores[id] = score
+		uploaders.add(uploader, score)
+		for _, color := range strings.Split(colorList, ",") {
+			colors.add(strings.ToLower(color), score)
+		}
+	}
+	rows.Close()
+
+	// Learn from wallhaven's tags along with the user's own, counting a tag both
+	// carry once
+	rows, err = c.db.Query(`
+		SELECT wallpaper_id, LOWER(tag) FROM wallhaven_tags
+		UNION
+		SELECT wallpaper_id, LOWER(tag) FROM wallpaper_tags
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := tally{}
+	for rows.Next() {
+		var id, tag string
+		if rows.Scan(&id, &tag) != nil {
+			continue
+		}
+		if score, ok := scores[id]; ok {
+			tags.add(tag, score)
+		}
+	}
+
+	return &Preferences{
+		Tags:      tags.weights(),
+		Uploaders: uploaders.weights(),
+		Colors:    colors.weights(),
+	}, rows.Err()
+}
+
+// TopTags returns up to n liked tags, best first
+func (p *Preferences) TopTags(n int) []string {
+	return rankedTags(p.Tags, n, func(w float64) bool { return w > 0 }, -1)
+}
+
+// DislikedTags returns up to n disliked tags, worst first
+func (p *Preferences) DislikedTags(n int) []string {
+	return rankedTags(p.Tags, n, func(w float64) bool { return w < 0 }, 1)
+}
+
+// rankedTags returns up to n tags whose weight passes keep, ordered by weight in
+// direction (1 ascending, -1 descending) and then by name
+func rankedTags(weights map[string]float64, n int, keep func(float64) bool, direction int) []string {
+	var tags []string
+	for tag, weight := range weights {
+		if keep(weight) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.SortFunc(tags, func(a, b string) int {
+		return cmp.Or(direction*cmp.Compare(weights[a], weights[b]), strings.Compare(a, b))
+	})
+	return tags[:min(n, len(tags))]
+}
+
+// Score estimates how much a search result will be liked from whatever of its
+// uploader, tags and colours is known
+func (p *Preferences) Score(w *Wallpaper) float64 {
+	var score float64
+	if w.Uploader != nil {
+		score += p.Uploaders[w.Uploader.Username]
+	}
+	for _, tag := range w.Tags {
+		score += p.Tags[strings.ToLower(tag.Name)]
+	}
+	if len(w.Colors) > 0 {
+		var colors float64
+		for _, color := range w.Colors {
+			colors += p.Colors[strings.ToLower(color)]
+		}
+		score += colors / float64(len(w.Colors))
+	}
+	return score
+}
+
+// Rank returns up to n of results, the highest scoring first
+func (p *Preferences) Rank(results []Wallpaper, n int) []Wallpaper {
+	ranked := slices.Clone(results)
+	slices.SortStableFunc(ranked, func(a, b Wallpaper) int {
+		return cmp.Compare(p.Score(&b), p.Score(&a))
+	})
+	return ranked[:min(n, len(ranked))]
+}
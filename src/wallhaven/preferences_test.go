@@ -0,0 +1,84 @@
+package wallhaven
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWallpaperCache_LearnPreferences(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache, err := NewWallpaperCache(filepath.Join(tmpDir, ".cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	add := func(i int, uploader string, colors []string, tags ...string) string {
+		t.Helper()
+		file := filepath.Join(tmpDir, fmt.Sprintf("test%d.jpg", i))
+		if err := os.WriteFile(file, []byte(fmt.Sprintf("content %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wallpaper := &Wallpaper{
+			Path:     fmt.Sprintf("https://example.com/test%d.jpg", i),
+			Colors:   colors,
+			Uploader: &Uploader{Username: uploader},
+		}
+		for _, tag := range tags {
+			wallpaper.Tags = append(wallpaper.Tags, Tag{Name: tag})
+		}
+		if err := cache.AddWallpaper(wallpaper, file, "010", "110"); err != nil {
+			t.Fatal(err)
+		}
+		return GenerateID(wallpaper.Path)
+	}
+
+	loved := add(0, "alice", []string{"#000000"}, "space", "Stars")
+	liked := add(1, "alice", []string{"#000000"}, "space")
+	hated := add(2, "bob", []string{"#ff0000"}, "cars")
+	add(3, "bob", nil, "cars", "space")
+
+	for id, rating := range map[string]int{loved: 5, liked: 4, hated: 1} {
+		if err := cache.SetRating(id, rating); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.ToggleFavorite(loved); err != nil {
+		t.Fatal(err)
+	}
+
+	if tags := cache.GetByID(loved).Tags; len(tags) != 0 {
+		t.Errorf("Expected wallhaven's tags kept apart from the user's, got %v", tags)
+	}
+	if err := cache.AddTags(hated, []string{"Cars", "rust"}); err != nil {
+		t.Fatal(err)
+	}
+
+	prefs, err := cache.LearnPreferences()
+	if err != nil {
+		t.Fatalf("LearnPreferences() error = %v", err)
+	}
+
+	if got := prefs.TopTags(5); !slices.Equal(got, []string{"stars", "space"}) {
+		t.Errorf("TopTags() = %v, want [stars space]", got)
+	}
+	if got := prefs.DislikedTags(5); !slices.Equal(got, []string{"rust", "cars"}) {
+		t.Errorf("DislikedTags() = %v, want [rust cars]", got)
+	}
+	if prefs.Uploaders["alice"] <= 0 || prefs.Uploaders["bob"] >= 0 {
+		t.Errorf("Expected alice liked and bob disliked, got %v", prefs.Uploaders)
+	}
+
+	results := []Wallpaper{
+		{ID: "red", Colors: []string{"#FF0000"}},
+		{ID: "plain"},
+		{ID: "dark", Colors: []string{"#000000"}},
+	}
+	ranked := prefs.Rank(results, 2)
+	if len(ranked) != 2 || ranked[0].ID != "dark" || ranked[1].ID != "plain" {
+		t.Errorf("Rank() = %+v, want dark then plain", ranked)
+	}
+}
@@ -0,0 +1,149 @@
+// Package wallhaven provides terminal image previews using the kitty graphics and sixel protocols
+package wallhaven
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+)
+
+// KittyClearImages deletes every image placed with the kitty graphics protocol
+const KittyClearImages = "\x1b_Ga=d,q=2\x1b\\"
+
+// kittyChunkSize is the largest base64 payload the kitty protocol accepts per escape
+const kittyChunkSize = 4096
+
+// sixelLevels is the number of levels per channel in the sixel palette, giving a
+// 6x6x6 colour cube that fits comfortably within the 256 registers terminals offer
+const sixelLevels = 6
+
+// LoadPreview loads an image scaled to fit within width x height pixels, keeping
+// its aspect ratio. Images that already fit are returned unscaled.
+func LoadPreview(filePath string, width, height int) (image.Image, error) {
+	img, err := loadImage(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	if width <= 0 || height <= 0 || (bounds.Dx() <= width && bounds.Dy() <= height) {
+		return img, nil
+	}
+
+	scale := min(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	return resizeImage(img, max(1, int(float64(bounds.Dx())*scale)), max(1, int(float64(bounds.Dy())*scale))), nil
+}
+
+// EncodeKitty returns the kitty graphics protocol escapes that draw img at the
+// cursor, scaled by the terminal to fill columns x rows cells
+func EncodeKitty(img image.Image, columns, rows int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("failed to encode preview: %w", err)
+	}
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var sb strings.Builder
+	for i := 0; i < len(payload); i += kittyChunkSize {
+		chunk := payload[i:min(i+kittyChunkSize, len(payload))]
+		more := 0
+		if i+kittyChunkSize < len(payload) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(&sb, "\x1b_Ga=T,f=100,q=2,c=%d,r=%d,m=%d;%s\x1b\\", columns, rows, more, chunk)
+		} else {
+			fmt.Fprintf(&sb, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return sb.String(), nil
+}
+
+// EncodeSixel returns img as a sixel image drawn at the cursor. Colours are
+// quantised to a 6x6x6 cube; previews are small enough that this looks fine.
+func EncodeSixel(img image.Image) string {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Palette index of every pixel
+	indexes := make([]int, width*height)
+	var used [sixelLevels * sixelLevels * sixelLevels]bool
+	for y := range height {
+		for x := range width {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			index := sixelLevel(r)*sixelLevels*sixelLevels + sixelLevel(g)*sixelLevels + sixelLevel(b)
+			indexes[y*width+x] = index
+			used[index] = true
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\x1bPq\"1;1;%d;%d", width, height)
+	for index, ok := range used {
+		if !ok {
+			continue
+		}
+		// Colour registers take RGB percentages
+		r := index / (sixelLevels * sixelLevels)
+		g := index / sixelLevels % sixelLevels
+		b := index % sixelLevels
+		fmt.Fprintf(&sb, "#%d;2;%d;%d;%d", index, r*100/(sixelLevels-1), g*100/(sixelLevels-1), b*100/(sixelLevels-1))
+	}
+
+	// Each band is six pixel rows; every colour in it is drawn as its own pass
+	for top := 0; top < height; top += 6 {
+		bands := make(map[int][]byte)
+		var order []int
+		for dy := 0; dy < 6 && top+dy < height; dy++ {
+			for x := range width {
+				index := indexes[(top+dy)*width+x]
+				band, ok := bands[index]
+				if !ok {
+					band = make([]byte, width)
+					bands[index] = band
+					order = append(order, index)
+				}
+				band[x] |= 1 << dy
+			}
+		}
+
+		for i, index := range order {
+			if i > 0 {
+				sb.WriteByte('$')
+			}
+			fmt.Fprintf(&sb, "#%d", index)
+			writeSixelRun(&sb, bands[index])
+		}
+		sb.WriteByte('-')
+	}
+
+	sb.WriteString("\x1b\\")
+	return sb.String()
+}
+
+// sixelLevel maps a 16-bit colour channel to one of sixelLevels levels
+func sixelLevel(v uint32) int {
+	return int((v*(sixelLevels-1) + 0x7fff) / 0xffff)
+}
+
+// writeSixelRun writes one colour's pass over a band, run-length encoding repeats
+func writeSixelRun(sb *strings.Builder, band []byte) {
+	for x := 0; x < len(band); {
+		run := 1
+		for x+run < len(band) && band[x+run] == band[x] {
+			run++
+		}
+		char := byte('?' + band[x])
+		if run > 3 {
+			fmt.Fprintf(sb, "!%d%c", run, char)
+		} else {
+			for range run {
+				sb.WriteByte(char)
+			}
+		}
+		x += run
+	}
+}
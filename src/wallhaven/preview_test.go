@@ -0,0 +1,107 @@
+package wallhaven
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLoadPreview(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.png")
+	writeTestImage(t, testFile, 200, 100, testBands...)
+
+	img, err := LoadPreview(testFile, 50, 50)
+	if err != nil {
+		t.Fatalf("LoadPreview() error = %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(50, 25) {
+		t.Errorf("Expected the preview to keep its aspect ratio at 50x25, got %v", got)
+	}
+
+	// Small images aren't upscaled
+	img, err = LoadPreview(testFile, 400, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(200, 100) {
+		t.Errorf("Expected 200x100, got %v", got)
+	}
+}
+
+func TestEncodeKitty(t *testing.T) {
+	// Noise keeps the PNG large enough to need several chunks
+	img := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.UintN(256))
+	}
+
+	encoded, err := EncodeKitty(img, 30, 10)
+	if err != nil {
+		t.Fatalf("EncodeKitty() error = %v", err)
+	}
+	if !strings.HasPrefix(encoded, "\x1b_Ga=T,f=100,q=2,c=30,r=10,m=1;") {
+		t.Fatalf("Unexpected first chunk %q", encoded[:min(len(encoded), 40)])
+	}
+
+	chunks := regexp.MustCompile("\x1b_G[^;]*m=([01]);([^\x1b]*)\x1b\\\\").FindAllStringSubmatch(encoded, -1)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+
+	var payload strings.Builder
+	for i, chunk := range chunks {
+		if last := i == len(chunks)-1; (chunk[1] == "0") != last {
+			t.Errorf("Chunk %d has m=%s", i, chunk[1])
+		}
+		payload.WriteString(chunk[2])
+	}
+	data, err := base64.StdEncoding.DecodeString(payload.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected the payload to be a PNG: %v", err)
+	}
+	if decoded.Bounds() != img.Bounds() {
+		t.Errorf("Expected bounds %v, got %v", img.Bounds(), decoded.Bounds())
+	}
+}
+
+func TestEncodeSixel(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 7))
+	for y := range 7 {
+		for x := range 8 {
+			if x < 4 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+
+	encoded := EncodeSixel(img)
+	if !strings.HasPrefix(encoded, "\x1bPq\"1;1;8;7") || !strings.HasSuffix(encoded, "\x1b\\") {
+		t.Fatalf("Unexpected framing %q", encoded)
+	}
+
+	// Pure red and blue are corners of the colour cube
+	if !strings.Contains(encoded, "#180;2;100;0;0") || !strings.Contains(encoded, "#5;2;0;0;100") {
+		t.Errorf("Expected red and blue registers, got %q", encoded)
+	}
+	// Seven rows make two bands; the first is full height and run-length encoded
+	if strings.Count(encoded, "-") != 2 {
+		t.Errorf("Expected 2 bands, got %q", encoded)
+	}
+	if !strings.Contains(encoded, "#180!4~") {
+		t.Errorf("Expected a run of four full sixels, got %q", encoded)
+	}
+}
@@ -0,0 +1,203 @@
+// Package wallhaven provides cache quotas and eviction policies
+package wallhaven
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// Quota limits how much the cache holds and decides what is evicted first when
+// it holds too much. Zero limits are disabled. Favourites and the wallpaper on
+// screen are never evicted.
+type Quota struct {
+	MaxCount       int      // Most wallpapers to keep
+	MaxBytes       int64    // Most bytes of wallpaper files to keep
+	MaxDiskPercent int      // Most of the disk space available to the cache, as a percentage
+	DownloadDir    string   // Where wallpapers are saved, whose disk MaxDiskPercent is of; see SetQuotaDir
+	Policy         string   // One of constants.ValidEvictionPolicies
+	ProtectRating  int      // Never evict wallpapers rated at least this
+	ProtectTags    []string // Never evict wallpapers with any of these tags
+}
+
+// DefaultQuota returns the quota used until SetQuota is called
+func DefaultQuota() Quota {
+	return Quota{
+		MaxCount: constants.MaxCacheSize,
+		MaxBytes: int64(constants.MaxCacheSizeMB) * 1024 * 1024,
+		Policy:   constants.DefaultEvictionPolicy,
+	}
+}
+
+// EvictionPlan describes the cache against its quota and what enforcing it removes
+type EvictionPlan struct {
+	Count    int                  `json:"count"`
+	Bytes    int64                `json:"bytes"`
+	MaxCount int                  `json:"max_count"`
+	MaxBytes int64                `json:"max_bytes"`
+	Policy   string               `json:"policy"`
+	Evict    []*WallpaperMetadata `json:"evict"`
+}
+
+// evictionOrder is the ORDER BY clause for each policy, ties going to the least
+// recently used
+var evictionOrder = map[string]string{
+	constants.EvictionLRU:         "last_used ASC",
+	constants.EvictionLeastUsed:   "use_count ASC, last_used ASC",
+	constants.EvictionLowestRated: "rating ASC, use_count ASC, last_used ASC",
+	constants.EvictionOldest:      "downloaded_at ASC",
+}
+
+// SetQuota replaces the cache quota used when wallpapers are added
+func (c *WallpaperCache) SetQuota(quota Quota) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.quota = quota
+}
+
+// SetQuotaDir sets the directory wallpapers are saved to, whose disk the disk
+// percentage quota is measured on. A directory that doesn't exist yet is measured
+// on the disk it would be created on. It fails when that disk can't be measured
+// while a disk percentage quota is set, rather than quietly ignoring the quota.
+func (c *WallpaperCache) SetQuotaDir(dir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.quota.DownloadDir = dir
+	if c.quota.MaxDiskPercent == 0 {
+		return nil
+	}
+	_, err := c.diskFree()
+	return err
+}
+
+// diskFree returns the free space on the disk the quota is measured on; the
+// caller must hold the lock
+func (c *WallpaperCache) diskFree() (int64, error) {
+	dir := cmp.Or(c.quota.DownloadDir, c.dir)
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	free, err := diskFree(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure free disk space on %s for the disk percentage quota: %w", dir, err)
+	}
+	return free, nil
+}
+
+// PlanEviction returns what enforcing the quota would remove right now, without
+// removing anything
+func (c *WallpaperCache) PlanEviction() (*EvictionPlan, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.planEviction()
+}
+
+// EnforceCacheLimits evicts wallpapers by the quota's policy once the cache is
+// over quota, until it is back under EvictionTargetPercent of it. Every eviction
+// is logged, and evicted wallpapers go to the trash.
+func (c *WallpaperCache) EnforceCacheLimits() (*EvictionPlan, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	plan, err := c.planEviction()
+	if err != nil || len(plan.Evict) == 0 {
+		return plan, err
+	}
+
+	var evicted []*WallpaperMetadata
+	for _, wallpaper := range plan.Evict {
+		if err := c.trashWallpaper(wallpaper.ID, constants.TrashReasonEvicted); err != nil {
+			slog.Warn("Failed to evict wallpaper", "path", wallpaper.Path, "error", err)
+			continue
+		}
+		evicted = append(evicted, wallpaper)
+		slog.Info("Evicted wallpaper", "path", wallpaper.Path, "policy", plan.Policy, "size", wallpaper.Size)
+	}
+	plan.Evict = evicted
+	slog.Info("Enforced cache limits", "removed", len(evicted), "remaining", plan.Count-len(evicted))
+	return plan, nil
+}
+
+// planEviction works out what the quota evicts; the caller must hold the lock
+func (c *WallpaperCache) planEviction() (*EvictionPlan, error) {
+	quota := c.quota
+	plan := &EvictionPlan{MaxCount: quota.MaxCount, MaxBytes: quota.MaxBytes, Policy: quota.Policy}
+	if err := c.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM wallpapers`).Scan(&plan.Count, &plan.Bytes); err != nil {
+		return nil, fmt.Errorf("failed to measure cache: %w", err)
+	}
+
+	// The space the cache may use is what is free plus what it already occupies, on
+	// the disk the wallpapers are saved to rather than the one holding the database.
+	// Evicted files only move to the trash, so they still count as occupied until
+	// it is purged; otherwise every eviction would shrink the limit.
+	if quota.MaxDiskPercent > 0 {
+		free, err := c.diskFree()
+		if err != nil {
+			return nil, err
+		}
+		if limit := (free + plan.Bytes + c.trashBytes()) * int64(quota.MaxDiskPercent) / 100; plan.MaxBytes == 0 || limit < plan.MaxBytes {
+			plan.MaxBytes = limit
+		}
+	}
+
+	overCount := plan.MaxCount > 0 && plan.Count > plan.MaxCount
+	overBytes := plan.MaxBytes > 0 && plan.Bytes > plan.MaxBytes
+	if !overCount && !overBytes {
+		return plan, nil
+	}
+
+	order, ok := evictionOrder[quota.Policy]
+	if !ok {
+		return nil, fmt.Errorf("unknown eviction policy: %s", quota.Policy)
+	}
+
+	query := `
+		SELECT id, path, original_url, hash, size, downloaded_at, last_used, use_count,
+		       categories, purities, COALESCE(resolution, ''), is_favorite, rating
+		FROM wallpapers
+		WHERE is_favorite = 0
+		  AND id IS NOT (SELECT current_wallpaper_id FROM view_state WHERE id = 1)`
+	var args []any
+	if quota.ProtectRating > 0 {
+		query += ` AND rating < ?`
+		args = append(args, quota.ProtectRating)
+	}
+	if len(quota.ProtectTags) > 0 {
+		query += ` AND id NOT IN (SELECT wallpaper_id FROM wallpaper_tags WHERE tag IN (?` +
+			strings.Repeat(", ?", len(quota.ProtectTags)-1) + `))`
+		for _, tag := range quota.ProtectTags {
+			args = append(args, tag)
+		}
+	}
+	query += ` ORDER BY ` + order
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wallpapers for cleanup: %w", err)
+	}
+	defer rows.Close()
+
+	targetCount := plan.MaxCount * constants.EvictionTargetPercent / 100
+	targetBytes := plan.MaxBytes * constants.EvictionTargetPercent / 100
+	count, size := plan.Count, plan.Bytes
+	for _, wallpaper := range c.scanWallpapers(rows) {
+		if !(plan.MaxCount > 0 && count > targetCount) && !(plan.MaxBytes > 0 && size > targetBytes) {
+			break
+		}
+		plan.Evict = append(plan.Evict, wallpaper)
+		count--
+		size -= wallpaper.Size
+	}
+	return plan, nil
+}
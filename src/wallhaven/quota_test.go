@@ -0,0 +1,96 @@
+package wallhaven
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+func TestWallpaperCache_PlanEviction(t *testing.T) {
+	cache, ids := newPopulatedTestCache(t, 5)
+	for i, uses := range []int{5, 1, 3, 2, 4} {
+		if _, err := cache.db.Exec(`UPDATE wallpapers SET use_count = ? WHERE id = ?`, uses, ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache.SetQuota(Quota{Policy: constants.EvictionLeastUsed})
+	plan, err := cache.PlanEviction()
+	if err != nil {
+		t.Fatalf("PlanEviction() error = %v", err)
+	}
+	if plan.Count != 5 || len(plan.Evict) != 0 {
+		t.Errorf("Expected nothing to evict without limits, got %d of %d", len(plan.Evict), plan.Count)
+	}
+
+	// Over a quota of 3, eviction goes down to 90% of it, least used first
+	cache.SetQuota(Quota{MaxCount: 3, Policy: constants.EvictionLeastUsed})
+	plan, err = cache.PlanEviction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{ids[1], ids[3], ids[2]}
+	if len(plan.Evict) != len(want) {
+		t.Fatalf("Expected %d evictions, got %d", len(want), len(plan.Evict))
+	}
+	for i, id := range want {
+		if plan.Evict[i].ID != id {
+			t.Errorf("Eviction %d: expected %s, got %s", i, id, plan.Evict[i].ID)
+		}
+	}
+
+	// Favourites, high ratings, protected tags and the current wallpaper are kept
+	if err := cache.ToggleFavorite(ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetRating(ids[3], 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AddTags(ids[2], []string{"keep"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetCurrentView(ids[4]); err != nil {
+		t.Fatal(err)
+	}
+	cache.SetQuota(Quota{MaxCount: 3, Policy: constants.EvictionLeastUsed, ProtectRating: 4, ProtectTags: []string{"keep"}})
+
+	plan, err = cache.EnforceCacheLimits()
+	if err != nil {
+		t.Fatalf("EnforceCacheLimits() error = %v", err)
+	}
+	if len(plan.Evict) != 1 || plan.Evict[0].ID != ids[0] {
+		t.Fatalf("Expected only %s to be evicted, got %+v", ids[0], plan.Evict)
+	}
+	if cache.GetByID(ids[0]) != nil {
+		t.Error("Expected the evicted wallpaper to be removed from the cache")
+	}
+	if _, err := os.Stat(plan.Evict[0].Path); !os.IsNotExist(err) {
+		t.Error("Expected the evicted file to be moved to the trash")
+	}
+	if trash, _ := cache.ListTrash(); len(trash) != 1 || trash[0].Reason != constants.TrashReasonEvicted {
+		t.Errorf("Expected the evicted wallpaper in the trash, got %+v", trash)
+	}
+	if size := cache.trashBytes(); size != plan.Evict[0].Size {
+		t.Errorf("Expected the trash to hold %d bytes, got %d", plan.Evict[0].Size, size)
+	}
+}
+
+func TestWallpaperCache_SetQuotaDir(t *testing.T) {
+	cache, _ := newPopulatedTestCache(t, 1)
+	cache.SetQuota(Quota{MaxDiskPercent: 50, Policy: constants.EvictionLRU})
+
+	// A download directory that doesn't exist yet is measured where it would be made
+	dir := filepath.Join(t.TempDir(), "not", "yet")
+	if err := cache.SetQuotaDir(dir); err != nil {
+		t.Fatalf("SetQuotaDir() error = %v", err)
+	}
+	plan, err := cache.PlanEviction()
+	if err != nil {
+		t.Fatalf("PlanEviction() error = %v", err)
+	}
+	if plan.MaxBytes == 0 {
+		t.Error("Expected the disk percentage to limit the cache's bytes")
+	}
+}
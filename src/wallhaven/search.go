@@ -138,6 +138,24 @@ func SearchWallpapersWithContext(ctx context.Context, search *Search) (*SearchRe
 	return out, nil
 }
 
+// GetWallpaperWithContext fetches the full information wallhaven has about a
+// single wallpaper, including its uploader and tags
+func GetWallpaperWithContext(ctx context.Context, id WallpaperID) (*Wallpaper, error) {
+	slog.Debug("Making API request to wallhaven", "endpoint", "/w/")
+	resp, err := getWithValuesAndContext(ctx, "/w/"+string(id), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &struct {
+		Data Wallpaper `json:"data"`
+	}{}
+	if err := processResponse(resp, out); err != nil {
+		return nil, err
+	}
+	return &out.Data, nil
+}
+
 func processResponse(resp *http.Response, out interface{}) error {
 	defer resp.Body.Close()
 
@@ -160,9 +178,27 @@ type SearchResults struct {
 	Data []Wallpaper `json:"data"`
 }
 
-// Wallpaper information about a given wallpaper
+// Wallpaper information about a given wallpaper. Search listings leave out the
+// uploader and tags; GetWallpaperWithContext fills them in.
 type Wallpaper struct {
-	Path string `json:"path"`
+	ID       string    `json:"id"`
+	Path     string    `json:"path"`
+	Thumbs   Thumbs    `json:"thumbs"`
+	Colors   []string  `json:"colors"`
+	Uploader *Uploader `json:"uploader,omitempty"`
+	Tags     []Tag     `json:"tags,omitempty"`
+}
+
+// Uploader the user who uploaded a wallpaper
+type Uploader struct {
+	Username string `json:"username"`
+}
+
+// Thumbs the thumbnail URLs wallhaven provides for a wallpaper
+type Thumbs struct {
+	Large    string `json:"large"`
+	Original string `json:"original"`
+	Small    string `json:"small"`
 }
 
 // Tag full data on a given wallpaper tag
@@ -314,3 +350,21 @@ func (w *Wallpaper) DownloadWithContext(ctx context.Context, dir string) error {
 
 	return download(filePath, resp)
 }
+
+// DownloadThumbnailWithContext downloads the wallpaper's small thumbnail to filePath
+func (w *Wallpaper) DownloadThumbnailWithContext(ctx context.Context, filePath string) error {
+	if w.Thumbs.Small == "" {
+		return fmt.Errorf("wallpaper has no thumbnail")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), constants.DirPermissions); err != nil {
+		return fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+
+	resp, err := getAuthedResponseWithContext(ctx, w.Thumbs.Small)
+	if err != nil {
+		return fmt.Errorf("failed to get thumbnail: %w", err)
+	}
+
+	return download(filePath, resp)
+}
@@ -0,0 +1,176 @@
+// Package wallhaven provides persistent shuffle bags for rotating through a set
+// of wallpapers without repeats
+package wallhaven
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// bagItem is a wallpaper's place in a shuffle bag
+type bagItem struct {
+	key   float64
+	drawn sql.NullInt64 // Draw sequence number, null while still in the bag
+}
+
+// ShuffleBagKey names the bag for a source. Tag sets are normalised so the same
+// tags in any order or case share one bag.
+func ShuffleBagKey(source string, tags []string) string {
+	if len(tags) == 0 {
+		return source
+	}
+	normalized := make([]string, len(tags))
+	for i, tag := range tags {
+		normalized[i] = strings.ToLower(strings.TrimSpace(tag))
+	}
+	slices.Sort(normalized)
+	return source + ":" + strings.Join(slices.Compact(normalized), ",")
+}
+
+// shuffleKey draws a sort key for a wallpaper. Keys are exponentially distributed
+// with rate weight, so ordering by key gives a random order in which heavier
+// wallpapers tend to come first. Unweighted bags give every wallpaper weight 1.
+func shuffleKey(w *WallpaperMetadata, weighted bool) float64 {
+	weight := 1.0
+	if weighted {
+		weight = float64(cmp.Or(w.Rating, constants.NeutralRating))
+		if w.IsFavorite {
+			weight += constants.FavoriteWeight
+		}
+	}
+	return rand.ExpFloat64() / weight
+}
+
+// NextShuffled draws the next wallpaper from the named shuffle bag. The bag holds
+// candidates, which is the source's current membership: every candidate is drawn
+// once before any is drawn again. Wallpapers that joined the source since the bag
+// was filled are shuffled into what's left of it, and ones that left are skipped.
+// When the bag runs out it is refilled, never starting with the wallpaper drawn
+// last. With weighted set, higher rated and favourite wallpapers come up earlier
+// within each round.
+func (c *WallpaperCache) NextShuffled(bag string, candidates []*WallpaperMetadata, weighted bool) (*WallpaperMetadata, error) {
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no wallpapers to shuffle")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	items, lastDrawn, lastSeq, err := loadBag(tx, bag)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*WallpaperMetadata
+	for _, w := range candidates {
+		item, ok := items[w.ID]
+		if !ok {
+			item = &bagItem{key: shuffleKey(w, weighted)}
+			if _, err := tx.Exec(`INSERT INTO shuffle_bags (bag, wallpaper_id, sort_key) VALUES (?, ?, ?)`,
+				bag, w.ID, item.key); err != nil {
+				return nil, fmt.Errorf("failed to add wallpaper to shuffle bag: %w", err)
+			}
+			items[w.ID] = item
+		}
+		if !item.drawn.Valid {
+			pending = append(pending, w)
+		}
+	}
+
+	if len(pending) == 0 {
+		if pending, err = refillBag(tx, bag, candidates, items, lastDrawn, weighted); err != nil {
+			return nil, err
+		}
+	}
+
+	next := slices.MinFunc(pending, func(a, b *WallpaperMetadata) int {
+		return cmp.Compare(items[a.ID].key, items[b.ID].key)
+	})
+	if _, err := tx.Exec(`UPDATE shuffle_bags SET drawn = ? WHERE bag = ? AND wallpaper_id = ?`,
+		lastSeq+1, bag, next.ID); err != nil {
+		return nil, fmt.Errorf("failed to draw from shuffle bag: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return next, nil
+}
+
+// loadBag reads a bag's items along with the wallpaper drawn last and its
+// sequence number
+func loadBag(tx *sql.Tx, bag string) (map[string]*bagItem, string, int64, error) {
+	rows, err := tx.Query(`SELECT wallpaper_id, sort_key, drawn FROM shuffle_bags WHERE bag = ?`, bag)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to query shuffle bag: %w", err)
+	}
+	defer rows.Close()
+
+	items := make(map[string]*bagItem)
+	var lastDrawn string
+	var lastSeq int64
+	for rows.Next() {
+		var id string
+		item := &bagItem{}
+		if err := rows.Scan(&id, &item.key, &item.drawn); err != nil {
+			return nil, "", 0, fmt.Errorf("failed to scan shuffle bag: %w", err)
+		}
+		items[id] = item
+		if item.drawn.Valid && item.drawn.Int64 > lastSeq {
+			lastDrawn, lastSeq = id, item.drawn.Int64
+		}
+	}
+	return items, lastDrawn, lastSeq, rows.Err()
+}
+
+// refillBag starts a new round with every candidate back in the bag and returns
+// them. Wallpapers that left the source are dropped from the bag.
+func refillBag(tx *sql.Tx, bag string, candidates []*WallpaperMetadata, items map[string]*bagItem, lastDrawn string, weighted bool) ([]*WallpaperMetadata, error) {
+	if _, err := tx.Exec(`DELETE FROM shuffle_bags WHERE bag = ?`, bag); err != nil {
+		return nil, fmt.Errorf("failed to empty shuffle bag: %w", err)
+	}
+
+	for _, w := range candidates {
+		items[w.ID] = &bagItem{key: shuffleKey(w, weighted)}
+	}
+
+	// Don't let a new round open with the wallpaper that closed the last one
+	order := slices.SortedFunc(slices.Values(candidates), func(a, b *WallpaperMetadata) int {
+		return cmp.Compare(items[a.ID].key, items[b.ID].key)
+	})
+	if len(order) > 1 && order[0].ID == lastDrawn {
+		first, second := items[order[0].ID], items[order[1].ID]
+		first.key, second.key = second.key, first.key
+	}
+
+	for _, w := range candidates {
+		if _, err := tx.Exec(`INSERT INTO shuffle_bags (bag, wallpaper_id, sort_key) VALUES (?, ?, ?)`,
+			bag, w.ID, items[w.ID].key); err != nil {
+			return nil, fmt.Errorf("failed to refill shuffle bag: %w", err)
+		}
+	}
+	return slices.Clone(candidates), nil
+}
+
+// ResetShuffle empties the named shuffle bag so the next draw starts a new round
+func (c *WallpaperCache) ResetShuffle(bag string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.db.Exec(`DELETE FROM shuffle_bags WHERE bag = ?`, bag); err != nil {
+		return fmt.Errorf("failed to reset shuffle bag: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package wallhaven
+
+import "testing"
+
+func TestWallpaperCache_NextShuffled(t *testing.T) {
+	cache, _ := newPopulatedTestCache(t, 5)
+	wallpapers := cache.GetAll()
+
+	draw := func(candidates []*WallpaperMetadata) string {
+		t.Helper()
+		w, err := cache.NextShuffled("library", candidates, false)
+		if err != nil {
+			t.Fatalf("NextShuffled() error = %v", err)
+		}
+		return w.ID
+	}
+
+	// Every wallpaper comes up once per round, and a round never opens with the
+	// wallpaper that closed the previous one
+	var last string
+	for round := range 4 {
+		seen := make(map[string]bool)
+		for i := range len(wallpapers) {
+			id := draw(wallpapers)
+			if seen[id] {
+				t.Fatalf("Round %d repeated %s", round, id)
+			}
+			if i == 0 && id == last {
+				t.Fatalf("Round %d opened with the previous round's last wallpaper", round)
+			}
+			seen[id] = true
+			last = id
+		}
+	}
+
+	// Wallpapers joining the source are shuffled into the current round
+	if err := cache.ResetShuffle("library"); err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{draw(wallpapers[:3]): true}
+	for range 4 {
+		id := draw(wallpapers)
+		if seen[id] {
+			t.Fatalf("Repeated %s after the source grew", id)
+		}
+		seen[id] = true
+	}
+
+	if _, err := cache.NextShuffled("library", nil, false); err == nil {
+		t.Error("Expected an empty source to be rejected")
+	}
+	if got := ShuffleBagKey("tags", []string{"Space", "nature", "space"}); got != "tags:nature,space" {
+		t.Errorf("ShuffleBagKey() = %q", got)
+	}
+}
@@ -0,0 +1,228 @@
+// Package wallhaven provides collection statistics for the wallpaper cache
+package wallhaven
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Statistics summarises the collection and how it has been used. Since and Until
+// bound the activity figures (Downloads, Uses, UniqueUsed and MostUsed); the
+// collection figures always cover the whole cache.
+type Statistics struct {
+	TotalWallpapers   int       `json:"total_wallpapers"`
+	ValidWallpapers   int       `json:"valid_wallpapers"`
+	InvalidWallpapers int       `json:"invalid_wallpapers"`
+	FavoriteCount     int       `json:"favorite_count"`
+	TotalSize         int64     `json:"total_size_bytes"`
+	AverageRating     float64   `json:"average_rating"`
+	OldestDownload    time.Time `json:"oldest_download,omitzero"`
+	NewestDownload    time.Time `json:"newest_download,omitzero"`
+
+	CurrentWallpaper  string `json:"current_wallpaper,omitempty"`
+	PreviousWallpaper string `json:"previous_wallpaper,omitempty"`
+
+	UniqueLastWeek  int `json:"unique_wallpapers_last_week"`
+	UniqueLastMonth int `json:"unique_wallpapers_last_month"`
+	HistoryEntries  int `json:"total_history_entries"`
+
+	Since      time.Time        `json:"since,omitzero"`
+	Until      time.Time        `json:"until,omitzero"`
+	Downloads  int              `json:"downloads"`
+	Uses       int              `json:"uses"`
+	UniqueUsed int              `json:"unique_used"`
+	MostUsed   []WallpaperUsage `json:"most_used"`
+
+	TopTags     []TagCount        `json:"top_tags"`
+	Resolutions []ResolutionCount `json:"resolutions"`
+}
+
+// WallpaperUsage is a wallpaper and how often it was used
+type WallpaperUsage struct {
+	ID       string `json:"id"`
+	Path     string `json:"path"`
+	UseCount int    `json:"use_count"`
+}
+
+// TagCount is a tag and the number of wallpapers carrying it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// ResolutionCount is a resolution and the number of wallpapers with it
+type ResolutionCount struct {
+	Resolution string `json:"resolution"`
+	Count      int    `json:"count"`
+}
+
+// Number of entries returned in each ranking
+const (
+	statsMostUsedLimit    = 5
+	statsTopTagsLimit     = 10
+	statsResolutionsLimit = 10
+)
+
+// GetStatistics returns statistics about the cache. Zero since or until leave
+// that end of the activity window open.
+func (c *WallpaperCache) GetStatistics(since, until time.Time) (*Statistics, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := &Statistics{Since: since, Until: until}
+
+	err := c.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(size), 0),
+			COALESCE(SUM(is_favorite), 0),
+			(SELECT COALESCE(AVG(rating), 0) FROM wallpapers WHERE rating > 0)
+		FROM wallpapers
+	`).Scan(&stats.TotalWallpapers, &stats.TotalSize, &stats.FavoriteCount, &stats.AverageRating)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count wallpapers: %w", err)
+	}
+
+	// Count valid wallpapers (files that exist)
+	rows, err := c.db.Query(`SELECT path FROM wallpapers`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallpapers: %w", err)
+	}
+	for rows.Next() {
+		var path string
+		if rows.Scan(&path) == nil {
+			if _, err := os.Stat(path); err == nil {
+				stats.ValidWallpapers++
+			}
+		}
+	}
+	rows.Close()
+	stats.InvalidWallpapers = stats.TotalWallpapers - stats.ValidWallpapers
+
+	if stats.TotalWallpapers > 0 {
+		// Aggregates lose the DATETIME column type, so select the rows themselves
+		c.db.QueryRow(`SELECT downloaded_at FROM wallpapers ORDER BY downloaded_at ASC LIMIT 1`).Scan(&stats.OldestDownload)
+		c.db.QueryRow(`SELECT downloaded_at FROM wallpapers ORDER BY downloaded_at DESC LIMIT 1`).Scan(&stats.NewestDownload)
+	}
+
+	// Get current and previous wallpaper IDs
+	rows, err = c.db.Query(`
+		SELECT wallpaper_id
+		FROM usage_history
+		GROUP BY wallpaper_id
+		ORDER BY MAX(used_at) DESC
+		LIMIT 2
+	`)
+	if err == nil {
+		if rows.Next() {
+			rows.Scan(&stats.CurrentWallpaper)
+		}
+		if rows.Next() {
+			rows.Scan(&stats.PreviousWallpaper)
+		}
+		rows.Close()
+	}
+
+	// Get usage activity (last 7 days, 30 days)
+	weekAgo := time.Now().AddDate(0, 0, -7)
+	monthAgo := time.Now().AddDate(0, -1, 0)
+	c.db.QueryRow(`SELECT COUNT(DISTINCT wallpaper_id) FROM usage_history WHERE used_at > ?`, weekAgo).Scan(&stats.UniqueLastWeek)
+	c.db.QueryRow(`SELECT COUNT(DISTINCT wallpaper_id) FROM usage_history WHERE used_at > ?`, monthAgo).Scan(&stats.UniqueLastMonth)
+	c.db.QueryRow(`SELECT COUNT(*) FROM usage_history`).Scan(&stats.HistoryEntries)
+
+	if err := c.windowStatistics(stats); err != nil {
+		return nil, err
+	}
+
+	// Get top 10 most common tags
+	stats.TopTags = []TagCount{}
+	rows, err = c.db.Query(`
+		SELECT tag, COUNT(*) as count
+		FROM wallpaper_tags
+		GROUP BY tag
+		ORDER BY count DESC, tag
+		LIMIT ?
+	`, statsTopTagsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tags: %w", err)
+	}
+	for rows.Next() {
+		var tc TagCount
+		if rows.Scan(&tc.Tag, &tc.Count) == nil {
+			stats.TopTags = append(stats.TopTags, tc)
+		}
+	}
+	rows.Close()
+
+	// Get resolution distribution
+	stats.Resolutions = []ResolutionCount{}
+	rows, err = c.db.Query(`
+		SELECT COALESCE(NULLIF(resolution, ''), 'unknown'), COUNT(*) as count
+		FROM wallpapers
+		GROUP BY 1
+		ORDER BY count DESC, 1
+		LIMIT ?
+	`, statsResolutionsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count resolutions: %w", err)
+	}
+	for rows.Next() {
+		var rc ResolutionCount
+		if rows.Scan(&rc.Resolution, &rc.Count) == nil {
+			stats.Resolutions = append(stats.Resolutions, rc)
+		}
+	}
+	rows.Close()
+
+	return stats, nil
+}
+
+// windowStatistics fills in the activity figures bounded by stats.Since and
+// stats.Until. Without a window, most used ranks by the lifetime use count, which
+// also covers uses merged in from other libraries.
+func (c *WallpaperCache) windowStatistics(stats *Statistics) error {
+	since, until := stats.Since, stats.Until
+	if until.IsZero() {
+		until = time.Now().AddDate(100, 0, 0)
+	}
+
+	c.db.QueryRow(`SELECT COUNT(*) FROM wallpapers WHERE downloaded_at >= ? AND downloaded_at <= ?`, since, until).Scan(&stats.Downloads)
+	c.db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT wallpaper_id)
+		FROM usage_history
+		WHERE used_at >= ? AND used_at <= ?
+	`, since, until).Scan(&stats.Uses, &stats.UniqueUsed)
+
+	query := `
+		SELECT w.id, w.path, COUNT(*) as uses
+		FROM usage_history h JOIN wallpapers w ON w.id = h.wallpaper_id
+		WHERE h.used_at >= ? AND h.used_at <= ?
+		GROUP BY w.id
+		ORDER BY uses DESC, MAX(h.used_at) DESC
+		LIMIT ?
+	`
+	args := []any{since, until, statsMostUsedLimit}
+	if stats.Since.IsZero() && stats.Until.IsZero() {
+		query = `
+			SELECT id, path, use_count
+			FROM wallpapers
+			WHERE use_count > 0
+			ORDER BY use_count DESC, last_used DESC
+			LIMIT ?
+		`
+		args = []any{statsMostUsedLimit}
+	}
+
+	stats.MostUsed = []WallpaperUsage{}
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to rank most used wallpapers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var usage WallpaperUsage
+		if rows.Scan(&usage.ID, &usage.Path, &usage.UseCount) == nil {
+			stats.MostUsed = append(stats.MostUsed, usage)
+		}
+	}
+	return rows.Err()
+}
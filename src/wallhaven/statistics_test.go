@@ -0,0 +1,78 @@
+package wallhaven
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWallpaperCache_GetStatistics(t *testing.T) {
+	cache, id := newLibraryTestCache(t, t.TempDir())
+	if err := cache.AddTags(id, []string{"nature", "blue"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two more uses, ten days ago
+	old := time.Now().AddDate(0, 0, -10)
+	for range 2 {
+		if _, err := cache.db.Exec(`INSERT INTO usage_history (wallpaper_id, used_at) VALUES (?, ?)`, id, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := cache.GetStatistics(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetStatistics() error = %v", err)
+	}
+	if stats.TotalWallpapers != 1 || stats.ValidWallpapers != 1 || stats.HistoryEntries != 3 {
+		t.Errorf("Unexpected collection stats %+v", stats)
+	}
+	if stats.OldestDownload.IsZero() || stats.NewestDownload.IsZero() {
+		t.Error("Expected download timeline to be set")
+	}
+	if stats.CurrentWallpaper != id {
+		t.Errorf("Expected current wallpaper %s, got %s", id, stats.CurrentWallpaper)
+	}
+	if len(stats.TopTags) != 2 || len(stats.Resolutions) != 1 || stats.Resolutions[0].Resolution != "200x100" {
+		t.Errorf("Unexpected rankings: tags %+v, resolutions %+v", stats.TopTags, stats.Resolutions)
+	}
+	if len(stats.MostUsed) != 1 || stats.MostUsed[0].ID != id {
+		t.Errorf("Expected %s to be most used, got %+v", id, stats.MostUsed)
+	}
+
+	// The window only counts uses inside it
+	stats, err = cache.GetStatistics(time.Now().AddDate(0, 0, -1), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Uses != 1 || stats.UniqueUsed != 1 || stats.Downloads != 1 {
+		t.Errorf("Expected 1 use and 1 download in the last day, got %+v", stats)
+	}
+	if stats.MostUsed[0].UseCount != 1 {
+		t.Errorf("Expected 1 use in the window, got %d", stats.MostUsed[0].UseCount)
+	}
+
+	stats, err = cache.GetStatistics(time.Now().AddDate(0, 0, -30), time.Now().AddDate(0, 0, -5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Uses != 2 || stats.Downloads != 0 || stats.MostUsed[0].UseCount != 2 {
+		t.Errorf("Expected 2 uses and no downloads between 30 and 5 days ago, got %+v", stats)
+	}
+
+	// The window is recorded in the JSON and rankings are always lists
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decoded["since"]; !ok {
+		t.Error("Expected since in the JSON output")
+	}
+	if _, ok := decoded["most_used"].([]any); !ok {
+		t.Errorf("Expected most_used to be a list, got %v", decoded["most_used"])
+	}
+}
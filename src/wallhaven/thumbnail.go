@@ -0,0 +1,114 @@
+// Package wallhaven provides thumbnail and contact sheet generation
+package wallhaven
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// Contact sheet layout
+const (
+	sheetPadding       = 8
+	sheetCaptionHeight = 18
+)
+
+var (
+	sheetBackground = color.RGBA{R: 0x1e, G: 0x1e, B: 0x1e, A: 0xff}
+	sheetCaption    = color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+)
+
+// ThumbnailPath returns where the thumbnail for a wallpaper is stored
+func (c *WallpaperCache) ThumbnailPath(id string) string {
+	return filepath.Join(c.dir, constants.ThumbnailDir, id+".jpg")
+}
+
+// GetThumbnail returns the thumbnail for a wallpaper, generating it from the
+// full-size image if it wasn't downloaded from wallhaven
+func (c *WallpaperCache) GetThumbnail(metadata *WallpaperMetadata) (string, error) {
+	thumbPath := c.ThumbnailPath(metadata.ID)
+	if _, err := os.Stat(thumbPath); err == nil {
+		return thumbPath, nil
+	}
+
+	if err := CreateThumbnail(metadata.Path, thumbPath); err != nil {
+		return "", fmt.Errorf("failed to create thumbnail: %w", err)
+	}
+	return thumbPath, nil
+}
+
+// removeThumbnail deletes the cached thumbnail for a wallpaper if there is one
+func (c *WallpaperCache) removeThumbnail(id string) {
+	if err := os.Remove(c.ThumbnailPath(id)); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to remove thumbnail", "id", id, "error", err)
+	}
+}
+
+// CreateThumbnail writes a centre-cropped thumbnail of the image at srcPath to dstPath
+func CreateThumbnail(srcPath, dstPath string) error {
+	img, err := loadImage(srcPath)
+	if err != nil {
+		return err
+	}
+	return saveImage(fillImage(img, constants.ThumbnailWidth, constants.ThumbnailHeight), dstPath)
+}
+
+// ContactSheetItem is a single captioned cell of a contact sheet
+type ContactSheetItem struct {
+	Thumbnail string
+	Caption   string
+}
+
+// CreateContactSheet renders items as a grid with the given number of columns and
+// writes it to outPath as a PNG. Items whose thumbnail can't be read are left blank.
+func CreateContactSheet(items []ContactSheetItem, columns int, outPath string) error {
+	if len(items) == 0 {
+		return fmt.Errorf("no wallpapers to render")
+	}
+	columns = max(1, min(columns, len(items)))
+	rows := (len(items) + columns - 1) / columns
+
+	cellWidth := constants.ThumbnailWidth + sheetPadding
+	cellHeight := constants.ThumbnailHeight + sheetCaptionHeight + sheetPadding
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*cellWidth+sheetPadding, rows*cellHeight+sheetPadding))
+	draw.Draw(sheet, sheet.Rect, image.NewUniform(sheetBackground), image.Point{}, draw.Src)
+
+	face := basicfont.Face7x13
+	maxChars := constants.ThumbnailWidth / face.Advance
+
+	for i, item := range items {
+		x := sheetPadding + (i%columns)*cellWidth
+		y := sheetPadding + (i/columns)*cellHeight
+
+		if thumb, err := loadImage(item.Thumbnail); err != nil {
+			slog.Warn("Failed to load thumbnail", "path", item.Thumbnail, "error", err)
+		} else {
+			cell := image.Rect(x, y, x+constants.ThumbnailWidth, y+constants.ThumbnailHeight)
+			draw.Draw(sheet, cell, fillImage(thumb, constants.ThumbnailWidth, constants.ThumbnailHeight), image.Point{}, draw.Src)
+		}
+
+		caption := item.Caption
+		if len(caption) > maxChars {
+			caption = caption[:maxChars]
+		}
+		drawer := &font.Drawer{
+			Dst:  sheet,
+			Src:  image.NewUniform(sheetCaption),
+			Face: face,
+			Dot:  fixed.P(x, y+constants.ThumbnailHeight+face.Ascent+2),
+		}
+		drawer.DrawString(caption)
+	}
+
+	return saveImage(sheet, outPath)
+}
@@ -0,0 +1,106 @@
+package wallhaven
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+func imageSize(t *testing.T, path string) image.Point {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return image.Pt(cfg.Width, cfg.Height)
+}
+
+func TestWallpaperCache_GetThumbnail(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, ".cache")
+
+	testFile := filepath.Join(tmpDir, "wallhaven-abc123.png")
+	writeTestImage(t, testFile, 800, 400, testBands...)
+
+	cache, err := NewWallpaperCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	wallpaper := &Wallpaper{Path: "https://example.com/wallhaven-abc123.png"}
+	if err := cache.AddWallpaper(wallpaper, testFile, "010", "110"); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := cache.GetByID(GenerateID(wallpaper.Path))
+	thumb, err := cache.GetThumbnail(metadata)
+	if err != nil {
+		t.Fatalf("GetThumbnail() error = %v", err)
+	}
+
+	if thumb != cache.ThumbnailPath(metadata.ID) {
+		t.Errorf("Expected thumbnail at %s, got %s", cache.ThumbnailPath(metadata.ID), thumb)
+	}
+	if size := imageSize(t, thumb); size != image.Pt(constants.ThumbnailWidth, constants.ThumbnailHeight) {
+		t.Errorf("Expected %dx%d thumbnail, got %v", constants.ThumbnailWidth, constants.ThumbnailHeight, size)
+	}
+
+	// Removing the wallpaper removes its thumbnail
+	if err := cache.RemoveWallpaper(metadata.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(thumb); !os.IsNotExist(err) {
+		t.Error("Expected thumbnail to be removed with the wallpaper")
+	}
+}
+
+func TestCreateContactSheet(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var items []ContactSheetItem
+	for i := range 3 {
+		thumb := filepath.Join(tmpDir, string(rune('a'+i))+".png")
+		writeTestImage(t, thumb, constants.ThumbnailWidth, constants.ThumbnailHeight, testBands[i:]...)
+		items = append(items, ContactSheetItem{Thumbnail: thumb, Caption: "abc123 ***"})
+	}
+
+	out := filepath.Join(tmpDir, "sheet.png")
+	if err := CreateContactSheet(items, 2, out); err != nil {
+		t.Fatalf("CreateContactSheet() error = %v", err)
+	}
+
+	// Two columns and two rows of padded, captioned cells
+	want := image.Pt(
+		2*(constants.ThumbnailWidth+sheetPadding)+sheetPadding,
+		2*(constants.ThumbnailHeight+sheetCaptionHeight+sheetPadding)+sheetPadding,
+	)
+	if size := imageSize(t, out); size != want {
+		t.Errorf("Expected contact sheet of %v, got %v", want, size)
+	}
+}
+
+func TestWallhavenID(t *testing.T) {
+	tests := map[string]string{
+		"/pics/wallhaven-abc123.jpg": "abc123",
+		"wallhaven-9d8e7f.png":       "9d8e7f",
+		"/pics/holiday.jpg":          "",
+		"/pics/wallhaven-.jpg":       "",
+	}
+
+	for path, want := range tests {
+		if got := WallhavenID(path); got != want {
+			t.Errorf("WallhavenID(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+// Package wallhaven provides the trash removed wallpapers are moved to
+package wallhaven
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// TrashEntry is a wallpaper in the trash. Path is where it lived before it was
+// trashed and TrashPath where its file is now, which is empty when the file was
+// already gone.
+type TrashEntry struct {
+	WallpaperMetadata
+	TrashPath string    `json:"trash_path"`
+	Reason    string    `json:"reason"`
+	TrashedAt time.Time `json:"trashed_at"`
+}
+
+// trashColumns holds what the trash keeps of a wallpaper beside its library
+// entry, which has no place for them
+type trashColumns struct {
+	phash    sql.NullInt64
+	uploader sql.NullString
+	colors   sql.NullString
+}
+
+// TrashWallpaper moves a wallpaper's file into the trash directory and its
+// metadata, tags and usage history into the trash table, from where
+// RestoreFromTrash brings it back. reason records why it was removed.
+func (c *WallpaperCache) TrashWallpaper(id, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.trashWallpaper(id, reason)
+}
+
+// trashWallpaper trashes a wallpaper; the caller must hold the lock
+func (c *WallpaperCache) trashWallpaper(id, reason string) error {
+	return c.trashWallpaperWith(id, reason, nil)
+}
+
+// trashWallpaperWith trashes a wallpaper, running before in the same transaction
+// that drops it from the cache so both happen or neither does. before may be nil.
+// The caller must hold the lock.
+func (c *WallpaperCache) trashWallpaperWith(id, reason string, before func(*sql.Tx) error) error {
+	entry, columns, err := c.libraryEntry(id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode wallpaper metadata: %w", err)
+	}
+
+	// A wallpaper trashed before replaces its older trash entry
+	var oldPath string
+	if c.db.QueryRow(`SELECT trash_path FROM trash WHERE wallpaper_id = ?`, id).Scan(&oldPath) == nil && oldPath != "" {
+		os.Remove(oldPath)
+	}
+
+	trashPath := filepath.Join(c.dir, constants.TrashDir, id+filepath.Ext(entry.Path))
+	if err := moveFile(entry.Path, trashPath); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to move wallpaper to trash: %w", err)
+		}
+		// Keep the metadata so it can be restored if the file turns up again
+		trashPath = ""
+	}
+
+	if err := c.commitTrash(id, entry.Path, trashPath, string(data), columns, reason, before); err != nil {
+		if trashPath != "" {
+			if err := moveFile(trashPath, entry.Path); err != nil {
+				slog.Warn("Failed to move wallpaper back out of trash", "path", entry.Path, "error", err)
+			}
+		}
+		return err
+	}
+
+	c.removeDerived(id)
+	slog.Info("Moved wallpaper to trash", "path", entry.Path, "reason", reason)
+	return nil
+}
+
+// TrashFile moves an image the cache doesn't know, such as an orphaned download,
+// into the trash. Restoring it adds it to the cache like an import would.
+func (c *WallpaperCache) TrashFile(filePath, reason string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	hash, size, err := CalculateFileHash(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate hash: %w", err)
+	}
+	resolution, _ := GetImageResolution(filePath)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A stray copy of a cached wallhaven wallpaper would share its ID, so fall back
+	// to one derived from the path
+	sourceURL := SourceURL(filePath)
+	if c.getEntry("id", GenerateID(sourceURL)) != nil {
+		abs, _ := filepath.Abs(filePath)
+		sourceURL = "file://" + abs
+	}
+
+	entry := LibraryEntry{WallpaperMetadata: WallpaperMetadata{
+		ID:           GenerateID(sourceURL),
+		Path:         filePath,
+		OriginalURL:  sourceURL,
+		Hash:         hash,
+		Size:         size,
+		DownloadedAt: info.ModTime(),
+		LastUsed:     info.ModTime(),
+		Resolution:   resolution,
+	}}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode wallpaper metadata: %w", err)
+	}
+
+	trashPath := filepath.Join(c.dir, constants.TrashDir, entry.ID+filepath.Ext(filePath))
+	if err := moveFile(filePath, trashPath); err != nil {
+		return fmt.Errorf("failed to move file to trash: %w", err)
+	}
+	if err := c.commitTrash(entry.ID, filePath, trashPath, string(data), trashColumns{}, reason, nil); err != nil {
+		if err := moveFile(trashPath, filePath); err != nil {
+			slog.Warn("Failed to move file back out of trash", "path", filePath, "error", err)
+		}
+		return err
+	}
+
+	slog.Info("Moved file to trash", "path", filePath, "reason", reason)
+	return nil
+}
+
+// commitTrash records a trashed wallpaper and drops it from the cache, after
+// running before, if given, in the same transaction
+func (c *WallpaperCache) commitTrash(id, originalPath, trashPath, entry string, columns trashColumns, reason string, before func(*sql.Tx) error) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if before != nil {
+		if err := before(tx); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO trash (wallpaper_id, original_path, trash_path, entry, phash, uploader, colors, reason, trashed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, id, originalPath, trashPath, entry, columns.phash, columns.uploader, columns.colors, reason, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record trashed wallpaper: %w", err)
+	}
+
+	// Foreign keys aren't enforced, so tags and history are removed explicitly
+	for _, query := range []string{
+		`DELETE FROM wallpaper_tags WHERE wallpaper_id = ?`,
+		`DELETE FROM usage_history WHERE wallpaper_id = ?`,
+		`DELETE FROM wallpapers WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return fmt.Errorf("failed to delete wallpaper from database: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// libraryEntry returns a wallpaper with its tags and usage history, and the
+// columns the trash keeps beside them, whether or not its file exists; the caller
+// must hold the lock
+func (c *WallpaperCache) libraryEntry(id string) (LibraryEntry, trashColumns, error) {
+	var entry LibraryEntry
+	var columns trashColumns
+	err := c.db.QueryRow(`
+		SELECT id, path, original_url, hash, size, downloaded_at, last_used, use_count,
+		       categories, purities, COALESCE(resolution, ''), is_favorite, rating, phash, uploader, colors
+		FROM wallpapers
+		WHERE id = ?
+	`, id).Scan(&entry.ID, &entry.Path, &entry.OriginalURL, &entry.Hash,
+		&entry.Size, &entry.DownloadedAt, &entry.LastUsed, &entry.UseCount,
+		&entry.Categories, &entry.Purities, &entry.Resolution, &entry.IsFavorite, &entry.Rating,
+		&columns.phash, &columns.uploader, &columns.colors)
+	if err == sql.ErrNoRows {
+		return entry, columns, fmt.Errorf("wallpaper not found in cache: %s", id)
+	}
+	if err != nil {
+		return entry, columns, fmt.Errorf("failed to query wallpaper: %w", err)
+	}
+	entry.Tags = c.getTags(id)
+
+	rows, err := c.db.Query(`SELECT used_at FROM usage_history WHERE wallpaper_id = ? ORDER BY used_at ASC`, id)
+	if err != nil {
+		return entry, columns, fmt.Errorf("failed to query usage history: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var usedAt time.Time
+		if rows.Scan(&usedAt) == nil {
+			entry.UsageHistory = append(entry.UsageHistory, usedAt)
+		}
+	}
+	return entry, columns, rows.Err()
+}
+
+// ListTrash returns the wallpapers in the trash, most recently trashed first
+func (c *WallpaperCache) ListTrash() ([]*TrashEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rows, err := c.db.Query(`
+		SELECT wallpaper_id, trash_path, entry, reason, trashed_at
+		FROM trash
+		ORDER BY trashed_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*TrashEntry
+	for rows.Next() {
+		var id, data string
+		entry := &TrashEntry{}
+		if err := rows.Scan(&id, &entry.TrashPath, &data, &entry.Reason, &entry.TrashedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan trash entry: %w", err)
+		}
+		var library LibraryEntry
+		if err := json.Unmarshal([]byte(data), &library); err != nil {
+			slog.Warn("Skipping unreadable trash entry", "id", id, "error", err)
+			continue
+		}
+		entry.WallpaperMetadata = library.WallpaperMetadata
+		entry.ID = id
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// RestoreFromTrash moves a trashed wallpaper's file back to where it was and
+// returns it to the cache with its rating, favourite status, tags and usage
+// history. It refuses to overwrite a file that has since appeared at that path.
+func (c *WallpaperCache) RestoreFromTrash(id string) (*WallpaperMetadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var trashPath, data string
+	var columns trashColumns
+	err := c.db.QueryRow(`SELECT trash_path, entry, phash, uploader, colors FROM trash WHERE wallpaper_id = ?`, id).
+		Scan(&trashPath, &data, &columns.phash, &columns.uploader, &columns.colors)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("wallpaper not in trash: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+
+	var entry LibraryEntry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode trashed wallpaper: %w", err)
+	}
+
+	var cached int
+	c.db.QueryRow(`SELECT COUNT(*) FROM wallpapers WHERE id = ?`, id).Scan(&cached)
+	if cached > 0 {
+		return nil, fmt.Errorf("wallpaper is already in the cache: %s", id)
+	}
+
+	_, statErr := os.Stat(entry.Path)
+	if trashPath != "" {
+		if statErr == nil {
+			return nil, fmt.Errorf("a file already exists at %s", entry.Path)
+		}
+		if err := moveFile(trashPath, entry.Path); err != nil {
+			return nil, fmt.Errorf("failed to move wallpaper out of trash: %w", err)
+		}
+	} else if statErr != nil {
+		return nil, fmt.Errorf("wallpaper file was already gone when it was trashed and is not back at %s", entry.Path)
+	}
+
+	if err := c.commitRestore(entry, columns); err != nil {
+		if trashPath != "" {
+			if err := moveFile(entry.Path, trashPath); err != nil {
+				slog.Warn("Failed to move wallpaper back into trash", "path", trashPath, "error", err)
+			}
+		}
+		return nil, err
+	}
+
+	slog.Info("Restored wallpaper from trash", "path", entry.Path)
+	return &entry.WallpaperMetadata, nil
+}
+
+// commitRestore puts a trashed wallpaper back into the cache and drops its trash
+// entry
+func (c *WallpaperCache) commitRestore(entry LibraryEntry, columns trashColumns) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO wallpapers (id, path, original_url, hash, size, downloaded_at, last_used, use_count,
+		                        categories, purities, resolution, is_favorite, rating, phash, uploader, colors)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.ID, entry.Path, entry.OriginalURL, entry.Hash, entry.Size, entry.DownloadedAt, entry.LastUsed, entry.UseCount,
+		entry.Categories, entry.Purities, entry.Resolution, entry.IsFavorite, entry.Rating,
+		columns.phash, columns.uploader, columns.colors)
+	if err != nil {
+		return fmt.Errorf("failed to restore wallpaper: %w", err)
+	}
+
+	for _, tag := range entry.Tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO wallpaper_tags (wallpaper_id, tag) VALUES (?, ?)`, entry.ID, tag); err != nil {
+			return fmt.Errorf("failed to restore tags: %w", err)
+		}
+	}
+	for _, usedAt := range entry.UsageHistory {
+		if _, err := tx.Exec(`INSERT INTO usage_history (wallpaper_id, used_at) VALUES (?, ?)`, entry.ID, usedAt); err != nil {
+			return fmt.Errorf("failed to restore usage history: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM trash WHERE wallpaper_id = ?`, entry.ID); err != nil {
+		return fmt.Errorf("failed to remove trash entry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// trashBytes returns the size of the files in the trash; the caller must hold
+// the lock
+func (c *WallpaperCache) trashBytes() int64 {
+	rows, err := c.db.Query(`SELECT trash_path FROM trash WHERE trash_path != ''`)
+	if err != nil {
+		slog.Warn("Failed to query trash", "error", err)
+		return 0
+	}
+	defer rows.Close()
+
+	var total int64
+	for rows.Next() {
+		var path string
+		if rows.Scan(&path) != nil {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// PurgeTrash permanently deletes wallpapers trashed more than olderThan ago and
+// returns how many were deleted
+func (c *WallpaperCache) PurgeTrash(olderThan time.Duration) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.purgeTrash(`WHERE trashed_at < ?`, time.Now().Add(-olderThan))
+}
+
+// EmptyTrash permanently deletes every wallpaper in the trash and returns how
+// many were deleted
+func (c *WallpaperCache) EmptyTrash() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.purgeTrash(``)
+}
+
+// purgeTrash deletes the trash entries matching where, and their files; the
+// caller must hold the lock
+func (c *WallpaperCache) purgeTrash(where string, args ...any) (int, error) {
+	rows, err := c.db.Query(`SELECT wallpaper_id, trash_path FROM trash `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trash: %w", err)
+	}
+	type trashed struct{ id, path string }
+	var purge []trashed
+	for rows.Next() {
+		var t trashed
+		if rows.Scan(&t.id, &t.path) == nil {
+			purge = append(purge, t)
+		}
+	}
+	rows.Close()
+
+	purged := 0
+	for _, t := range purge {
+		if t.path != "" {
+			if err := os.Remove(t.path); err != nil && !os.IsNotExist(err) {
+				slog.Warn("Failed to delete trashed wallpaper", "path", t.path, "error", err)
+				continue
+			}
+		}
+		if _, err := c.db.Exec(`DELETE FROM trash WHERE wallpaper_id = ?`, t.id); err != nil {
+			return purged, fmt.Errorf("failed to delete trash entry: %w", err)
+		}
+		// Wallhaven's tags are left in place while trashed so a restore keeps them
+		if _, err := c.db.Exec(`DELETE FROM wallhaven_tags WHERE wallpaper_id = ? AND wallpaper_id NOT IN (SELECT id FROM wallpapers)`, t.id); err != nil {
+			return purged, fmt.Errorf("failed to delete wallhaven tags: %w", err)
+		}
+		purged++
+	}
+
+	if purged > 0 {
+		slog.Info("Purged trash", "count", purged)
+	}
+	return purged, nil
+}
+
+// moveFile moves src to dst, creating dst's directory and falling back to a copy
+// when they are on different filesystems
+func moveFile(src, dst string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), constants.DirPermissions); err != nil {
+		return err
+	}
+
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
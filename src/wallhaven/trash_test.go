@@ -0,0 +1,138 @@
+package wallhaven
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+func TestWallpaperCache_TrashAndRestore(t *testing.T) {
+	cache, ids := newPopulatedTestCache(t, 2)
+	id := ids[0]
+	if err := cache.SetRating(id, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AddTags(id, []string{"space"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.MarkAsUsed(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.db.Exec(`UPDATE wallpapers SET uploader = 'someone', colors = '#000000,#ffffff' WHERE id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+	path := cache.GetByID(id).Path
+
+	if err := cache.TrashWallpaper(id, constants.TrashReasonCleanup); err != nil {
+		t.Fatalf("TrashWallpaper() error = %v", err)
+	}
+	if cache.GetByID(id) != nil {
+		t.Error("Expected the trashed wallpaper to leave the cache")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the file to be moved out of place")
+	}
+
+	entries, err := cache.ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != id || entries[0].Reason != constants.TrashReasonCleanup || entries[0].Path != path {
+		t.Fatalf("Unexpected trash contents: %+v", entries)
+	}
+
+	// A file that reappeared at the original path is never overwritten
+	if err := os.WriteFile(path, []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.RestoreFromTrash(id); err == nil {
+		t.Error("Expected restoring over an existing file to fail")
+	}
+	os.Remove(path)
+
+	if _, err := cache.RestoreFromTrash(id); err != nil {
+		t.Fatalf("RestoreFromTrash() error = %v", err)
+	}
+	restored := cache.GetByID(id)
+	if restored == nil || restored.Rating != 5 || len(restored.Tags) != 1 || restored.UseCount != 2 {
+		t.Fatalf("Expected rating, tags and uses to be restored, got %+v", restored)
+	}
+	var uploader, colors string
+	if err := cache.db.QueryRow(`SELECT uploader, colors FROM wallpapers WHERE id = ?`, id).Scan(&uploader, &colors); err != nil {
+		t.Fatal(err)
+	}
+	if uploader != "someone" || colors != "#000000,#ffffff" {
+		t.Errorf("Expected the uploader and colours preferences learn from to be restored, got %q and %q", uploader, colors)
+	}
+	if history, _ := cache.GetUsageHistory(id, 0); len(history) != 2 {
+		t.Errorf("Expected 2 restored uses, got %d", len(history))
+	}
+	if entries, _ := cache.ListTrash(); len(entries) != 0 {
+		t.Errorf("Expected the trash to be empty after restoring, got %d", len(entries))
+	}
+}
+
+func TestWallpaperCache_PurgeTrash(t *testing.T) {
+	cache, ids := newPopulatedTestCache(t, 3)
+	for _, id := range ids {
+		if err := cache.RemoveWallpaper(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := cache.db.Exec(`UPDATE trash SET trashed_at = datetime('now', '-60 days') WHERE wallpaper_id = ?`, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := cache.ListTrash()
+	var oldPath string
+	for _, entry := range entries {
+		if entry.ID == ids[0] {
+			oldPath = entry.TrashPath
+		}
+	}
+
+	purged, err := cache.PurgeTrash(30 * 24 * time.Hour)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeTrash() = %d, %v; want 1", purged, err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Error("Expected the purged file to be deleted")
+	}
+	if _, err := cache.RestoreFromTrash(ids[0]); err == nil {
+		t.Error("Expected a purged wallpaper to be unrestorable")
+	}
+
+	emptied, err := cache.EmptyTrash()
+	if err != nil || emptied != 2 {
+		t.Errorf("EmptyTrash() = %d, %v; want 2", emptied, err)
+	}
+}
+
+func TestWallpaperCache_TrashFile(t *testing.T) {
+	cache, _ := newPopulatedTestCache(t, 1)
+	orphan := filepath.Join(t.TempDir(), "orphan.jpg")
+	if err := os.WriteFile(orphan, []byte("orphan"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.TrashFile(orphan, constants.TrashReasonCleanup); err != nil {
+		t.Fatalf("TrashFile() error = %v", err)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("Expected the orphaned file to be moved to the trash")
+	}
+
+	entries, err := cache.ListTrash()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected one trash entry, got %d, %v", len(entries), err)
+	}
+	restored, err := cache.RestoreFromTrash(entries[0].ID)
+	if err != nil {
+		t.Fatalf("RestoreFromTrash() error = %v", err)
+	}
+	if cache.GetByPath(orphan) == nil || restored.UseCount != 0 {
+		t.Errorf("Expected the restored file to be cached as unused, got %+v", restored)
+	}
+}
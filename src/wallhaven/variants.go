@@ -0,0 +1,115 @@
+// Package wallhaven provides display-sized variants of cached wallpapers
+package wallhaven
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+// GetDisplayVariant returns a copy of the wallpaper scaled and cropped to
+// width x height, creating and recording it the first time it's requested.
+// Images that already match the target resolution are returned unchanged, and
+// smaller images are only cropped to the target aspect ratio, never upscaled.
+func (c *WallpaperCache) GetDisplayVariant(metadata *WallpaperMetadata, width, height int, mode string) (string, error) {
+	resolution := fmt.Sprintf("%dx%d", width, height)
+	if metadata.Resolution == resolution {
+		return metadata.Path, nil
+	}
+
+	c.mu.RLock()
+	var existing string
+	err := c.db.QueryRow(`
+		SELECT path FROM wallpaper_variants
+		WHERE wallpaper_id = ? AND resolution = ? AND mode = ?
+	`, metadata.ID, resolution, mode).Scan(&existing)
+	c.mu.RUnlock()
+	if err == nil {
+		if _, err := os.Stat(existing); err == nil {
+			return existing, nil
+		}
+	}
+
+	img, err := loadImage(metadata.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var crop = centerCrop(img.Bounds(), width, height)
+	if mode == constants.CropModeFocus {
+		crop = focusCrop(img, width, height)
+	}
+	outWidth, outHeight := min(width, crop.Dx()), min(height, crop.Dy())
+	variant := resizeImage(subImage(img, crop), outWidth, outHeight)
+
+	variantPath := variantPath(metadata.Path, resolution, mode)
+	if err := saveImage(variant, variantPath); err != nil {
+		return "", fmt.Errorf("failed to save display variant: %w", err)
+	}
+
+	info, err := os.Stat(variantPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat display variant: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err = c.db.Exec(`
+		INSERT INTO wallpaper_variants (wallpaper_id, resolution, mode, path, size, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(wallpaper_id, resolution, mode) DO UPDATE SET
+			path = excluded.path,
+			size = excluded.size,
+			created_at = excluded.created_at
+	`, metadata.ID, resolution, mode, variantPath, info.Size(), time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to record display variant: %w", err)
+	}
+
+	slog.Debug("Created display variant", "source", metadata.Path, "variant", variantPath)
+	return variantPath, nil
+}
+
+// variantPath returns where a display variant of the wallpaper at filePath is stored:
+// a .variants directory next to the original
+func variantPath(filePath, resolution, mode string) string {
+	ext := filepath.Ext(filePath)
+	name := strings.TrimSuffix(filepath.Base(filePath), ext)
+	return filepath.Join(filepath.Dir(filePath), constants.VariantsDir, fmt.Sprintf("%s-%s-%s%s", name, resolution, mode, ext))
+}
+
+// removeDerived deletes the thumbnail and display variants generated from a
+// wallpaper; the caller must hold the lock
+func (c *WallpaperCache) removeDerived(id string) {
+	c.removeThumbnail(id)
+
+	rows, err := c.db.Query(`SELECT path FROM wallpaper_variants WHERE wallpaper_id = ?`, id)
+	if err != nil {
+		slog.Warn("Failed to query display variants", "id", id, "error", err)
+		return
+	}
+	var paths []string
+	for rows.Next() {
+		var path string
+		if rows.Scan(&path) == nil {
+			paths = append(paths, path)
+		}
+	}
+	rows.Close()
+
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove display variant", "path", path, "error", err)
+		}
+	}
+
+	if _, err := c.db.Exec(`DELETE FROM wallpaper_variants WHERE wallpaper_id = ?`, id); err != nil {
+		slog.Warn("Failed to delete display variants", "id", id, "error", err)
+	}
+}
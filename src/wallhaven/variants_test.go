@@ -0,0 +1,103 @@
+package wallhaven
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
+)
+
+func TestWallpaperCache_GetDisplayVariant(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, ".cache")
+
+	testFile := filepath.Join(tmpDir, "wallhaven-abc123.png")
+	writeTestImage(t, testFile, 800, 400, testBands...)
+
+	cache, err := NewWallpaperCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	wallpaper := &Wallpaper{Path: "https://example.com/wallhaven-abc123.png"}
+	if err := cache.AddWallpaper(wallpaper, testFile, "010", "110"); err != nil {
+		t.Fatal(err)
+	}
+	metadata := cache.GetByID(GenerateID(wallpaper.Path))
+
+	// Matching resolutions use the original
+	original, err := cache.GetDisplayVariant(metadata, 800, 400, constants.CropModeCenter)
+	if err != nil {
+		t.Fatalf("GetDisplayVariant() error = %v", err)
+	}
+	if original != testFile {
+		t.Errorf("Expected original %s for matching resolution, got %s", testFile, original)
+	}
+
+	variant, err := cache.GetDisplayVariant(metadata, 300, 300, constants.CropModeCenter)
+	if err != nil {
+		t.Fatalf("GetDisplayVariant() error = %v", err)
+	}
+	if filepath.Dir(variant) != filepath.Join(tmpDir, constants.VariantsDir) {
+		t.Errorf("Expected variant next to the original, got %s", variant)
+	}
+	if size := imageSize(t, variant); size != image.Pt(300, 300) {
+		t.Errorf("Expected 300x300 variant, got %v", size)
+	}
+
+	// Smaller images are cropped to the aspect ratio but not upscaled
+	large, err := cache.GetDisplayVariant(metadata, 1600, 1600, constants.CropModeCenter)
+	if err != nil {
+		t.Fatalf("GetDisplayVariant() error = %v", err)
+	}
+	if size := imageSize(t, large); size != image.Pt(400, 400) {
+		t.Errorf("Expected 400x400 variant, got %v", size)
+	}
+
+	// The second request reuses the recorded variant
+	again, err := cache.GetDisplayVariant(metadata, 300, 300, constants.CropModeCenter)
+	if err != nil || again != variant {
+		t.Errorf("Expected cached variant %s, got %s (%v)", variant, again, err)
+	}
+
+	// Removing the wallpaper removes its variants
+	if err := cache.RemoveWallpaper(metadata.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{variant, large} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected variant %s to be removed with the wallpaper", path)
+		}
+	}
+}
+
+func TestFocusCrop(t *testing.T) {
+	flat := color.RGBA{R: 40, G: 40, B: 40, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 900, 300))
+	for x := range 900 {
+		// Flat on the left two thirds with detail on the right
+		c := flat
+		if x >= 600 {
+			c = testBands[(x/25)%len(testBands)]
+		}
+		for y := range 300 {
+			img.Set(x, y, c)
+		}
+	}
+
+	crop := focusCrop(img, 300, 300)
+	if crop.Dx() != 300 || crop.Dy() != 300 {
+		t.Fatalf("Expected 300x300 crop, got %v", crop)
+	}
+	if crop.Min.X < 500 {
+		t.Errorf("Expected crop over the detailed region, got %v", crop)
+	}
+
+	if center := centerCrop(img.Bounds(), 300, 300); center != image.Rect(300, 0, 600, 300) {
+		t.Errorf("Expected centred crop, got %v", center)
+	}
+}
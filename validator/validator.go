@@ -2,6 +2,9 @@
 package validator
 
 import (
+	"strconv"
+	"strings"
+
 	"git.asdf.cafe/abs3nt/wallhaven_dl/constants"
 	"git.asdf.cafe/abs3nt/wallhaven_dl/errors"
 )
@@ -78,6 +81,14 @@ func (v *Validator) ValidateRating(value int) error {
 	return nil
 }
 
+// ValidatePercentage validates a percentage parameter
+func (v *Validator) ValidatePercentage(value int) error {
+	if value < 0 || value > 100 {
+		return errors.NewValidationError("percentage", strconv.Itoa(value), "must be between 0 and 100")
+	}
+	return nil
+}
+
 // ValidateCleanupMode validates cleanup mode parameter
 func (v *Validator) ValidateCleanupMode(value string) error {
 	for _, valid := range constants.ValidCleanupModes {
@@ -88,6 +99,119 @@ func (v *Validator) ValidateCleanupMode(value string) error {
 	return errors.NewValidationError("cleanup_mode", value, "must be one of: "+joinStrings(constants.ValidCleanupModes))
 }
 
+// ValidateSource validates wallpaper source parameter
+func (v *Validator) ValidateSource(value string) error {
+	for _, valid := range constants.ValidSources {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("source", value, "must be one of: "+joinStrings(constants.ValidSources))
+}
+
+// ValidateThemeFormat validates theme output format parameter
+func (v *Validator) ValidateThemeFormat(value string) error {
+	for _, valid := range constants.ValidThemeFormats {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("theme_format", value, "must be one of: "+joinStrings(constants.ValidThemeFormats))
+}
+
+// ValidateCropMode validates display variant crop mode parameter
+func (v *Validator) ValidateCropMode(value string) error {
+	for _, valid := range constants.ValidCropModes {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("crop_mode", value, "must be one of: "+joinStrings(constants.ValidCropModes))
+}
+
+// ValidateLibraryFormat validates library document format parameter
+func (v *Validator) ValidateLibraryFormat(value string) error {
+	for _, valid := range constants.ValidLibraryFormats {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("library_format", value, "must be one of: "+joinStrings(constants.ValidLibraryFormats))
+}
+
+// ValidateMergePolicy validates library merge policy parameter
+func (v *Validator) ValidateMergePolicy(value string) error {
+	for _, valid := range constants.ValidMergePolicies {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("merge_policy", value, "must be one of: "+joinStrings(constants.ValidMergePolicies))
+}
+
+// ValidateOutputMode validates the output mode parameter
+func (v *Validator) ValidateOutputMode(value string) error {
+	for _, valid := range constants.ValidOutputModes {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("output", value, "must be one of: "+joinStrings(constants.ValidOutputModes))
+}
+
+// ValidateStatusBar validates the status bar format parameter
+func (v *Validator) ValidateStatusBar(value string) error {
+	for _, valid := range constants.ValidStatusBars {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("bar", value, "must be one of: "+joinStrings(constants.ValidStatusBars))
+}
+
+// ValidatePreviewMode validates the preview protocol parameter
+func (v *Validator) ValidatePreviewMode(value string) error {
+	for _, valid := range constants.ValidPreviewModes {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("preview", value, "must be one of: "+joinStrings(constants.ValidPreviewModes))
+}
+
+// ValidateEvictionPolicy validates the cache eviction policy parameter
+func (v *Validator) ValidateEvictionPolicy(value string) error {
+	for _, valid := range constants.ValidEvictionPolicies {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("eviction_policy", value, "must be one of: "+joinStrings(constants.ValidEvictionPolicies))
+}
+
+// ValidatePlaylistMode validates the playlist play mode parameter
+func (v *Validator) ValidatePlaylistMode(value string) error {
+	for _, valid := range constants.ValidPlaylistModes {
+		if value == valid {
+			return nil
+		}
+	}
+	return errors.NewValidationError("mode", value, "must be one of: "+joinStrings(constants.ValidPlaylistModes))
+}
+
+// ValidateResolution validates a WIDTHxHEIGHT resolution parameter
+func (v *Validator) ValidateResolution(value string) error {
+	width, height, ok := strings.Cut(value, "x")
+	if ok {
+		w, werr := strconv.Atoi(width)
+		h, herr := strconv.Atoi(height)
+		if werr == nil && herr == nil && w > 0 && h > 0 {
+			return nil
+		}
+	}
+	return errors.NewValidationError("resolution", value, "must be in the form WIDTHxHEIGHT, e.g. 2560x1440")
+}
+
 // Helper function to join strings
 func joinStrings(strings []string) string {
 	result := ""
@@ -39,4 +39,20 @@ func TestValidatePurity(t *testing.T) {
 	if err := v.ValidatePurity("112"); err == nil {
 		t.Error("Expected invalid purity characters to fail validation")
 	}
-}
\ No newline at end of file
+}
+
+func TestValidateResolution(t *testing.T) {
+	v := NewValidator()
+
+	// Test valid resolution
+	if err := v.ValidateResolution("2560x1440"); err != nil {
+		t.Errorf("Expected valid resolution to pass validation, got error: %v", err)
+	}
+
+	// Test invalid resolutions
+	for _, invalid := range []string{"2560", "2560x", "0x1440", "widexhigh"} {
+		if err := v.ValidateResolution(invalid); err == nil {
+			t.Errorf("Expected invalid resolution %s to fail validation", invalid)
+		}
+	}
+}